@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ConstructorCache memoizes the results of the pure constructors provided
+// with ProvidePure. When a pure constructor is called again with inputs
+// that are deeply equal to the inputs of an earlier call, the earlier results
+// are returned without calling the constructor. The same objects are then
+// shared by all hives using the cache. The constructors are identified by
+// the ProvidePure cell they were given to and not by their function, as the
+// closures of the same function literal may capture different variables. The
+// same cell must thus be used in the hives to share the results. Meant for
+// tests that build the same hive many times, see hivetest.WithConstructorCache.
+// Supplied with [hive.Options] field 'ConstructorCache'.
+type ConstructorCache struct {
+	mu      sync.Mutex
+	entries map[cacheKey][]cacheEntry
+}
+
+// cacheKey identifies a constructor in the cache. It is the pointer to the
+// constructor in the cell providing it.
+type cacheKey *any
+
+type cacheEntry struct {
+	args    []any
+	results []reflect.Value
+}
+
+// NewConstructorCache returns an empty constructor cache.
+func NewConstructorCache() *ConstructorCache {
+	return &ConstructorCache{entries: map[cacheKey][]cacheEntry{}}
+}
+
+func (c *ConstructorCache) lookup(ctor cacheKey, args []reflect.Value) ([]reflect.Value, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.entries[ctor] {
+		if reflect.DeepEqual(e.args, interfaces(args)) {
+			return e.results, true
+		}
+	}
+	return nil, false
+}
+
+func (c *ConstructorCache) store(ctor cacheKey, args, results []reflect.Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[ctor] = append(c.entries[ctor], cacheEntry{interfaces(args), results})
+}
+
+func interfaces(vs []reflect.Value) []any {
+	is := make([]any, len(vs))
+	for i, v := range vs {
+		is[i] = v.Interface()
+	}
+	return is
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cilium/hive/internal"
+)
+
+// RequireCapability constructs a cell that requires a capability of the
+// platform for the given cell, e.g. the operating system or a kernel feature.
+// The check is run when the hive is constructed: if it fails, constructing
+// the hive fails with an error naming the check, otherwise the cell is
+// applied as is. The cell is not shown in the Info when the check fails.
+//
+//	cell.RequireCapability(requireLinux, netlinkCell)
+//
+//	func requireLinux() error {
+//		if runtime.GOOS != "linux" {
+//			return fmt.Errorf("unsupported operating system %s", runtime.GOOS)
+//		}
+//		return nil
+//	}
+func RequireCapability(check func() error, c Cell) Cell {
+	return &capability{check: check, cell: c}
+}
+
+type capability struct {
+	check func() error
+	cell  Cell
+}
+
+func (c *capability) Apply(log *slog.Logger, cont Container, logThreshold time.Duration) error {
+	if err := c.check(); err != nil {
+		return fmt.Errorf("unmet capability %s: %w", internal.FuncNameAndLocation(c.check), err)
+	}
+	return c.cell.Apply(log, cont, logThreshold)
+}
+
+func (c *capability) Info(cont Container) Info {
+	if err := c.check(); err != nil {
+		return NewInfoNode("")
+	}
+	return c.cell.Info(cont)
+}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func capabilityPresent() error { return nil }
+func capabilityMissing() error { return errors.New("feature not available") }
+
+func TestRequireCapability(t *testing.T) {
+	var some *SomeObject
+	provide := cell.Provide(func() *SomeObject { return &SomeObject{X: 1} })
+
+	h := hive.New(cell.RequireCapability(capabilityPresent, provide))
+	require.NoError(t, h.Populate(&some))
+	assert.Equal(t, 1, some.X)
+
+	var msg string
+	func() {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(cell.RequireCapability(capabilityMissing, provide))
+	}()
+	assert.Regexp(t, `unmet capability cell_test.capabilityMissing \(.*capability_test.go:\d+\): feature not available`, msg)
+
+	info := cell.RequireCapability(capabilityMissing, provide).Info(nil).(*cell.InfoNode)
+	assert.Empty(t, info.Children())
+	info = cell.RequireCapability(capabilityPresent, provide).Info(nil).(*cell.InfoNode)
+	assert.Len(t, info.Children(), 1)
+}
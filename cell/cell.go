@@ -21,10 +21,10 @@ import (
 //   - Config(): Cell providing a configuration struct.
 type Cell interface {
 	// Info provides a structural summary of the cell for printing purposes.
-	Info(container) Info
+	Info(Container) Info
 
 	// Apply the cell to the dependency graph container.
-	Apply(*slog.Logger, container, time.Duration) error
+	Apply(*slog.Logger, Container, time.Duration) error
 }
 
 // In when embedded into a struct used as constructor parameter makes the exported
@@ -37,6 +37,21 @@ type Cell interface {
 // See https://pkg.go.dev/go.uber.org/dig#In for more information.
 type In = dig.In
 
+// Optional is a constructor or invoke function parameter for an optional
+// dependency. It is a shorthand for a struct embedding In with a field tagged
+// with `optional:"true"`. If T is not provided, Value is the zero value:
+//
+//	func newServer(metrics cell.Optional[*Metrics]) *Server {
+//		if metrics.Value != nil {
+//			...
+//		}
+//	}
+type Optional[T any] struct {
+	In
+
+	Value T `optional:"true"`
+}
+
 // Out when embedded into a struct that is returned by a constructor will make the
 // values in the struct become objects in the dependency graph instead of the struct
 // itself.
@@ -44,9 +59,9 @@ type In = dig.In
 // See https://pkg.go.dev/go.uber.org/dig#Out for more information.
 type Out = dig.Out
 
-// container is the common interface between dig.Container and dig.Scope.
-// Used in Apply().
-type container interface {
+// Container is the common interface between dig.Container and dig.Scope
+// the cells are applied to. Used in Apply().
+type Container interface {
 	Provide(ctor any, opts ...dig.ProvideOption) error
 	Invoke(fn any, opts ...dig.InvokeOption) error
 	Decorate(fn any, opts ...dig.DecorateOption) error
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+type SomeObject struct {
+	X int
+}
+
+type OtherObject struct {
+	Y int
+}
+
+type ThirdObject struct{}
+
+func newSome() *SomeObject { return &SomeObject{} }
+
+var shutdownOnStartCell = cell.Invoke(func(lc cell.Lifecycle, shutdowner hive.Shutdowner) {
+	lc.Append(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			shutdowner.Shutdown()
+			return nil
+		}})
+})
+
+// logRecorder is a slog.Handler that records the log records.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (r *logRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (r *logRecorder) Handle(_ context.Context, rec slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+	return nil
+}
+
+func (r *logRecorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+func (r *logRecorder) WithGroup(string) slog.Handler      { return r }
+
+// find returns the string value of the given attribute for all records
+// with the given level and message.
+func (r *logRecorder) find(level slog.Level, msg string, attr string) (values []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.records {
+		if rec.Level != level || rec.Message != msg {
+			continue
+		}
+		rec.Attrs(func(a slog.Attr) bool {
+			if a.Key == attr {
+				values = append(values, a.Value.String())
+			}
+			return true
+		})
+	}
+	return
+}
+
+type Greeter interface{ Greet() string }
+
+type englishGreeter struct{}
+
+func (englishGreeter) Greet() string { return "hello" }
+
+type ReloadConfig struct {
+	ReloadAddress string
+	ReloadLevel   string `reloadable:"true"`
+}
+
+func (ReloadConfig) Flags(flags *pflag.FlagSet) {
+	flags.String("reload-address", "localhost", "address")
+	flags.String("reload-level", "info", "log level")
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time for measuring the durations of the
+// constructors, invoke functions and lifecycle hooks. Tests can use a fake
+// clock to deterministically exercise the thresholds and timeouts.
+// Supplied with [hive.Options] field 'Clock'. Provided in the hive.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the Clock backed by the time package.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type clockKey struct{}
+
+// withClock returns the context with the clock if it is not nil, e.g. to
+// give the lifecycle's clock to the hooks.
+func withClock(ctx context.Context, c Clock) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, clockKey{}, c)
+}
+
+// clockFrom returns the clock of the context, or nil if it has none.
+func clockFrom(ctx context.Context) Clock {
+	c, _ := ctx.Value(clockKey{}).(Clock)
+	return c
+}
+
+// clockOrReal returns the clock, or RealClock if it is nil.
+func clockOrReal(c Clock) Clock {
+	if c == nil {
+		return RealClock
+	}
+	return c
+}
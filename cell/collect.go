@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"time"
+
+	"go.uber.org/dig"
+
+	"github.com/cilium/hive/internal"
+)
+
+// Collected are the members of a value group of type T collected with
+// Collect.
+type Collected[T any] []T
+
+// Collect constructs a cell that provides the members of the value group as
+// Collected[T], so that the group can be depended on without a struct
+// annotated with cell.In:
+//
+//	cell.ProvideGroup("handlers", newHelloHandler),
+//	cell.ProvideGroup("handlers", newGoodbyeHandler),
+//	cell.Collect[Handler]("handlers"),
+//
+//	func newServer(handlers cell.Collected[Handler]) *Server {
+//		...
+//	}
+//
+// As Collected[T] is identified by the type of the members, a group can be
+// collected only once in a hive.
+func Collect[T any](group string) Cell {
+	pc, _, _, _ := runtime.Caller(1)
+	return &collector[T]{
+		group:    group,
+		pc:       pc,
+		location: internal.CallerLocation(),
+	}
+}
+
+type collector[T any] struct {
+	group    string
+	pc       uintptr
+	location string
+}
+
+func (c *collector[T]) Apply(log *slog.Logger, cont Container, logThreshold time.Duration) error {
+	memberType := reflect.TypeOf((*T)(nil)).Elem()
+	params := reflect.StructOf([]reflect.StructField{
+		{Name: "In", Type: reflect.TypeOf(In{}), Anonymous: true},
+		{Name: "Members", Type: reflect.SliceOf(memberType), Tag: reflect.StructTag(fmt.Sprintf("group:%q", c.group))},
+	})
+	collectedType := reflect.TypeOf(Collected[T]{})
+	ctor := reflect.MakeFunc(
+		reflect.FuncOf([]reflect.Type{params}, []reflect.Type{collectedType}, false),
+		func(in []reflect.Value) []reflect.Value {
+			return []reflect.Value{in[0].Field(1).Convert(collectedType)}
+		},
+	)
+	if err := cont.Provide(ctor.Interface(), dig.Export(true), dig.LocationForPC(c.pc)); err != nil {
+		return fmt.Errorf("%s: %w", c.name(), err)
+	}
+	return nil
+}
+
+// name returns the name and location of the collector in the same form as
+// the names of the constructors.
+func (c *collector[T]) name() string {
+	return fmt.Sprintf("cell.Collect[%s] (%s)", reflect.TypeOf((*T)(nil)).Elem(), c.location)
+}
+
+func (c *collector[T]) Info(Container) Info {
+	memberType := reflect.TypeOf((*T)(nil)).Elem()
+	info := &ProviderInfo{
+		Name:     c.name(),
+		Exported: true,
+		Inputs:   []InfoValue{{Type: reflect.SliceOf(memberType).String(), Group: c.group}},
+		Outputs:  []InfoValue{{Type: reflect.TypeOf(Collected[T]{}).String()}},
+	}
+	n := newInfoNode("🚧", info.Name)
+	n.condensed = true
+	n.provider = info
+	addInfoValues(n, "⇨", "inputs", info.Inputs)
+	addInfoValues(n, "⇦", "outputs", info.Outputs)
+	return n
+}
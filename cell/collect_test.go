@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestCollect(t *testing.T) {
+	var greetings []string
+	h := hive.New(
+		cell.ProvideGroup("greeters", func() Greeter { return englishGreeter{} }),
+		cell.Module("test", "Test",
+			cell.ProvideGroup("greeters", func() Greeter { return &namedGreeter{"test"} }),
+		),
+		cell.Collect[Greeter]("greeters"),
+		cell.Invoke(func(greeters cell.Collected[Greeter]) {
+			for _, g := range greeters {
+				greetings = append(greetings, g.Greet())
+			}
+		}),
+	)
+	require.NoError(t, h.Populate(), "Populate")
+	assert.ElementsMatch(t, []string{"hello", "Hello, test"}, greetings)
+
+	var buf bytes.Buffer
+	cmd := h.Command()
+	cmd.SetOut(&buf)
+	cmd.SetArgs([]string{"objects"})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "cell.Collect[cell_test.Greeter]")
+	assert.Contains(t, buf.String(), `[]cell_test.Greeter[group = "greeters"]`)
+	assert.Contains(t, buf.String(), "cell.Collected[github.com/cilium/hive/cell_test.Greeter]")
+}
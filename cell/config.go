@@ -22,6 +22,26 @@ import (
 // registers the flags. The structure is populated and provided via dependency
 // injection by Hive.Run(). The underlying mechanism for populating the struct
 // is viper's Unmarshal().
+//
+// The flags are global and not scoped to the module the config cell is in,
+// so by convention the flag names are prefixed with the module ID, e.g.
+// "server-address" for the "server" module. Nested structs can be used by
+// embedding them with the `mapstructure:",squash"` tag, which matches the
+// fields of the nested struct with the flags as if they were fields of the
+// outer struct:
+//
+//	type Config struct {
+//		Address   string `mapstructure:"server-address"`
+//		TLSConfig `mapstructure:",squash"`
+//	}
+//
+//	type TLSConfig struct {
+//		CertFile string `mapstructure:"server-cert-file"`
+//	}
+//
+// The flags are registered anew for each hive the cell is applied to, so the
+// same config cell can be used in multiple hives with different configuration,
+// e.g. set with command-line flags, Hive.Viper() or hive.AddConfigOverride.
 func Config[Cfg Flagger](def Cfg) Cell {
 	c := &config[Cfg]{defaultConfig: def, flags: pflag.NewFlagSet("", pflag.ContinueOnError)}
 	def.Flags(c.flags)
@@ -42,6 +62,12 @@ type Flagger interface {
 	// Exported fields that are not found from the viper settings will cause
 	// hive.Run() to fail. Unexported fields are ignored.
 	//
+	// If the configuration struct has a 'Validate() error' method, it is
+	// called after the struct has been populated and hive.Run() fails if it
+	// returns an error. The error should name the invalid field. All configs
+	// are validated before anything else is constructed and the errors from
+	// all invalid configs are reported.
+	//
 	// See https://pkg.go.dev/github.com/mitchellh/mapstructure for more info.
 	Flags(*pflag.FlagSet)
 }
@@ -53,6 +79,17 @@ type config[Cfg Flagger] struct {
 	flags         *pflag.FlagSet
 }
 
+// configValidator is optionally implemented by the configuration structs.
+type configValidator interface {
+	Validate() error
+}
+
+// configChecker is optionally implemented by the InvokerList for checking
+// the configs before the invoke functions are run.
+type configChecker interface {
+	AppendConfigCheck(func() error)
+}
+
 type AllSettings map[string]any
 
 type DecodeHooks []mapstructure.DecodeHookFunc
@@ -64,7 +101,7 @@ type configParams[Cfg Flagger] struct {
 	Override    func(*Cfg)  `optional:"true"`
 }
 
-func (c *config[Cfg]) provideConfig(p configParams[Cfg]) (Cfg, error) {
+func (c *config[Cfg]) provideConfig(p configParams[Cfg], prefix string) (Cfg, error) {
 	settings := p.AllSettings
 	target := c.defaultConfig
 	decoder, err := mapstructure.NewDecoder(decoderConfig(&target, p.DecodeHooks))
@@ -76,10 +113,10 @@ func (c *config[Cfg]) provideConfig(p configParams[Cfg]) (Cfg, error) {
 	input := make(map[string]any)
 
 	c.flags.VisitAll(func(f *pflag.Flag) {
-		if v, ok := settings[f.Name]; ok {
+		if v, ok := settings[prefix+f.Name]; ok {
 			input[f.Name] = v
 		} else {
-			err = fmt.Errorf("internal error: %s not found from settings", f.Name)
+			err = fmt.Errorf("internal error: %s not found from settings", prefix+f.Name)
 		}
 	})
 	if err != nil {
@@ -98,6 +135,12 @@ func (c *config[Cfg]) provideConfig(p configParams[Cfg]) (Cfg, error) {
 		p.Override(&target)
 	}
 
+	if v, ok := any(target).(configValidator); ok {
+		if err := v.Validate(); err != nil {
+			return target, fmt.Errorf("invalid config %T: %w", target, err)
+		}
+	}
+
 	return target, nil
 }
 
@@ -146,24 +189,134 @@ func decoderConfig(target any, extraHooks DecodeHooks) *mapstructure.DecoderConf
 	}
 }
 
-func (c *config[Cfg]) Apply(log *slog.Logger, cont container, logThreshold time.Duration) error {
-	// Register the flags to the global set of all flags.
-	err := cont.Invoke(
-		func(allFlags *pflag.FlagSet) {
-			allFlags.AddFlagSet(c.flags)
+func (c *config[Cfg]) Apply(log *slog.Logger, cont Container, logThreshold time.Duration) error {
+	// Register the flags anew for each hive as the registered flags hold
+	// the values parsed by the hive and the same cell may be used in
+	// multiple hives concurrently. Prefix the flags if the config is in a
+	// module with a flag prefix.
+	prefix := flagPrefix(cont)
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	c.defaultConfig.Flags(flags)
+	if prefix != "" {
+		unprefixed := flags
+		flags = pflag.NewFlagSet("", pflag.ContinueOnError)
+		unprefixed.VisitAll(func(f *pflag.Flag) {
+			prefixed := *f
+			prefixed.Name = prefix + f.Name
+			prefixed.Shorthand = ""
+			flags.AddFlag(&prefixed)
 		})
-	if err != nil {
+	}
+
+	// Register the flags to the global set of all flags.
+	if err := registerFlags(cont, fmt.Sprintf("config %T", c.defaultConfig), flags); err != nil {
 		return err
 	}
 	// And provide the constructor for the config.
-	return cont.Provide(c.provideConfig, dig.Export(true))
+	err := cont.Provide(
+		func(p configParams[Cfg]) (Cfg, error) {
+			return c.provideConfig(p, prefix)
+		},
+		dig.Export(true))
+	if err != nil {
+		return err
+	}
+
+	// Validate the config when the hive is populated even if nothing
+	// depends on it.
+	if _, ok := any(c.defaultConfig).(configValidator); ok {
+		return cont.Invoke(func(l InvokerList) {
+			check := func() error {
+				return cont.Invoke(func(Cfg) {})
+			}
+			if checker, ok := l.(configChecker); ok {
+				checker.AppendConfigCheck(check)
+			} else {
+				l.AppendInvoke(check)
+			}
+		})
+	}
+	return nil
 }
 
-func (c *config[Cfg]) Info(cont container) (info Info) {
-	cont.Invoke(func(cfg Cfg) {
-		info = &InfoStruct{cfg}
+func (c *config[Cfg]) Info(cont Container) Info {
+	// Show the default configuration if the config cannot be constructed,
+	// e.g. when the hive has not been populated.
+	cfg := c.defaultConfig
+	cont.Invoke(func(populated Cfg) {
+		cfg = populated
 	})
-	return
+	return &InfoStruct{
+		value:  cfg,
+		fields: configFields(reflect.ValueOf(cfg), "", c.flags, flagPrefix(cont)),
+	}
+}
+
+// ConfigField is a field of a populated configuration struct.
+// See [InfoStruct.ConfigFields].
+type ConfigField struct {
+	// Name is the name of the field. The fields of nested structs are
+	// prefixed with the name of the nested struct, e.g. "TLSConfig.CertFile".
+	Name string
+
+	// Flag is the name of the flag the field was populated from.
+	Flag string
+
+	// Value is the value of the field.
+	Value any
+
+	// Sensitive is true if the field is tagged with `sensitive:"true"`,
+	// in which case the value should not be shown.
+	Sensitive bool
+
+	// Reloadable is true if the field is tagged with `reloadable:"true"`,
+	// in which case the value may change when the config of a
+	// ReloadableConfig cell is reloaded.
+	Reloadable bool
+}
+
+// configFields returns the fields of the configuration struct that
+// are matched with the flags in the same way as by the decoder.
+func configFields(v reflect.Value, namePrefix string, flags *pflag.FlagSet, flagPrefix string) []ConfigField {
+	var fields []ConfigField
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		key, opts, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if opts == "squash" {
+			fields = append(fields, configFields(v.Field(i), namePrefix+f.Name+".", flags, flagPrefix)...)
+			continue
+		}
+		if key == "" {
+			key = f.Name
+		}
+		var flag string
+		flags.VisitAll(func(fl *pflag.Flag) {
+			// The decoder matches a key given with a tag as is.
+			if flag == "" && (fl.Name == key || strings.EqualFold(strings.ReplaceAll(fl.Name, "-", ""), key)) {
+				flag = fl.Name
+			}
+		})
+		if flag == "" {
+			continue
+		}
+		fields = append(fields, ConfigField{
+			Name:       namePrefix + f.Name,
+			Flag:       flagPrefix + flag,
+			Value:      v.Field(i).Interface(),
+			Sensitive:  f.Tag.Get("sensitive") == "true",
+			Reloadable: f.Tag.Get("reloadable") == "true",
+		})
+	}
+	return fields
 }
 
 // stringToMapHookFunc is a DecodeHookFunc that converts string
@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/dig"
+
+	"github.com/cilium/hive/internal"
+)
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	cleanupType = reflect.TypeOf(Cleanup(nil))
+)
+
+// Cleanup is a function for tearing down what a constructor allocated. A
+// constructor may return it after its other results, e.g.
+// func() (*DB, cell.Cleanup, error), to have it run when the hive is stopped.
+// See Provide.
+type Cleanup func() error
+
+// ConstructorMetrics is an optional sink for how long the constructors took
+// to run, e.g. to observe them with a Prometheus histogram. The name is the
+// function name and location of the constructor.
+// Supplied with [hive.Options] field 'ConstructorMetrics'.
+type ConstructorMetrics interface {
+	ObserveConstructor(name string, duration time.Duration)
+}
+
+// StrictProvideThreshold if true makes a constructor that takes longer than
+// the log threshold fail with an error instead of only being logged.
+// Supplied with [hive.Options] field 'StrictProvideThreshold'.
+type StrictProvideThreshold bool
+
+type ctorWrapperParams struct {
+	In
+	ConstructorMetrics     ConstructorMetrics     `optional:"true"`
+	Lifecycle              Lifecycle              `optional:"true"`
+	Clock                  Clock                  `optional:"true"`
+	StrictProvideThreshold StrictProvideThreshold `optional:"true"`
+	ConstructorCache       *ConstructorCache      `optional:"true"`
+	Tracer                 Tracer                 `optional:"true"`
+}
+
+// ctorWrapper wraps constructors. See wrap.
+type ctorWrapper struct {
+	log          *slog.Logger
+	logThreshold time.Duration
+
+	// metrics if not nil is given the durations of the constructors.
+	metrics ConstructorMetrics
+
+	// lifecycle if not nil tracks the dependency levels of the
+	// constructors for running stop hooks in parallel.
+	lifecycle *DefaultLifecycle
+
+	// clock measures the durations of the constructors.
+	clock Clock
+
+	// strict if true fails the constructors that take longer than
+	// logThreshold.
+	strict bool
+
+	// lc if not nil is the lifecycle to append the stop hooks for the
+	// cleanup functions returned by the constructors to.
+	lc Lifecycle
+
+	// cache if not nil is the cache for the results of the constructors.
+	// Only set for the pure constructors.
+	cache *ConstructorCache
+
+	// cacheKey identifies the constructor in the cache. The results are
+	// only cached if it is set.
+	cacheKey cacheKey
+
+	// tracer if not nil starts a span for each constructor call.
+	tracer Tracer
+
+	// onConstructed if not nil is called after a constructor has
+	// succeeded.
+	onConstructed func()
+}
+
+// fill fills the wrapper from the objects provided by the hive. The results
+// of the constructors are only cached if pure is true.
+func (w *ctorWrapper) fill(c Container, pure bool) error {
+	return c.Invoke(func(p ctorWrapperParams) {
+		w.metrics = p.ConstructorMetrics
+		w.lifecycle = levelTracker(p.Lifecycle)
+		w.clock = p.Clock
+		w.lc = p.Lifecycle
+		w.strict = bool(p.StrictProvideThreshold)
+		if pure {
+			w.cache = p.ConstructorCache
+		}
+		w.tracer = p.Tracer
+	})
+}
+
+// wrap wraps the constructor to log how long it took to run, to record the
+// duration to the metrics, to track its dependency level, and to turn a panic
+// in the constructor into an error that includes the location of the
+// constructor and the stack trace. If the constructor does not return an
+// error then the wrapper has an additional error result. If the constructor
+// returns a Cleanup after its other results, the Cleanup is not provided as
+// an object; it is appended as a stop hook once the constructor has
+// succeeded. dig is told the location of the original constructor for its
+// error messages. The inputs and outputs are filled by dig when the
+// constructor is provided.
+func (w ctorWrapper) wrap(ctor any, inputs *[]*dig.Input, outputs *[]*dig.Output) (any, []dig.ProvideOption) {
+	v := reflect.ValueOf(ctor)
+	if v.Kind() != reflect.Func {
+		// Let dig complain about it.
+		return ctor, nil
+	}
+	name := internal.FuncNameAndLocation(ctor)
+	typ := v.Type()
+	call := v.Call
+	if typ.IsVariadic() {
+		call = v.CallSlice
+	}
+
+	ins, outs := funcTypes(typ)
+	returnsError := len(outs) > 0 && outs[len(outs)-1] == errorType
+	if returnsError {
+		outs = outs[:len(outs)-1]
+	}
+	// Only consider the last result a cleanup if there are other results
+	// for the cleanup to be of.
+	cleanupIdx := -1
+	if n := len(outs); n > 1 && outs[n-1] == cleanupType {
+		cleanupIdx = n - 1
+		outs = outs[:n-1]
+	}
+	outs = append(outs, errorType)
+	wrappedType := reflect.FuncOf(ins, outs, typ.IsVariadic())
+
+	wrapped := reflect.MakeFunc(wrappedType, func(args []reflect.Value) (results []reflect.Value) {
+		if w.tracer != nil {
+			_, span := w.tracer.Start(context.Background(), name)
+			// Deferred before the recovery from a panic so that the span
+			// ends with the error from the panic.
+			defer func() { span.End(resultError(results)) }()
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				results = panicResults(outs, fmt.Errorf("constructor %s panicked: %w\n%s", name, panicError(r), debug.Stack()))
+			}
+		}()
+
+		if w.lifecycle != nil {
+			exit := w.lifecycle.enterFunc(func() []levelKey {
+				keys := make([]levelKey, len(*inputs))
+				for i, in := range *inputs {
+					keys[i] = inputLevelKey(internal.DigInput(in, typ))
+				}
+				return keys
+			})
+			defer func() {
+				keys := make([]levelKey, len(*outputs))
+				for i, out := range *outputs {
+					keys[i] = outputLevelKey(internal.DigOutput(out, typ))
+				}
+				exit(keys)
+			}()
+		}
+
+		if w.cache != nil && w.cacheKey != nil {
+			if cached, ok := w.cache.lookup(w.cacheKey, args); ok {
+				w.log.Debug("Constructed from cache", "function", name)
+				return cached
+			}
+		}
+
+		clock := clockOrReal(w.clock)
+		t0 := clock.Now()
+		results = call(args)
+		d := clock.Since(t0)
+		if !returnsError {
+			results = append(results, reflect.Zero(errorType))
+		}
+		var cleanup Cleanup
+		if cleanupIdx >= 0 {
+			cleanup = results[cleanupIdx].Interface().(Cleanup)
+			results = append(results[:cleanupIdx], results[cleanupIdx+1:]...)
+		}
+		if d > w.logThreshold {
+			w.log.Info("Constructed", "duration", d, "function", name)
+			if w.strict && w.logThreshold > 0 && results[len(results)-1].IsNil() {
+				results = panicResults(outs, fmt.Errorf("constructor %s took %s, longer than the threshold %s", name, d, w.logThreshold))
+				// The objects are discarded, so tear them down right away.
+				if cleanup != nil {
+					cleanup()
+					cleanup = nil
+				}
+			}
+		} else {
+			w.log.Debug("Constructed", "duration", d, "function", name)
+		}
+		if cleanup != nil && results[len(results)-1].IsNil() {
+			w.appendCleanup(name, cleanup)
+		}
+		if w.metrics != nil {
+			w.metrics.ObserveConstructor(name, d)
+		}
+		if w.cache != nil && w.cacheKey != nil && cleanupIdx < 0 && results[len(results)-1].IsNil() {
+			w.cache.store(w.cacheKey, args, results)
+		}
+		if w.onConstructed != nil && results[len(results)-1].IsNil() {
+			w.onConstructed()
+		}
+		return results
+	})
+	return wrapped.Interface(), []dig.ProvideOption{dig.LocationForPC(v.Pointer())}
+}
+
+// funcTypes returns the parameter and result types of the function type.
+func funcTypes(typ reflect.Type) (ins, outs []reflect.Type) {
+	ins = make([]reflect.Type, typ.NumIn())
+	for i := range ins {
+		ins[i] = typ.In(i)
+	}
+	outs = make([]reflect.Type, typ.NumOut())
+	for i := range outs {
+		outs[i] = typ.Out(i)
+	}
+	return ins, outs
+}
+
+// appendCleanup appends the cleanup function returned by the constructor
+// as a stop hook.
+func (w ctorWrapper) appendCleanup(name string, cleanup Cleanup) {
+	if w.lc == nil {
+		return
+	}
+	w.lc.Append(HookWithName("cleanup of "+name, Hook{
+		OnStop: func(HookContext) error { return cleanup() },
+	}))
+}
+
+// panicError returns the recovered panic value as an error so that
+// panics with an error value can be matched with errors.Is.
+func panicError(r any) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
+
+// panicResults returns zero values for the results with the final
+// error result set to err. Used for failing the wrapped constructors.
+func panicResults(outs []reflect.Type, err error) []reflect.Value {
+	results := make([]reflect.Value, len(outs))
+	for i, out := range outs[:len(outs)-1] {
+		results[i] = reflect.Zero(out)
+	}
+	results[len(outs)-1] = reflect.ValueOf(&err).Elem()
+	return results
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+// slowClock is the clock of the hives with the slow constructors, which
+// advance it as if they took 10ms.
+var slowClock = &fakeClock{now: time.Unix(0, 0)}
+
+func newFast() *SomeObject { return &SomeObject{1} }
+
+func newSlow() *OtherObject {
+	slowClock.Advance(10 * time.Millisecond)
+	return &OtherObject{2}
+}
+
+func newSlowInt() int {
+	slowClock.Advance(10 * time.Millisecond)
+	return 3
+}
+
+type fakeConstructorMetrics struct {
+	mu        sync.Mutex
+	durations map[string][]time.Duration
+}
+
+func (m *fakeConstructorMetrics) ObserveConstructor(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, _, _ = strings.Cut(name, " ")
+	m.durations[name] = append(m.durations[name], d)
+}
+
+// fakeClock is a cell.Clock that only advances when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.Now().Sub(t) }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestProvideWithThreshold(t *testing.T) {
+	var rec logRecorder
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(&rec)
+	opts.LogThreshold = time.Hour
+	opts.Clock = slowClock
+
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(newFast, newSlowInt),
+		cell.ProvideWithThreshold(time.Millisecond, newSlow),
+		cell.Invoke(func(*SomeObject, *OtherObject, int) {}),
+	)
+	require.NoError(t, h.Populate())
+
+	infos := rec.find(slog.LevelInfo, "Constructed", "function")
+	if assert.Len(t, infos, 1) {
+		assert.Contains(t, infos[0], "cell_test.newSlow ")
+	}
+	debugs := rec.find(slog.LevelDebug, "Constructed", "function")
+	assert.Len(t, debugs, 2)
+}
+
+func newPanicking() *SomeObject {
+	panic("oh no")
+}
+
+func TestProvidePanic(t *testing.T) {
+	h := hive.New(
+		cell.Provide(newPanicking),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	err := h.Start(context.TODO())
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "constructor cell_test.newPanicking (")
+	assert.ErrorContains(t, err, "ctor_test.go:")
+	assert.ErrorContains(t, err, "panicked: oh no")
+
+	// Panics with an error value can be matched against.
+	errPanic := errors.New("panic error")
+	h = hive.New(
+		cell.Provide(func() (*SomeObject, error) { panic(errPanic) }),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	assert.ErrorIs(t, h.Start(context.TODO()), errPanic)
+}
+
+func TestConstructorMetrics(t *testing.T) {
+	metrics := &fakeConstructorMetrics{durations: map[string][]time.Duration{}}
+	opts := hive.DefaultOptions()
+	opts.ConstructorMetrics = metrics
+	opts.Clock = slowClock
+
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(newFast, newSlow),
+		cell.Module("test", "Test Module",
+			cell.ProvidePrivate(newSlowInt),
+		),
+		cell.Invoke(func(*SomeObject, *OtherObject) {}),
+	)
+	require.NoError(t, h.Populate())
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	require.Len(t, metrics.durations["cell_test.newFast"], 1)
+	require.Len(t, metrics.durations["cell_test.newSlow"], 1)
+	assert.Equal(t, 10*time.Millisecond, metrics.durations["cell_test.newSlow"][0])
+
+	// Constructors that were not needed are not observed.
+	assert.NotContains(t, metrics.durations, "cell_test.newSlowInt")
+}
+
+func TestConstructorCleanup(t *testing.T) {
+	var cleanups []string
+	cleanup := func(name string) cell.Cleanup {
+		return func() error {
+			cleanups = append(cleanups, name)
+			return nil
+		}
+	}
+	h := hive.New(
+		cell.Provide(
+			func() (*SomeObject, cell.Cleanup) {
+				return &SomeObject{}, cleanup("some")
+			},
+			func(*SomeObject) (*OtherObject, cell.Cleanup, error) {
+				return &OtherObject{}, cleanup("other"), nil
+			},
+			// Never constructed as nothing depends on it.
+			func() (*ThirdObject, cell.Cleanup) {
+				return &ThirdObject{}, cleanup("third")
+			},
+		),
+		cell.Invoke(func(*OtherObject) {}),
+	)
+
+	require.NoError(t, h.Start(context.TODO()))
+	assert.Empty(t, cleanups)
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, []string{"other", "some"}, cleanups)
+
+	// The cleanup is not provided as an object.
+	err := hive.New(
+		cell.Provide(func() (*SomeObject, cell.Cleanup) { return &SomeObject{}, func() error { return nil } }),
+		cell.Invoke(func(cell.Cleanup) {}),
+	).Populate()
+	assert.ErrorContains(t, err, "missing type: cell.Cleanup")
+
+	// Other function results are provided as objects.
+	var fn func()
+	err = hive.New(
+		cell.Provide(func() (*SomeObject, func()) { return &SomeObject{}, func() {} }),
+		cell.Invoke(func(f func()) { fn = f }),
+	).Populate()
+	require.NoError(t, err)
+	assert.NotNil(t, fn)
+
+	// The cleanup of a failed constructor is not run.
+	cleanups = nil
+	h = hive.New(
+		cell.Provide(func() (*SomeObject, cell.Cleanup, error) {
+			return nil, cleanup("failed"), errors.New("fail")
+		}),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	assert.Error(t, h.Start(context.TODO()))
+	assert.NoError(t, h.Stop(context.TODO()))
+	assert.Empty(t, cleanups)
+
+	// The cleanup of a constructor failing the strict threshold is run
+	// right away and not appended as a stop hook.
+	cleanups = nil
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	opts := hive.DefaultOptions()
+	opts.Clock = clock
+	opts.LogThreshold = time.Second
+	opts.StrictProvideThreshold = true
+	h = hive.NewWithOptions(opts,
+		cell.Provide(func() (*SomeObject, cell.Cleanup) {
+			clock.Advance(2 * time.Second)
+			return &SomeObject{}, cleanup("slow")
+		}),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	assert.ErrorContains(t, h.Start(context.TODO()), "longer than the threshold")
+	assert.Equal(t, []string{"slow"}, cleanups)
+	assert.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, []string{"slow"}, cleanups)
+}
+
+func TestStrictProvideThreshold(t *testing.T) {
+	newHive := func(strict bool) (*hive.Hive, *logRecorder) {
+		var rec logRecorder
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		opts := hive.DefaultOptions()
+		opts.Logger = slog.New(&rec)
+		opts.LogThreshold = time.Second
+		opts.Clock = clock
+		opts.StrictProvideThreshold = strict
+		return hive.NewWithOptions(
+			opts,
+			cell.Provide(func() *SomeObject {
+				clock.Advance(5 * time.Second)
+				return &SomeObject{}
+			}),
+			cell.Provide(func(*SomeObject) *OtherObject { return &OtherObject{} }),
+			cell.Invoke(func(*OtherObject) {}),
+		), &rec
+	}
+
+	// Non-strict: the slow constructor is only logged.
+	h, rec := newHive(false)
+	require.NoError(t, h.Populate())
+	assert.Equal(t, []string{"5s"}, rec.find(slog.LevelInfo, "Constructed", "duration"))
+
+	// Strict: populating fails naming the slow constructor.
+	h, _ = newHive(true)
+	err := h.Populate()
+	assert.ErrorContains(t, err, "TestStrictProvideThreshold.func1.1")
+	assert.ErrorContains(t, err, "took 5s, longer than the threshold 1s")
+}
@@ -4,8 +4,10 @@
 package cell
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"reflect"
 	"time"
 
 	"github.com/cilium/hive/internal"
@@ -16,7 +18,9 @@ import (
 //
 // A decorator function is a function that takes as arguments objects
 // in the hive and returns one or more augmented objects. The cells wrapped
-// with a decorator will be provided the returned augmented objects.
+// with a decorator will be provided the returned augmented objects. The
+// decorator only applies to the wrapped cells and the modules nested in
+// them, similar to ProvidePrivate, and not to the rest of the hive.
 //
 // Example:
 //
@@ -40,23 +44,33 @@ type decorator struct {
 	cells     []Cell
 }
 
-func (d *decorator) Apply(log *slog.Logger, c container, logThreshold time.Duration) error {
-	scope := c.Scope(fmt.Sprintf("(decorate %s)", internal.PrettyType(d.decorator)))
-	if err := scope.Decorate(d.decorator); err != nil {
+func (d *decorator) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	scope := newScope(c, fmt.Sprintf("(decorate %s)", internal.PrettyType(d.decorator)))
+	cont := scope
+	if fc, ok := c.(flagPrefixContainer); ok {
+		fc.Container = scope
+		cont = fc
+	}
+	if err := cont.Decorate(d.decorator); err != nil {
 		return err
 	}
 
+	var errs []error
 	for _, cell := range d.cells {
-		if err := cell.Apply(log, scope, logThreshold); err != nil {
-			return err
+		if err := cell.Apply(log, cont, logThreshold); err != nil {
+			errs = append(errs, err)
 		}
 	}
-
-	return nil
+	return errors.Join(errs...)
 }
 
-func (d *decorator) Info(c container) Info {
-	n := NewInfoNode(fmt.Sprintf("🔀 %s: %s", internal.FuncNameAndLocation(d.decorator), internal.PrettyType(d.decorator)))
+func (d *decorator) Info(c Container) Info {
+	n := newInfoNode("🔀", fmt.Sprintf("%s: %s", internal.FuncNameAndLocation(d.decorator), internal.PrettyType(d.decorator)))
+	n.decorator = &InvokeInfo{Name: internal.FuncNameAndLocation(d.decorator)}
+	for _, input := range funcInputs(d.decorator) {
+		n.decorator.inputTypes.add(&n.decorator.Inputs, internal.DigInput(input, reflect.TypeOf(d.decorator)))
+	}
+	sortInfoValues(n.decorator.Inputs)
 	for _, cell := range d.cells {
 		n.Add(cell.Info(c))
 	}
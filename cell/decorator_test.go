@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestDecorate(t *testing.T) {
+	invoked := false
+
+	testCell := cell.Decorate(
+		func(o *SomeObject) *SomeObject {
+			return &SomeObject{X: o.X + 1}
+		},
+		cell.Invoke(
+			func(o *SomeObject) error {
+				if o.X != 2 {
+					return errors.New("X != 2")
+				}
+				invoked = true
+				return nil
+			}),
+	)
+
+	hive := hive.New(
+		cell.Provide(func() *SomeObject { return &SomeObject{1} }),
+
+		// Here *SomeObject is not decorated.
+		cell.Invoke(func(o *SomeObject) error {
+			if o.X != 1 {
+				return errors.New("X != 1")
+			}
+			return nil
+		}),
+
+		testCell,
+
+		shutdownOnStartCell,
+	)
+
+	assert.NoError(t, hive.Run(), "expected Run() to succeed")
+	assert.True(t, invoked, "expected decorated invoke function to be called")
+}
+
+func TestDecorateScope(t *testing.T) {
+	type decorated struct{ X int }
+	var inScope, nested, sibling *decorated
+
+	h := hive.New(
+		cell.Provide(func() *SomeObject { return &SomeObject{1} }),
+
+		cell.Decorate(
+			func(o *SomeObject) *SomeObject {
+				return &SomeObject{X: o.X + 1}
+			},
+			cell.ProvidePrivate(func(o *SomeObject) *decorated { return &decorated{o.X} }),
+			cell.Invoke(func(d *decorated) { inScope = d }),
+			cell.Module("nested", "Nested module",
+				cell.Invoke(func(o *SomeObject) { nested = &decorated{o.X} }),
+			),
+		),
+
+		cell.Module("sibling", "Sibling module",
+			cell.Invoke(func(o *SomeObject) { sibling = &decorated{o.X} }),
+		),
+	)
+
+	require.NoError(t, h.Populate(), "Populate")
+	assert.Equal(t, 2, inScope.X, "expected constructor in scope to see decorated object")
+	assert.Equal(t, 2, nested.X, "expected nested module to see decorated object")
+	assert.Equal(t, 1, sibling.X, "expected sibling module to see original object")
+}
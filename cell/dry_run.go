@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/dig"
+)
+
+// dryRunContainer is the container the cells are applied to when a hive is
+// validated without calling its constructors and invoke functions. A
+// container created with dig.DryRun does not call any functions, including
+// the ones the cells invoke when they are applied, e.g. to append their
+// invoke functions or to register their flags. The cells are therefore
+// applied both to the dry run container and to a mirror of it in which the
+// functions are called, until all the cells have been applied. After that
+// the functions are only invoked in the dry run container.
+type dryRunContainer struct {
+	dry, mirror Container
+	applied     *atomic.Bool
+}
+
+// NewDryRunContainer returns the container to apply the cells of a hive to
+// for validating the hive in the dry run container created with
+// dig.DryRun(true), and the function to call once all the cells have been
+// applied. The mirror is a container created with the same options, but not
+// in dry run mode. Used by hive.Validate.
+func NewDryRunContainer(dry, mirror *dig.Container) (c Container, applied func()) {
+	dc := dryRunContainer{dry, mirror, &atomic.Bool{}}
+	return dc, func() { dc.applied.Store(true) }
+}
+
+func (c dryRunContainer) Provide(ctor any, opts ...dig.ProvideOption) error {
+	if err := c.dry.Provide(ctor, opts...); err != nil {
+		return err
+	}
+	return c.mirror.Provide(ctor, opts...)
+}
+
+func (c dryRunContainer) Invoke(fn any, opts ...dig.InvokeOption) error {
+	if c.applied.Load() {
+		return c.dry.Invoke(fn, opts...)
+	}
+	return c.mirror.Invoke(fn, opts...)
+}
+
+func (c dryRunContainer) Decorate(fn any, opts ...dig.DecorateOption) error {
+	if err := c.dry.Decorate(fn, opts...); err != nil {
+		return err
+	}
+	return c.mirror.Decorate(fn, opts...)
+}
+
+// Scope returns the child scope of the dry run container only. The cells
+// use newScope instead to have the child scope mirrored as well.
+func (c dryRunContainer) Scope(name string, opts ...dig.ScopeOption) *dig.Scope {
+	return c.dry.Scope(name, opts...)
+}
+
+// asDryRun returns the dry run container the container is for, if any.
+func asDryRun(c Container) (dryRunContainer, bool) {
+	if fc, ok := c.(flagPrefixContainer); ok {
+		c = fc.Container
+	}
+	dc, ok := c.(dryRunContainer)
+	return dc, ok
+}
+
+// newScope returns a new child scope of the container, which is in dry run
+// mode if the container is.
+func newScope(c Container, name string) Container {
+	if dc, ok := asDryRun(c); ok {
+		return dryRunContainer{dc.dry.Scope(name), dc.mirror.Scope(name), dc.applied}
+	}
+	return c.Scope(name)
+}
+
+// newContainer returns a new container with the options for applying cells
+// to a container of their own, e.g. in a sub-hive. The new container is in
+// dry run mode if the container c is.
+func newContainer(c Container, opts ...dig.Option) Container {
+	if dc, ok := asDryRun(c); ok {
+		dryOpts := append(append([]dig.Option{}, opts...), dig.DryRun(true))
+		return dryRunContainer{dig.New(dryOpts...), dig.New(opts...), dc.applied}
+	}
+	return dig.New(opts...)
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// EventBus is a typed publish-subscribe bus for decoupling the components
+// publishing events of type T from the components consuming them. Provide
+// it with ProvideEventBus and inject *EventBus[T] into both the publishers
+// and the subscribers:
+//
+//	cell.ProvideEventBus[NodeAdded](cell.EventBusOptions{BufferSize: 16})
+//
+//	func startWatcher(bus *cell.EventBus[NodeAdded]) { ... bus.Publish(ev) ... }
+//	func startConsumer(bus *cell.EventBus[NodeAdded]) { ... for ev := range bus.Subscribe(ctx) { ... } }
+//
+// Each event is delivered to every subscriber in the order published.
+type EventBus[T any] struct {
+	opts EventBusOptions
+
+	// pubMu serializes the publishers for delivering the events in the
+	// same order to every subscriber.
+	pubMu sync.Mutex
+
+	mu      sync.Mutex
+	subs    []*eventSubscriber[T]
+	dropped int
+}
+
+// EventBusOptions are the options for an EventBus.
+type EventBusOptions struct {
+	// BufferSize is the number of events buffered for each subscriber.
+	BufferSize int
+
+	// DropSlow if true drops the events for a subscriber whose buffer is
+	// full. Otherwise Publish blocks until every subscriber has received the
+	// event or unsubscribed, and a slow subscriber slows down the publishers.
+	DropSlow bool
+}
+
+// NewEventBus returns a new event bus.
+func NewEventBus[T any](opts EventBusOptions) *EventBus[T] {
+	return &EventBus[T]{opts: opts}
+}
+
+// ProvideEventBus constructs a cell that provides a *EventBus[T].
+func ProvideEventBus[T any](opts EventBusOptions) Cell {
+	return Provide(func() *EventBus[T] { return NewEventBus[T](opts) })
+}
+
+type eventSubscriber[T any] struct {
+	ch   chan T
+	done <-chan struct{}
+
+	// mu is held while sending to ch for not closing it concurrently.
+	mu     sync.Mutex
+	closed bool
+}
+
+// send sends the event to the subscriber and returns false if it was
+// dropped.
+func (s *eventSubscriber[T]) send(ev T, dropSlow bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	if dropSlow {
+		select {
+		case s.ch <- ev:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case s.ch <- ev:
+	case <-s.done:
+	}
+	return true
+}
+
+// Publish delivers the event to the current subscribers. The subscribers
+// are not locked out of subscribing or unsubscribing while the event is
+// being delivered.
+func (b *EventBus[T]) Publish(ev T) {
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+
+	b.mu.Lock()
+	subs := slices.Clone(b.subs)
+	b.mu.Unlock()
+
+	dropped := 0
+	for _, sub := range subs {
+		if !sub.send(ev, b.opts.DropSlow) {
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		b.mu.Lock()
+		b.dropped += dropped
+		b.mu.Unlock()
+	}
+}
+
+// Subscribe returns a channel for the events published after the call. The
+// channel is closed when the context is cancelled.
+func (b *EventBus[T]) Subscribe(ctx context.Context) <-chan T {
+	sub := &eventSubscriber[T]{
+		ch:   make(chan T, b.opts.BufferSize),
+		done: ctx.Done(),
+	}
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		b.subs = slices.DeleteFunc(b.subs, func(s *eventSubscriber[T]) bool { return s == sub })
+		b.mu.Unlock()
+
+		// A blocked send returns as the context is done.
+		sub.mu.Lock()
+		defer sub.mu.Unlock()
+		sub.closed = true
+		close(sub.ch)
+	}()
+	return sub.ch
+}
+
+// Dropped returns the number of events dropped for the slow subscribers
+// with EventBusOptions.DropSlow.
+func (b *EventBus[T]) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive/cell"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := cell.NewEventBus[int](cell.EventBusOptions{})
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	sub1, sub2 := bus.Subscribe(ctx1), bus.Subscribe(ctx2)
+
+	received := make(chan []int)
+	receive := func(sub <-chan int, n int) {
+		var evs []int
+		for ev := range sub {
+			evs = append(evs, ev)
+			if len(evs) == n {
+				break
+			}
+		}
+		received <- evs
+	}
+	go receive(sub1, 2)
+	go receive(sub2, 2)
+	bus.Publish(1)
+	bus.Publish(2)
+	assert.Equal(t, []int{1, 2}, <-received)
+	assert.Equal(t, []int{1, 2}, <-received)
+
+	// Cancelling the context unsubscribes and closes the channel. Publish
+	// does not block on the unsubscribed subscriber.
+	cancel1()
+	for range sub1 {
+	}
+	go receive(sub2, 1)
+	bus.Publish(3)
+	assert.Equal(t, []int{3}, <-received)
+}
+
+func TestEventBusDropSlow(t *testing.T) {
+	bus := cell.NewEventBus[int](cell.EventBusOptions{BufferSize: 1, DropSlow: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := bus.Subscribe(ctx)
+
+	bus.Publish(1)
+	bus.Publish(2)
+	bus.Publish(3)
+	assert.Equal(t, 2, bus.Dropped())
+	require.Equal(t, 1, <-sub)
+
+	cancel()
+	_, ok := <-sub
+	assert.False(t, ok, "expected the channel to be closed")
+}
+
+func TestEventBusSubscribeWhilePublishing(t *testing.T) {
+	bus := cell.NewEventBus[int](cell.EventBusOptions{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slow := bus.Subscribe(ctx)
+
+	// Publish blocks on the slow subscriber, which does not block
+	// subscribing, unsubscribing or the reading of the dropped count.
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(1)
+		close(published)
+	}()
+
+	subscribed := make(chan struct{})
+	go func() {
+		subCtx, subCancel := context.WithCancel(context.Background())
+		bus.Subscribe(subCtx)
+		subCancel()
+		bus.Dropped()
+		close(subscribed)
+	}()
+	select {
+	case <-subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe blocked by Publish")
+	}
+
+	assert.Equal(t, 1, <-slow)
+	<-published
+}
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+// subscriberCount returns the number of subscribers.
+func (h *SimpleHealth) subscriberCount() int {
+	h.Lock()
+	defer h.Unlock()
+	return len(h.subs)
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// flagModuleAnnotation is the annotation of the flags registered within a
+// module. The values are the full module ID and the module description.
+const flagModuleAnnotation = "hive-module"
+
+// flagSourceAnnotation is the annotation of the flags with the description
+// of the cell that registered them, e.g. "config foo.Config".
+const flagSourceAnnotation = "hive-source"
+
+// FlagModule returns the full ID and the description of the module in which
+// the flag was registered by a config cell, or empty strings if the flag was
+// not registered within a module.
+func FlagModule(f *pflag.Flag) (id, description string) {
+	if vs := f.Annotations[flagModuleAnnotation]; len(vs) == 2 {
+		return vs[0], vs[1]
+	}
+	return "", ""
+}
+
+// moduleDescription is the description of the module. Provided in the
+// module's scope.
+type moduleDescription string
+
+type flagModuleParams struct {
+	In
+	ID          FullModuleID      `optional:"true"`
+	Description moduleDescription `optional:"true"`
+}
+
+// annotateFlags annotates the flags with the module the container is for.
+func annotateFlags(c Container, flags *pflag.FlagSet) error {
+	return c.Invoke(func(p flagModuleParams) {
+		if len(p.ID) == 0 {
+			return
+		}
+		flags.VisitAll(func(f *pflag.Flag) {
+			flags.SetAnnotation(f.Name, flagModuleAnnotation, []string{p.ID.String(), string(p.Description)})
+		})
+	})
+}
+
+// registerFlags annotates the flags with the module the container is for and
+// with the source that registers them, and adds them to the flags of the
+// hive. Fails with an error naming both sources if a flag, or its shorthand,
+// has already been registered, before the flags are parsed.
+func registerFlags(c Container, source string, flags *pflag.FlagSet) error {
+	if err := annotateFlags(c, flags); err != nil {
+		return err
+	}
+	flags.VisitAll(func(f *pflag.Flag) {
+		flags.SetAnnotation(f.Name, flagSourceAnnotation, []string{source})
+	})
+	var err error
+	invokeErr := c.Invoke(func(allFlags *pflag.FlagSet) {
+		flags.VisitAll(func(f *pflag.Flag) {
+			if err != nil {
+				return
+			}
+			if other := allFlags.Lookup(f.Name); other != nil {
+				err = fmt.Errorf("flag %q is registered by both %s and %s", f.Name, describeFlagSource(other), describeFlagSource(f))
+			} else if other := allFlags.ShorthandLookup(f.Shorthand); f.Shorthand != "" && other != nil {
+				err = fmt.Errorf("shorthand %q of flag %q is registered by both %s (flag %q) and %s",
+					f.Shorthand, f.Name, describeFlagSource(other), other.Name, describeFlagSource(f))
+			}
+		})
+		if err == nil {
+			allFlags.AddFlagSet(flags)
+		}
+	})
+	if invokeErr != nil {
+		return invokeErr
+	}
+	return err
+}
+
+// describeFlagSource describes the cell that registered the flag and the
+// module it is in, e.g. "config foo.Config in module bar".
+func describeFlagSource(f *pflag.Flag) string {
+	source := "unknown"
+	if vs := f.Annotations[flagSourceAnnotation]; len(vs) == 1 {
+		source = vs[0]
+	}
+	if id, _ := FlagModule(f); id != "" {
+		source += " in module " + id
+	}
+	return source
+}
@@ -4,6 +4,7 @@
 package cell
 
 import (
+	"errors"
 	"log/slog"
 	"time"
 )
@@ -11,21 +12,29 @@ import (
 type group []Cell
 
 // Group a set of cells. Unlike Module(), Group() does not create a new
-// scope.
+// scope. The cells are applied in order as if they were given directly to
+// the enclosing module, so objects provided with ProvidePrivate in a group
+// are visible to the sibling cells of the group in the module. Groups are
+// not shown with an identifier in the object dump and do not affect the
+// module ID, logger or flag prefix of the cells.
+//
+// Use Group to bundle related cells together for organization and Module to
+// introduce a boundary for private objects.
 func Group(cells ...Cell) Cell {
 	return group(cells)
 }
 
-func (g group) Apply(log *slog.Logger, c container, logThreshold time.Duration) error {
+func (g group) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	var errs []error
 	for _, cell := range g {
 		if err := cell.Apply(log, c, logThreshold); err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func (g group) Info(c container) Info {
+func (g group) Info(c Container) Info {
 	n := NewInfoNode("")
 	for _, cell := range g {
 		n.Add(cell.Info(c))
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestGroup(t *testing.T) {
+	sum := 0
+
+	testCell := cell.Group(
+		cell.Provide(func() *SomeObject { return &SomeObject{10} }),
+		cell.Provide(func() *OtherObject { return &OtherObject{5} }),
+	)
+	err := hive.New(
+		testCell,
+		cell.Invoke(func(a *SomeObject, b *OtherObject) { sum = a.X + b.Y }),
+		shutdownOnStartCell,
+	).Run()
+	assert.NoError(t, err, "expected Run to succeed")
+	assert.Equal(t, 15, sum)
+}
+
+func TestGroupPrivate(t *testing.T) {
+	var obj *SomeObject
+	h := hive.New(
+		cell.Module("test", "Test",
+			cell.Group(
+				cell.ProvidePrivate(func() *SomeObject { return &SomeObject{10} }),
+			),
+			// The group does not create a scope, so the private object is
+			// visible to the sibling cells in the module.
+			cell.Invoke(func(o *SomeObject) { obj = o }),
+		),
+	)
+	require.NoError(t, h.Populate(), "Populate")
+	assert.Equal(t, 10, obj.X)
+
+	// Outside the module the private object is not visible.
+	h = hive.New(
+		cell.Module("test", "Test",
+			cell.Group(
+				cell.ProvidePrivate(func() *SomeObject { return &SomeObject{10} }),
+			),
+		),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	assert.ErrorContains(t, h.Populate(), "missing type: *cell_test.SomeObject")
+}
@@ -3,6 +3,8 @@
 
 package cell
 
+import "encoding/json"
+
 // Level denotes what kind an update is.
 type Level string
 
@@ -26,9 +28,11 @@ const (
 // Health provides a method of declaring a Modules health status.
 //
 // The interface is meant to be used with "ModuleDecorator" to inject it into
-// the scope of modules.
+// the scope of modules, see HealthModuleDecorator.
 //
-// Implementation for health reporting is not included with the Hive library.
+// The Hive library only includes the simple implementation SimpleHealth.
+// Implementations can implement HealthStatuser to expose the statuses with
+// Hive.Health().
 type Health interface {
 	// OK declares that a Module has achieved a desired state and has not entered
 	// any unexpected or incorrect states.
@@ -56,3 +60,93 @@ type Health interface {
 	// 'Stopped' in that after closing the health status will disappear completely.
 	Close()
 }
+
+// HealthStatus is the status of a health scope and of the scopes created
+// from it.
+type HealthStatus struct {
+	// Scope is the full name of the scope, e.g. "agent.controlplane.job-foo".
+	Scope string
+
+	// Level is the level of the scope rolled up from its children: the worst
+	// of its own level and the levels of its children, see Cause.
+	Level Level
+
+	// Cause is the full name of the scope the rolled up level comes from,
+	// e.g. "agent.controlplane.job-foo" for a degraded job in module
+	// "controlplane". Empty if the level is the scope's own.
+	Cause string
+
+	// Message is the status or reason last reported to the scope.
+	Message string
+
+	// Error is the error given with Degraded.
+	Error error
+
+	// Children are the statuses of the scopes created from this scope.
+	Children []HealthStatus
+}
+
+// MarshalJSON encodes the status as JSON with the error as a string.
+func (s HealthStatus) MarshalJSON() ([]byte, error) {
+	var errString string
+	if s.Error != nil {
+		errString = s.Error.Error()
+	}
+	return json.Marshal(struct {
+		Scope    string         `json:"scope"`
+		Level    Level          `json:"level"`
+		Cause    string         `json:"cause,omitempty"`
+		Message  string         `json:"message,omitempty"`
+		Error    string         `json:"error,omitempty"`
+		Children []HealthStatus `json:"children,omitempty"`
+	}{s.Scope, s.Level, s.Cause, s.Message, errString, s.Children})
+}
+
+// levelSeverity orders the levels for rolling them up, from the least to
+// the most severe.
+var levelSeverity = map[Level]int{
+	StatusStopped:  1,
+	StatusOK:       2,
+	StatusUnknown:  3,
+	StatusDegraded: 4,
+}
+
+// rollUp sets the level of the scope to the worst of its own level and the
+// already rolled up levels of its children, and the cause to the scope the
+// level comes from. Stopped is terminal: a stopped scope stays stopped. The
+// unknown level of a scope with children is not taken into account, as it
+// is e.g. the scope of a module that does not report a status of its own.
+func (s *HealthStatus) rollUp() {
+	if s.Level == StatusStopped {
+		return
+	}
+	level := s.Level
+	if level == StatusUnknown && len(s.Children) > 0 {
+		level = ""
+	}
+	cause := ""
+	for _, child := range s.Children {
+		if levelSeverity[child.Level] > levelSeverity[level] {
+			level = child.Level
+			cause = child.Cause
+			if cause == "" {
+				cause = child.Scope
+			}
+		}
+	}
+	s.Level, s.Cause = level, cause
+}
+
+// HealthStatuser is optionally implemented by Health to expose the tree of
+// statuses, e.g. for Hive.Health().
+type HealthStatuser interface {
+	// Statuses returns the statuses of the scopes created from this one.
+	Statuses() []HealthStatus
+}
+
+// HealthModuleDecorator is a ModuleDecorator that scopes Health to the
+// module, so that the statuses reported within a module roll up under the
+// module ID. Supplied with [hive.Options] field 'ModuleDecorators'.
+func HealthModuleDecorator(h Health, id ModuleID) Health {
+	return h.NewScope(string(id))
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestHealth(t *testing.T) {
+	opts := hive.DefaultOptions()
+	opts.ModuleDecorators = cell.ModuleDecorators{cell.HealthModuleDecorator}
+	h := hive.NewWithOptions(opts,
+		cell.SimpleHealthCell,
+		cell.Module("outer", "Outer",
+			cell.Invoke(func(h cell.Health) { h.OK("Outer ready") }),
+			cell.Module("inner", "Inner",
+				cell.Invoke(func(h cell.Health) {
+					h.NewScope("component").Degraded("Connection lost", errors.New("timeout"))
+				}),
+			),
+		),
+		cell.Module("other", "Other",
+			cell.Invoke(func(h cell.Health) { h.OK("Other ready") }),
+		),
+	)
+
+	statuses, err := h.Health()
+	require.NoError(t, err, "Health")
+	require.Len(t, statuses, 2)
+
+	outer := statuses[0]
+	assert.Equal(t, "outer", outer.Scope)
+	assert.Equal(t, cell.StatusDegraded, outer.Level, "expected degraded component to roll up")
+	assert.Equal(t, "outer.inner.component", outer.Cause)
+	assert.Equal(t, "Outer ready", outer.Message)
+	require.Len(t, outer.Children, 1)
+
+	inner := outer.Children[0]
+	assert.Equal(t, "outer.inner", inner.Scope)
+	assert.Equal(t, cell.StatusDegraded, inner.Level)
+	require.Len(t, inner.Children, 1)
+
+	component := inner.Children[0]
+	assert.Equal(t, "outer.inner.component", component.Scope)
+	assert.Equal(t, cell.StatusDegraded, component.Level)
+	assert.Equal(t, "Connection lost", component.Message)
+	assert.EqualError(t, component.Error, "timeout")
+
+	other := statuses[1]
+	assert.Equal(t, "other", other.Scope)
+	assert.Equal(t, cell.StatusOK, other.Level)
+	assert.Empty(t, other.Children)
+}
@@ -5,13 +5,21 @@ package cell
 
 import (
 	"bufio"
+	"encoding"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 	"golang.org/x/term"
+
+	"github.com/cilium/hive/internal"
 )
 
 const (
@@ -22,19 +30,61 @@ const (
 type InfoPrinter struct {
 	io.Writer
 	width int
+
+	// ASCII if true prints the glyphs in plain ASCII, e.g. "[ctor]" instead
+	// of "🚧", for terminals and logs that render the emojis poorly.
+	ASCII bool
+
+	// Health if not nil are the rolled up health statuses by the full IDs
+	// of the modules, e.g. "foo.bar". The level of each module with a
+	// status is printed next to it.
+	Health map[string]HealthStatus
+
+	// modules are the IDs of the modules being printed.
+	modules []string
 }
 
+// ASCIIInfoEnv is the environment variable that when set to true makes
+// NewInfoPrinter print the glyphs in plain ASCII.
+const ASCIIInfoEnv = "HIVE_ASCII_INFO"
+
 func NewInfoPrinter() *InfoPrinter {
 	width, _, err := term.GetSize(int(os.Stdout.Fd()))
 	if err != nil {
 		width = 120
 	}
+	ascii, _ := strconv.ParseBool(os.Getenv(ASCIIInfoEnv))
 	return &InfoPrinter{
 		Writer: os.Stdout,
 		width:  width,
+		ASCII:  ascii,
 	}
 }
 
+// asciiGlyphs replaces the glyphs with their plain ASCII counterparts. The
+// glyphs with a variation selector are listed before the ones without.
+var asciiGlyphs = strings.NewReplacer(
+	"🔒️", "[private]",
+	"🛠️", "[invoke]",
+	"Ⓜ️", "[module]",
+	"⚙️", "[config]",
+	"🚧", "[ctor]",
+	"🔁", "[replace]",
+	"🔀", "[decorator]",
+	"📦", "[supply]",
+	"🛸", "[sub-hive]",
+	"⇨", "<-",
+	"⇦", "->",
+)
+
+// glyphs returns s with the glyphs in plain ASCII if ASCII is set.
+func (w *InfoPrinter) glyphs(s string) string {
+	if w.ASCII {
+		return asciiGlyphs.Replace(s)
+	}
+	return s
+}
+
 // Info provides a simple way of printing cells hierarchically in
 // textual form.
 type Info interface {
@@ -49,7 +99,7 @@ func (l InfoLeaf) Print(indent int, w *InfoPrinter) {
 	buf.WriteString(indentString)
 	currentLineLength := len(indentString)
 	wrapped := false
-	for _, f := range strings.Fields(string(l)) {
+	for _, f := range strings.Fields(w.glyphs(string(l))) {
 		newLineLength := currentLineLength + len(f) + 1
 		if newLineLength >= w.width {
 			buf.WriteByte('\n')
@@ -78,11 +128,77 @@ type InfoNode struct {
 	header    string
 	condensed bool
 
+	// message is the header without the glyph. Used in the JSON
+	// encoding.
+	message string
+
+	// module is the structured information about the module if this
+	// node describes one.
+	module *ModuleInfo
+
+	// provider is the structured information about the constructor
+	// if this node describes one.
+	provider *ProviderInfo
+
+	// invoke is the structured information about the invoke function
+	// if this node describes one.
+	invoke *InvokeInfo
+
+	// replacement is the structured information about the replacement
+	// constructor (ReplaceProvide) if this node describes one.
+	replacement *ProviderInfo
+
+	// decorator is the structured information about the decorator
+	// (Decorate) if this node describes one.
+	decorator *InvokeInfo
+
 	children []Info
 }
 
 func NewInfoNode(header string) *InfoNode {
-	return &InfoNode{header: header}
+	return &InfoNode{header: header, message: header}
+}
+
+// newInfoNode constructs a node with a header consisting of a glyph and
+// the message.
+func newInfoNode(glyph, message string) *InfoNode {
+	return &InfoNode{header: glyph + " " + message, message: message}
+}
+
+// Children returns the child nodes.
+func (n *InfoNode) Children() []Info {
+	return n.children
+}
+
+// Provider returns the structured information about the constructor if
+// this node describes one, otherwise nil.
+func (n *InfoNode) Provider() *ProviderInfo {
+	return n.provider
+}
+
+// Invoke returns the structured information about the invoke function if
+// this node describes one, otherwise nil.
+func (n *InfoNode) Invoke() *InvokeInfo {
+	return n.invoke
+}
+
+// Module returns the structured information about the module if this
+// node describes one, otherwise nil.
+func (n *InfoNode) Module() *ModuleInfo {
+	return n.module
+}
+
+// Replacement returns the structured information about the constructor
+// given to ReplaceProvide if this node describes one, otherwise nil.
+func (n *InfoNode) Replacement() *ProviderInfo {
+	return n.replacement
+}
+
+// Decorator returns the structured information about the decorator given
+// to Decorate if this node describes one, otherwise nil. Only the name and
+// the inputs of the decorator are included.
+func (n *InfoNode) Decorator() *InvokeInfo {
+	return n.decorator
 }
 
 func (n *InfoNode) Add(child Info) {
@@ -93,9 +209,51 @@ func (n *InfoNode) AddLeaf(format string, args ...any) {
 	n.Add(InfoLeaf(fmt.Sprintf(format, args...)))
 }
 
+// MarshalJSON encodes the node and its children as JSON. Instead of the
+// glyphs and leaves used when printing, the structured information about
+// modules, constructors and invoke functions is included.
+func (n *InfoNode) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Message     string        `json:"message,omitempty"`
+		Condensed   bool          `json:"condensed,omitempty"`
+		Module      *ModuleInfo   `json:"module,omitempty"`
+		Provider    *ProviderInfo `json:"provider,omitempty"`
+		Replacement *ProviderInfo `json:"replacement,omitempty"`
+		Invoke      *InvokeInfo   `json:"invoke,omitempty"`
+		Decorator   *InvokeInfo   `json:"decorator,omitempty"`
+		Children    []Info        `json:"children,omitempty"`
+	}{
+		Message:     n.message,
+		Condensed:   n.condensed,
+		Module:      n.module,
+		Provider:    n.provider,
+		Replacement: n.replacement,
+		Invoke:      n.invoke,
+		Decorator:   n.decorator,
+	}
+	if n.provider == nil && n.replacement == nil && n.invoke == nil {
+		// The children of the constructor and invoke nodes are leaves
+		// describing the inputs and outputs and are thus left out.
+		for _, child := range n.children {
+			if child != nil {
+				out.Children = append(out.Children, child)
+			}
+		}
+	}
+	return json.Marshal(out)
+}
+
 func (n *InfoNode) Print(indent int, w *InfoPrinter) {
+	if n.module != nil {
+		w.modules = append(w.modules, n.module.ID)
+		defer func() { w.modules = w.modules[:len(w.modules)-1] }()
+	}
 	if n.header != "" {
-		fmt.Fprintf(w, "%s%s:\n", strings.Repeat(" ", indent), n.header)
+		header := w.glyphs(n.header)
+		if n.module != nil {
+			header += w.moduleHealth()
+		}
+		fmt.Fprintf(w, "%s%s:\n", strings.Repeat(" ", indent), header)
 		indent += indentBy
 	}
 
@@ -107,8 +265,302 @@ func (n *InfoNode) Print(indent int, w *InfoPrinter) {
 	}
 }
 
+// moduleHealth returns the annotation of the health of the module being
+// printed, e.g. " [health: Degraded, cause foo.bar.job-baz]", or an empty
+// string if it has no status.
+func (w *InfoPrinter) moduleHealth() string {
+	s, ok := w.Health[strings.Join(w.modules, ".")]
+	if !ok {
+		return ""
+	}
+	if s.Cause != "" {
+		return fmt.Sprintf(" [health: %s, cause %s]", s.Level, s.Cause)
+	}
+	return fmt.Sprintf(" [health: %s]", s.Level)
+}
+
+// ModuleInfo is the structured information about a module
+// for consumption by tooling.
+type ModuleInfo struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+// ProviderInfo is the structured information about a constructor
+// for consumption by tooling.
+type ProviderInfo struct {
+	// Name is the function name and location of the constructor.
+	Name string `json:"name"`
+
+	// Exported is false if the constructor was provided with ProvidePrivate.
+	Exported bool `json:"exported"`
+
+	// Eager is true if the constructor was provided with ProvideEager and
+	// is thus called even if nothing depends on its outputs.
+	Eager bool `json:"eager,omitempty"`
+
+	// Inputs and Outputs of the constructor sorted by their string form.
+	Inputs  []InfoValue `json:"inputs,omitempty"`
+	Outputs []InfoValue `json:"outputs,omitempty"`
+
+	inputTypes
+}
+
+// InvokeInfo is the structured information about an invoke function
+// for consumption by tooling.
+type InvokeInfo struct {
+	// Name is the function name and location of the invoke function.
+	Name string `json:"name"`
+
+	// Inputs of the invoke function sorted by their string form.
+	Inputs []InfoValue `json:"inputs,omitempty"`
+
+	inputTypes
+}
+
+// inputTypes maps the inputs to their Go types.
+type inputTypes map[InfoValue]reflect.Type
+
+// InputType returns the Go type of the input, or nil if the input is
+// unknown. For value group inputs this is the slice type.
+func (t inputTypes) InputType(in InfoValue) reflect.Type {
+	return t[in]
+}
+
+// add appends the input and records its type.
+func (t *inputTypes) add(inputs *[]InfoValue, dv internal.DigValue) {
+	v := newInfoValue(dv)
+	if *t == nil {
+		*t = inputTypes{}
+	}
+	(*t)[v] = dv.Type
+	*inputs = append(*inputs, v)
+}
+
+// InfoValue describes an input or an output of a constructor.
+type InfoValue struct {
+	// Type of the value, e.g. "*foo.Bar". For value group inputs this
+	// is the slice type.
+	Type string `json:"type"`
+
+	// Name is the name of the value (`name:"..."`), if any.
+	Name string `json:"name,omitempty"`
+
+	// Group is the value group (`group:"..."`), if any.
+	Group string `json:"group,omitempty"`
+
+	// Optional is true for inputs tagged with `optional:"true"`.
+	Optional bool `json:"optional,omitempty"`
+}
+
+func newInfoValue(dv internal.DigValue) InfoValue {
+	return InfoValue{Type: dv.TypeName, Name: dv.Name, Group: dv.Group, Optional: dv.Optional}
+}
+
+// String returns the value in the same form as dig, e.g.
+// `[]foo.Bar[optional, group = "bars"]`.
+func (v InfoValue) String() string {
+	var toks []string
+	if v.Optional {
+		toks = append(toks, "optional")
+	}
+	if v.Name != "" {
+		toks = append(toks, fmt.Sprintf("name = %q", v.Name))
+	}
+	if v.Group != "" {
+		toks = append(toks, fmt.Sprintf("group = %q", v.Group))
+	}
+	if len(toks) == 0 {
+		return v.Type
+	}
+	return fmt.Sprintf("%s[%s]", v.Type, strings.Join(toks, ", "))
+}
+
+func sortInfoValues(vs []InfoValue) {
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i].String() < vs[j].String()
+	})
+}
+
 type InfoStruct struct {
-	value any
+	value  any
+	fields []ConfigField
+}
+
+// Value returns the configuration struct.
+func (n *InfoStruct) Value() any {
+	return n.value
+}
+
+// ConfigFields returns the fields of the configuration struct and the
+// flags they were populated from.
+func (n *InfoStruct) ConfigFields() []ConfigField {
+	return n.fields
+}
+
+// MarshalJSON encodes the type and the value of the struct as JSON. The
+// values of the fields tagged with `sensitive:"true"` are redacted, also in
+// nested structs.
+func (n *InfoStruct) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string `json:"type"`
+		Value any    `json:"value"`
+	}{internal.PrettyType(n.value), redact(reflect.ValueOf(n.value))})
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// redact returns the value to encode as JSON in place of v, in which the
+// values of the fields tagged with `sensitive:"true"` are "<redacted>". The
+// structs with such fields, directly or in nested structs, are turned into
+// maps keyed by the JSON names of their fields. Other values are encoded by
+// encoding/json as is.
+func redact(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	}
+	if !hasSensitiveFields(v.Type(), map[reflect.Type]bool{}) || marshalsItself(v) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		elems := make([]any, v.Len())
+		for i := range elems {
+			elems[i] = redact(v.Index(i))
+		}
+		return elems
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = redact(iter.Value())
+		}
+		return m
+	case reflect.Struct:
+		m := map[string]any{}
+		redactFields(v, m)
+		return m
+	}
+	return v.Interface()
+}
+
+// redactFields adds the fields of the struct to m in the same way as
+// encoding/json encodes them: by the name in the json tag or by the field
+// name, without the unexported fields and with the fields of the embedded
+// structs inlined unless shadowed by a field of the outer struct. The
+// fields tagged with `sensitive:"true"` are "<redacted>".
+func redactFields(v reflect.Value, m map[string]any) {
+	var embedded []reflect.Value
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		fv := v.Field(i)
+		if name == "" && f.Anonymous {
+			if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct {
+				if !fv.IsNil() {
+					embedded = append(embedded, fv.Elem())
+				}
+				continue
+			}
+			if fv.Kind() == reflect.Struct {
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		switch {
+		case f.Tag.Get("sensitive") == "true":
+			m[name] = "<redacted>"
+		case slices.Contains(strings.Split(opts, ","), "omitempty") && isEmptyValue(fv):
+		default:
+			m[name] = redact(fv)
+		}
+	}
+	for _, ev := range embedded {
+		inlined := map[string]any{}
+		redactFields(ev, inlined)
+		for name, value := range inlined {
+			if _, ok := m[name]; !ok {
+				m[name] = value
+			}
+		}
+	}
+}
+
+// hasSensitiveFields returns true if the type is or refers to a struct with
+// a field tagged with `sensitive:"true"`.
+func hasSensitiveFields(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return hasSensitiveFields(typ.Elem(), seen)
+	case reflect.Struct:
+		if seen[typ] {
+			return false
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.Tag.Get("sensitive") == "true" || hasSensitiveFields(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// marshalsItself returns true if encoding/json encodes the value with its
+// MarshalJSON or MarshalText method.
+func marshalsItself(v reflect.Value) bool {
+	typ := v.Type()
+	if typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType) {
+		return true
+	}
+	ptr := reflect.PointerTo(typ)
+	return v.CanAddr() && (ptr.Implements(jsonMarshalerType) || ptr.Implements(textMarshalerType))
+}
+
+// isEmptyValue returns true if the value is omitted by encoding/json with
+// the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
 }
 
 func (n *InfoStruct) Print(indent int, w *InfoPrinter) {
@@ -116,7 +568,7 @@ func (n *InfoStruct) Print(indent int, w *InfoPrinter) {
 	indentString := strings.Repeat(" ", indent)
 	for i, line := range strings.Split(scs.Sdump(n.value), "\n") {
 		if i == 0 {
-			fmt.Fprintf(w, "%s⚙️ %s\n", indentString, line)
+			fmt.Fprintf(w, "%s%s %s\n", indentString, w.glyphs("⚙️"), line)
 		} else {
 			fmt.Fprintf(w, "%s%s\n", indentString, line)
 		}
@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+type A struct{}
+type B struct{}
+type C struct{}
+
+type bParams struct {
+	cell.In
+
+	A     *A
+	Named *A   `name:"named"`
+	Maybe *C   `optional:"true"`
+	As    []*A `group:"as"`
+}
+
+type bOut struct {
+	cell.Out
+
+	B  *B
+	Cs *C `group:"cs"`
+}
+
+func newB(bParams) bOut { return bOut{} }
+
+func TestProviderInfo(t *testing.T) {
+	c := cell.ProvidePrivate(newB)
+	hive.New(c)
+
+	n, ok := c.Info(nil).(*cell.InfoNode)
+	require.True(t, ok, "expected *InfoNode")
+	require.Len(t, n.Children(), 1)
+
+	info := n.Children()[0].(*cell.InfoNode).Provider()
+	require.NotNil(t, info)
+	assert.Contains(t, info.Name, "cell_test.newB")
+	assert.False(t, info.Exported)
+	assert.Equal(t,
+		[]cell.InfoValue{
+			{Type: "*cell_test.A"},
+			{Type: "*cell_test.A", Name: "named"},
+			{Type: "*cell_test.C", Optional: true},
+			{Type: "[]*cell_test.A", Group: "as"},
+		},
+		info.Inputs)
+	assert.Equal(t,
+		[]cell.InfoValue{
+			{Type: "*cell_test.B"},
+			{Type: "*cell_test.C", Group: "cs"},
+		},
+		info.Outputs)
+
+	assert.Equal(t, `*cell_test.C[optional]`, info.Inputs[2].String())
+	assert.Equal(t, `[]*cell_test.A[group = "as"]`, info.Inputs[3].String())
+}
+
+func BenchmarkInfo(b *testing.B) {
+	var cells []cell.Cell
+	for i := 0; i < 500; i++ {
+		i := i
+		cells = append(cells, cell.ProvideNamed(fmt.Sprintf("n%d", i), func() int { return i }))
+	}
+	group := cell.Group(cells...)
+	hive.New(group)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		group.Info(nil)
+	}
+}
+
+// BenchmarkProviderInfo measures the repeated building of the Info of a
+// provider, which is cached after the provider has been applied.
+func BenchmarkProviderInfo(b *testing.B) {
+	p := cell.Provide(newA, newC)
+	hive.New(p)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Info(nil)
+	}
+}
+
+func TestProviderInfoCache(t *testing.T) {
+	render := func(c cell.Cell) string {
+		var buf bytes.Buffer
+		c.Info(nil).Print(0, &cell.InfoPrinter{Writer: &buf})
+		return buf.String()
+	}
+	p := cell.Provide(newA)
+	assert.NotContains(t, render(p), "*cell_test.A", "expected no outputs before the provider is applied")
+
+	hive.New(p)
+	info := render(p)
+	assert.Contains(t, info, "*cell_test.A")
+
+	// Applying the shared provider to another hive gives the same Info.
+	hive.New(p)
+	assert.Equal(t, info, render(p))
+}
+
+func newA() *A { return &A{} }
+func newC() *C { return &C{} }
+
+func TestInfoDeterministicOrder(t *testing.T) {
+	render := func(ctors ...any) string {
+		c := cell.Provide(ctors...)
+		hive.New(c)
+		var buf bytes.Buffer
+		c.Info(nil).Print(0, &cell.InfoPrinter{Writer: &buf})
+		return buf.String()
+	}
+
+	expected := render(newA, newB, newC)
+	assert.Equal(t, expected, render(newC, newA, newB))
+	assert.Equal(t, expected, render(newB, newC, newA))
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+// locationRegex matches the source location of a function, e.g.
+// " (cell/info_test.go:42)", which is removed to keep the golden file stable.
+var locationRegex = regexp.MustCompile(` \([^()]*\.go:\d+\)`)
+
+func TestInfoJSON(t *testing.T) {
+	mod := cell.Module(
+		"test",
+		"Test module",
+
+		cell.Provide(newA),
+		cell.ProvidePrivate(newB),
+		cell.ProvideNamed("named", newA),
+		cell.ProvideGroup("as", newA),
+		cell.Invoke(func(*B) {}),
+	)
+	require.NoError(t, hive.New(mod).Populate())
+
+	data, err := json.MarshalIndent(mod.Info(nil), "", "  ")
+	require.NoError(t, err)
+	data = append(locationRegex.ReplaceAll(data, nil), '\n')
+
+	assert.NotContains(t, string(data), "🚧")
+	assert.NotContains(t, string(data), "🔒️")
+	assert.NotContains(t, string(data), "⇨")
+	assert.NotContains(t, string(data), "⇦")
+
+	golden := "testdata/info.json"
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, data, 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+
+	// Check that the JSON round-trips.
+	var decoded any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	data2, err := json.MarshalIndent(decoded, "", "  ")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(data2))
+}
+
+type (
+	I0 struct{}
+	I1 struct{}
+	I2 struct{}
+	I3 struct{}
+	I4 struct{}
+	I5 struct{}
+	I6 struct{}
+	I7 struct{}
+	I8 struct{}
+	I9 struct{}
+)
+
+func newWide(*I0, *I1, *I2, *I3, *I4, *I5, *I6, *I7, *I8, *I9) (*A, *B) {
+	return &A{}, &B{}
+}
+
+func TestInfoWide(t *testing.T) {
+	c := cell.Group(
+		cell.Provide(newWide),
+		cell.Provide(newC),
+	)
+	hive.New(c)
+
+	var buf bytes.Buffer
+	ip := cell.NewInfoPrinter()
+	ip.Writer = &buf
+	c.Info(nil).Print(0, ip)
+	data := locationRegex.ReplaceAll(buf.Bytes(), nil)
+
+	golden := "testdata/info_wide.txt"
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, data, 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+}
+
+type GlyphsConfig struct {
+	Enabled bool
+}
+
+func (def GlyphsConfig) Flags(flags *pflag.FlagSet) {
+	flags.Bool("glyphs-enabled", def.Enabled, "Enable glyphs")
+}
+
+func TestInfoASCII(t *testing.T) {
+	mod := cell.Module(
+		"test",
+		"Test module",
+
+		cell.Config(GlyphsConfig{}),
+		cell.Provide(newA),
+		cell.ProvidePrivate(newC),
+		cell.Supply(&I0{}),
+		cell.Invoke(func(*A, *C, *I0) {}),
+	)
+	h := hive.New(mod)
+	require.NoError(t, h.Populate())
+
+	for _, ascii := range []bool{false, true} {
+		var buf bytes.Buffer
+		ip := cell.NewInfoPrinter()
+		ip.Writer = &buf
+		ip.ASCII = ascii
+		mod.Info(h.Container()).Print(0, ip)
+		data := locationRegex.ReplaceAll(buf.Bytes(), nil)
+
+		golden := "testdata/info_glyphs.txt"
+		if ascii {
+			golden = "testdata/info_glyphs_ascii.txt"
+			assert.Regexp(t, `^[[:ascii:]]*$`, string(data))
+		}
+		if *updateGolden {
+			require.NoError(t, os.WriteFile(golden, data, 0644))
+		}
+		expected, err := os.ReadFile(golden)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(data))
+	}
+}
+
+type CredentialsConfig struct {
+	User     string
+	Password string `sensitive:"true"`
+}
+
+type SecretsConfig struct {
+	Token   string            `json:"token" sensitive:"true"`
+	Key     string            `json:"key,omitempty" sensitive:"true"`
+	Primary CredentialsConfig `json:"primary"`
+	Backups []*CredentialsConfig
+	Shadow  map[string]CredentialsConfig `json:",omitempty"`
+	CredentialsConfig
+	Comment string `json:"-"`
+}
+
+func (def SecretsConfig) Flags(flags *pflag.FlagSet) {
+	flags.String("token", def.Token, "Token")
+	flags.String("secrets-user", def.User, "User")
+}
+
+func TestInfoStructRedacted(t *testing.T) {
+	cfg := cell.Config(SecretsConfig{
+		Token:             "token-secret",
+		Primary:           CredentialsConfig{User: "primary", Password: "primary-secret"},
+		Backups:           []*CredentialsConfig{{User: "backup", Password: "backup-secret"}, nil},
+		CredentialsConfig: CredentialsConfig{User: "embedded", Password: "embedded-secret"},
+		Comment:           "comment",
+	})
+	h := hive.New(cfg)
+	require.NoError(t, h.Populate())
+
+	data, err := json.Marshal(cfg.Info(h.Container()))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.JSONEq(t, `{
+		"type": "cell_test.SecretsConfig",
+		"value": {
+			"token": "<redacted>",
+			"key": "<redacted>",
+			"primary": {"User": "primary", "Password": "<redacted>"},
+			"Backups": [{"User": "backup", "Password": "<redacted>"}, null],
+			"User": "embedded",
+			"Password": "<redacted>"
+		}
+	}`, string(data))
+
+	// The structs without sensitive fields are encoded as is.
+	data, err = json.Marshal(cell.Config(GlyphsConfig{Enabled: true}).Info(h.Container()))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type": "cell_test.GlyphsConfig", "value": {"Enabled": true}}`, string(data))
+}
@@ -4,9 +4,10 @@
 package cell
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
-	"sort"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +19,25 @@ import (
 
 type invoker struct {
 	funcs []namedFunc
+
+	// after are the invoke cells whose functions must be invoked before
+	// these.
+	after []InvokeHandle
+
+	// bestEffort if true continues with the rest of the invoke functions
+	// if one fails. See InvokeBestEffort.
+	bestEffort bool
+}
+
+// InvokeHandle is an invoke cell that other invoke functions can be ordered
+// after with InvokeAfter.
+type InvokeHandle interface {
+	Cell
+
+	// String returns the names and locations of the invoke functions.
+	String() string
+
+	isInvokeHandle()
 }
 
 type namedFunc struct {
@@ -32,67 +52,154 @@ type InvokerList interface {
 	AppendInvoke(func() error)
 }
 
-func (inv *invoker) invoke(log *slog.Logger, cont container, logThreshold time.Duration) error {
+// OrderedInvokerList is optionally implemented by InvokerList to order the
+// invoke functions of the invoke cells (see InvokeAfter).
+type OrderedInvokerList interface {
+	InvokerList
+
+	// AppendInvokeAfter appends the invoke function of the invoke cell. It
+	// must be invoked after the invoke functions of the other invoke cells.
+	AppendInvokeAfter(invoke func() error, handle InvokeHandle, after []InvokeHandle)
+}
+
+func (inv *invoker) invoke(log *slog.Logger, cont Container, logThreshold time.Duration, lc *DefaultLifecycle, clock Clock) error {
+	var bestEffortErrs []error
 	for i := range inv.funcs {
 		nf := &inv.funcs[i]
 		log.Debug("Invoking", "function", nf.name)
-		t0 := time.Now()
+		t0 := clock.Now()
 
-		var opts []dig.InvokeOption
 		nf.infoMu.Lock()
-		if nf.info == nil {
-			nf.info = &dig.InvokeInfo{}
-			opts = []dig.InvokeOption{
-				dig.FillInvokeInfo(nf.info),
-			}
-		}
 		defer inv.funcs[i].infoMu.Unlock()
 
-		if err := cont.Invoke(nf.fn, opts...); err != nil {
+		var exit func([]levelKey)
+		if lc != nil {
+			// The info is filled when the cell is applied.
+			exit = lc.enterFunc(func() []levelKey {
+				keys := make([]levelKey, len(nf.info.Inputs))
+				for i, in := range nf.info.Inputs {
+					keys[i] = inputLevelKey(internal.DigInput(in, reflect.TypeOf(nf.fn)))
+				}
+				return keys
+			})
+		}
+		err := cont.Invoke(nf.fn)
+		if exit != nil {
+			exit(nil)
+		}
+		if err != nil {
+			if inv.bestEffort {
+				log.Warn("Best-effort invoke failed", "error", err, "function", nf.name)
+				bestEffortErrs = append(bestEffortErrs, fmt.Errorf("%s: %w", nf.name, err))
+				continue
+			}
 			log.Error("Invoke failed", "error", err, "function", nf.name)
 			return err
 		}
-		d := time.Since(t0)
+		d := clock.Since(t0)
 		if d > logThreshold {
 			log.Info("Invoked", "duration", d, "function", nf.name)
 		} else {
 			log.Debug("Invoked", "duration", d, "function", nf.name)
 		}
 	}
+	if len(bestEffortErrs) > 0 {
+		return &BestEffortError{Err: errors.Join(bestEffortErrs...)}
+	}
 	return nil
 }
 
-func (inv *invoker) Apply(log *slog.Logger, c container, logThreshold time.Duration) error {
-	// Remember the scope in which we need to invoke.
-	invoker := func() error { return inv.invoke(log, c, logThreshold) }
+// funcInputsMarker is the output of the constructor created by funcInputs.
+type funcInputsMarker struct{}
 
+// funcInputs returns the inputs of the function as parsed by dig by
+// providing a constructor with the same parameters to an empty container.
+// This way the inputs are known without invoking the function.
+func funcInputs(fn any) []*dig.Input {
+	typ := reflect.TypeOf(fn)
+	if typ == nil || typ.Kind() != reflect.Func {
+		return nil
+	}
+	ins, _ := funcTypes(typ)
+	ctorType := reflect.FuncOf(ins, []reflect.Type{reflect.TypeOf(funcInputsMarker{})}, typ.IsVariadic())
+	ctor := reflect.MakeFunc(ctorType, func([]reflect.Value) []reflect.Value {
+		return []reflect.Value{reflect.ValueOf(funcInputsMarker{})}
+	})
+	var info dig.ProvideInfo
+	if err := dig.New().Provide(ctor.Interface(), dig.FillProvideInfo(&info)); err != nil {
+		return nil
+	}
+	return info.Inputs
+}
+
+type invokerParams struct {
+	In
+	InvokerList InvokerList
+	Lifecycle   Lifecycle `optional:"true"`
+	Clock       Clock     `optional:"true"`
+}
+
+func (inv *invoker) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
 	// Append the invoker to the list of invoke functions. These are invoked
 	// prior to start to build up the objects. They are not invoked directly
 	// here as first the configuration flags need to be registered. This allows
 	// using hives in a command-line application with many commands and where
 	// we don't yet know which command to run, but we still need to register
 	// all the flags.
-	return c.Invoke(func(l InvokerList) {
-		l.AppendInvoke(invoker)
+	// Fill the info of the invoke functions that have not been invoked
+	// yet in order to describe their inputs before the hive is populated.
+	for i := range inv.funcs {
+		nf := &inv.funcs[i]
+		nf.infoMu.Lock()
+		if nf.info == nil {
+			nf.info = &dig.InvokeInfo{Inputs: funcInputs(nf.fn)}
+		}
+		nf.infoMu.Unlock()
+	}
+
+	return c.Invoke(func(p invokerParams) {
+		// Remember the scope in which we need to invoke.
+		lc := levelTracker(p.Lifecycle)
+		clock := clockOrReal(p.Clock)
+		invoke := func() error { return inv.invoke(log, c, logThreshold, lc, clock) }
+		if l, ok := p.InvokerList.(OrderedInvokerList); ok {
+			l.AppendInvokeAfter(invoke, inv, inv.after)
+		} else {
+			p.InvokerList.AppendInvoke(invoke)
+		}
 	})
 }
 
-func (inv *invoker) Info(container) Info {
+func (inv *invoker) String() string {
+	names := make([]string, len(inv.funcs))
+	for i := range inv.funcs {
+		names[i] = inv.funcs[i].name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (inv *invoker) isInvokeHandle() {}
+
+func (inv *invoker) Info(Container) Info {
 	n := NewInfoNode("")
 	for i := range inv.funcs {
 		namedFunc := &inv.funcs[i]
 		namedFunc.infoMu.Lock()
 		defer namedFunc.infoMu.Unlock()
 
-		invNode := NewInfoNode(fmt.Sprintf("🛠️ %s", namedFunc.name))
-		invNode.condensed = true
-
-		var ins []string
-		for _, input := range namedFunc.info.Inputs {
-			ins = append(ins, input.String())
+		info := &InvokeInfo{Name: namedFunc.name}
+		if namedFunc.info != nil {
+			// The info is filled when first applied.
+			for _, input := range namedFunc.info.Inputs {
+				info.inputTypes.add(&info.Inputs, internal.DigInput(input, reflect.TypeOf(namedFunc.fn)))
+			}
 		}
-		sort.Strings(ins)
-		invNode.AddLeaf("⇨ %s", strings.Join(ins, ", "))
+		sortInfoValues(info.Inputs)
+
+		invNode := newInfoNode("🛠️", namedFunc.name)
+		invNode.condensed = true
+		invNode.invoke = info
+		addInfoValues(invNode, "⇨", "inputs", info.Inputs)
 		n.Add(invNode)
 	}
 	return n
@@ -100,7 +207,12 @@ func (inv *invoker) Info(container) Info {
 
 // Invoke constructs a cell for invoke functions. The invoke functions are executed
 // when the hive is started to instantiate all objects via the constructors.
-func Invoke(funcs ...any) Cell {
+//
+// The invoke functions are executed in the order the cells are in the hive,
+// unless ordered explicitly with InvokeAfter. An invoke function may depend on
+// Lifecycle for appending hooks. These are started after the hooks of the
+// constructors it depends on and stopped before them, see Lifecycle.
+func Invoke(funcs ...any) InvokeHandle {
 	namedFuncs := []namedFunc{}
 	for _, fn := range funcs {
 		namedFuncs = append(
@@ -109,3 +221,47 @@ func Invoke(funcs ...any) Cell {
 	}
 	return &invoker{funcs: namedFuncs}
 }
+
+// InvokeBestEffort is like Invoke, but for non-critical invoke functions, e.g.
+// registering optional debug handlers. If an invoke function fails the error
+// is logged and the rest of the invoke functions are invoked. The failures do
+// not prevent the hive from running, but they are returned by hive.Run() as a
+// BestEffortError after the hive has stopped. Failures of the other invoke
+// functions still abort populating the hive immediately.
+func InvokeBestEffort(funcs ...any) InvokeHandle {
+	inv := Invoke(funcs...).(*invoker)
+	inv.bestEffort = true
+	return inv
+}
+
+// BestEffortError is the error of failed best-effort invoke functions.
+// See InvokeBestEffort.
+type BestEffortError struct {
+	Err error
+}
+
+func (e *BestEffortError) Error() string {
+	return fmt.Sprintf("best-effort invoke failed: %s", e.Err)
+}
+
+func (e *BestEffortError) Unwrap() error {
+	return e.Err
+}
+
+// InvokeAfter is like Invoke, but the invoke functions are executed after the
+// invoke functions of the given invoke cell, regardless of the order of the
+// cells in the hive. Useful when an invoke function depends on the side-effects
+// of another without depending on the same objects:
+//
+//	registerHandlers := cell.Invoke(registerHandlers)
+//	cell.Module("server", "HTTP server",
+//		registerHandlers,
+//		cell.InvokeAfter(registerHandlers, serveHandlers),
+//	)
+//
+// The hive fails to populate if the given invoke cell is not in the hive.
+func InvokeAfter(dep InvokeHandle, funcs ...any) InvokeHandle {
+	inv := Invoke(funcs...).(*invoker)
+	inv.after = []InvokeHandle{dep}
+	return inv
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestInvokeAfter(t *testing.T) {
+	var order []string
+	first := cell.Invoke(func() { order = append(order, "first") })
+	h := hive.New(
+		cell.Module("mod-a", "A",
+			cell.InvokeAfter(first, func() { order = append(order, "second") }),
+		),
+		cell.Invoke(func() { order = append(order, "unordered") }),
+		cell.Module("mod-b", "B", first),
+	)
+	require.NoError(t, h.Populate(), "Populate")
+	assert.Equal(t, []string{"unordered", "first", "second"}, order)
+
+	// The invoke cell to order after must be in the hive.
+	h = hive.New(
+		cell.InvokeAfter(cell.Invoke(func() {}), func() {}),
+	)
+	assert.ErrorContains(t, h.Populate(), "which is not in the hive")
+}
+
+func TestInvokeOrderingConflict(t *testing.T) {
+	a, b := cell.Invoke(func() {}), cell.Invoke(func() {})
+	h := hive.New()
+	h.AppendInvokeAfter(func() error { return nil }, a, []cell.InvokeHandle{b})
+	h.AppendInvokeAfter(func() error { return nil }, b, []cell.InvokeHandle{a})
+	err := h.Populate()
+	assert.ErrorContains(t, err, "conflicting ordering of invoke functions: cell_test.TestInvokeOrderingConflict.func1")
+	assert.ErrorContains(t, err, "cell_test.TestInvokeOrderingConflict.func2")
+}
+
+func TestInvokeBestEffort(t *testing.T) {
+	var invoked []string
+	h := hive.New(
+		cell.InvokeBestEffort(
+			func() error { return errors.New("debug handler failed") },
+			func() { invoked = append(invoked, "best-effort") },
+		),
+		cell.Invoke(func() { invoked = append(invoked, "critical") }),
+		shutdownOnStartCell,
+	)
+	err := h.Run()
+	require.Error(t, err, "expected Run to return the best-effort failure")
+	assert.Equal(t, []string{"best-effort", "critical"}, invoked, "expected the hive to run")
+
+	var bestEffortErr *cell.BestEffortError
+	require.ErrorAs(t, err, &bestEffortErr)
+	assert.ErrorContains(t, bestEffortErr, "debug handler failed")
+	assert.NotContains(t, err.Error(), "failed to start")
+
+	// Critical invoke functions still abort.
+	invoked = nil
+	h = hive.New(
+		cell.Invoke(func() error { return errors.New("critical failed") }),
+		cell.InvokeBestEffort(func() { invoked = append(invoked, "best-effort") }),
+	)
+	err = h.Populate()
+	assert.ErrorContains(t, err, "critical failed")
+	assert.False(t, errors.As(err, &bestEffortErr), "expected critical failure to not be a BestEffortError")
+	assert.Empty(t, invoked, "expected populate to abort")
+}
+
+func TestInvokeAppendsHooks(t *testing.T) {
+	var events []string
+	appendHook := func(lc cell.Lifecycle, name string) {
+		lc.Append(cell.Hook{
+			OnStart: func(cell.HookContext) error {
+				events = append(events, "start "+name)
+				return nil
+			},
+			OnStop: func(cell.HookContext) error {
+				events = append(events, "stop "+name)
+				return nil
+			},
+		})
+	}
+	h := hive.New(
+		// The invoke functions are before the constructors in the hive,
+		// yet the constructors they depend on append their hooks first.
+		cell.Invoke(func(lc cell.Lifecycle, _ *OtherObject) { appendHook(lc, "invoke-1") }),
+		cell.Invoke(func(lc cell.Lifecycle) { appendHook(lc, "invoke-2") }),
+		cell.Provide(
+			func(lc cell.Lifecycle) *SomeObject {
+				appendHook(lc, "some")
+				return &SomeObject{}
+			},
+			func(lc cell.Lifecycle, _ *SomeObject) *OtherObject {
+				appendHook(lc, "other")
+				return &OtherObject{}
+			},
+		),
+	)
+
+	require.NoError(t, h.Populate())
+	assert.Empty(t, events, "expected no hooks to run before start")
+
+	require.NoError(t, h.Start(context.TODO()))
+	assert.Equal(t, []string{"start some", "start other", "start invoke-1", "start invoke-2"}, events)
+
+	// The hooks are stopped in reverse dependency order, and in reverse
+	// order of appending otherwise: invoke-2 does not depend on anything
+	// and was appended last, so it is stopped first.
+	events = nil
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, []string{"stop invoke-2", "stop invoke-1", "stop other", "stop some"}, events)
+}
@@ -7,8 +7,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cilium/hive/internal"
@@ -29,7 +37,11 @@ type HookInterface interface {
 
 // Hook is a pair of start and stop callbacks. Both are optional.
 // They're paired up to make sure that on failed start all corresponding
-// stop hooks are executed.
+// stop hooks are executed. The stop callback is only executed if the start
+// callback completed successfully, so it does not need to handle cleaning
+// up something that was never initialized. Hooks after the failed one are
+// neither started nor stopped. A hook without a start callback counts as
+// started.
 type Hook struct {
 	OnStart func(HookContext) error
 	OnStop  func(HookContext) error
@@ -49,8 +61,86 @@ func (h Hook) Stop(ctx HookContext) error {
 	return h.OnStop(ctx)
 }
 
+// HookWithName returns a hook that is referred to by the given name in the
+// logs and errors of the lifecycle instead of the names and locations of
+// the start and stop functions.
+func HookWithName(name string, h Hook) HookInterface {
+	return namedHook{h, name}
+}
+
+type namedHook struct {
+	Hook
+	name string
+}
+
+// RetryHook returns a hook that retries the start hook of the given hook if
+// it fails. The start hook is tried at most 'attempts' times, waiting for
+// 'backoff' after the first failure and doubling the wait after each further
+// failure. The wait is measured with the Clock of the lifecycle. If the start
+// context is cancelled the retrying stops immediately.
+// If all attempts fail, the error of the last attempt is returned and the
+// lifecycle stops the hooks that were already started. The stop hook is not
+// retried.
+func RetryHook(h Hook, attempts int, backoff time.Duration) HookInterface {
+	return retryHook{h, attempts, backoff}
+}
+
+type retryHook struct {
+	Hook
+	attempts int
+	backoff  time.Duration
+}
+
+func (h retryHook) Start(ctx HookContext) error {
+	if h.OnStart == nil {
+		return nil
+	}
+	backoff := h.backoff
+	clock := clockOrReal(clockFrom(ctx))
+	for attempt := 1; ; attempt++ {
+		err := h.OnStart(ctx)
+		if err == nil || attempt >= h.attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-clock.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// RootContext is the context of the hive. It is cancelled when the hive
+// begins to stop, before the stop hooks are executed. Constructors can use it
+// for cancellation-aware initialization, e.g. retrying until the hive is
+// stopped. Background work that outlives the constructor should be started
+// from a start hook and stopped from a stop hook instead of relying on this
+// context, and the context should not be used after the hive has stopped.
+type RootContext context.Context
+
 // Lifecycle enables cells to register start and stop hooks, either
 // from a constructor or an invoke function.
+//
+// The start hooks are executed in the order they were appended. As the
+// constructors are called when the invoke functions are run, the hooks
+// appended by an invoke function are appended after the hooks of the
+// constructors of the objects it depends on, and thus started after them.
+// Keeping the wiring out of the constructors is done by appending the hooks
+// in an invoke function:
+//
+//	cell.Invoke(func(lc cell.Lifecycle, s *Server, r *Registry) {
+//		lc.Append(cell.Hook{OnStart: func(cell.HookContext) error { return r.Register(s) }})
+//	})
+//
+// The stop hooks are executed in reverse dependency order: the hooks appended
+// by a constructor or an invoke function are stopped before the hooks
+// appended by the constructors of its dependencies, and otherwise in reverse
+// order of appending.
+//
+// Appending a hook after the lifecycle has been started panics with the
+// location of the caller, as the start hook of the appended hook would never
+// be executed.
 type Lifecycle interface {
 	Append(HookInterface)
 
@@ -67,22 +157,134 @@ type DefaultLifecycle struct {
 	hooks      []augmentedHook
 	numStarted int
 
+	// started is true from the call to Start until Stop has completed.
+	// Appending hooks during this time is a bug as their start hooks would
+	// never run.
+	started atomic.Bool
+
+	// levels and frames track the dependency levels of the constructors
+	// and invoke functions for running stop hooks in parallel.
+	levels map[levelKey]int
+	frames []*levelFrame
+
+	// inputsOf are the inputs of the constructors of each object, for
+	// stopping the hooks in reverse dependency order, and deps the
+	// dependencies of the hooks computed from them when stopping.
+	inputsOf map[levelKey][]levelKey
+	deps     map[*levelFrame]*frameDeps
+
+	// onFirstAppend if not nil is called when the first hook is appended.
+	// Used for nesting the lifecycle of a sub-hive in the parent lifecycle.
+	onFirstAppend func()
+
+	// progressMu is held when calling StartProgress, also by the nested
+	// lifecycles of the sub-hives, to not call it concurrently.
+	progressMu sync.Mutex
+
 	LogThreshold time.Duration
+
+	// HookTimeout if non-zero is the time allotted for each start and stop
+	// hook. The context given to the hook is cancelled after the timeout and
+	// the hook is abandoned if it does not return in time, failing the start
+	// or stop with an error naming the hook.
+	HookTimeout time.Duration
+
+	// ParallelStop if larger than one is the maximum number of stop hooks
+	// to run in parallel. Only the stop hooks appended by constructors and
+	// invoke functions that do not depend on each other are run in parallel,
+	// so the stop hook of an object is still run before the stop hooks of its
+	// dependencies. Hooks appended elsewhere are stopped one at a time.
+	ParallelStop int
+
+	// ParallelStart if larger than one is the maximum number of start hooks
+	// to run in parallel. Like with ParallelStop only the start hooks
+	// appended by constructors and invoke functions that do not depend on
+	// each other are run in parallel, so the start hook of an object is still
+	// run after the start hooks of its dependencies. The errors of the hooks
+	// run in parallel are joined.
+	ParallelStart int
+
+	// StartWatchdog if non-zero is the time after which a start that has not
+	// completed logs the start hook that is still running, and then again
+	// every StartWatchdog until the start completes. If WatchdogStacks is
+	// true the stacks of all goroutines are logged as well.
+	StartWatchdog  time.Duration
+	WatchdogStacks bool
+
+	// StartProgress if not nil is called after each successful start hook
+	// with the number of start hooks executed so far, the total number of
+	// start hooks and the name of the hook. It is not called concurrently
+	// even with ParallelStart.
+	StartProgress func(done, total int, name string)
+
+	// Clock if not nil is used for measuring the durations of the hooks and
+	// for waiting between the attempts of the hooks from RetryHook.
+	Clock Clock
+
+	// Tracer if not nil starts a span for each start and stop hook.
+	Tracer Tracer
+
+	// Metrics if not nil is given the durations and results of the start
+	// and stop hooks.
+	Metrics LifecycleMetrics
+}
+
+// LifecycleMetrics is an optional sink for the durations and results of the
+// lifecycle hooks, e.g. for building a flamegraph of the startup. The name is
+// the name of the hook as logged by the lifecycle. With ParallelStart and
+// ParallelStop the methods may be called concurrently.
+// Supplied with [hive.Options] field 'LifecycleMetrics'.
+type LifecycleMetrics interface {
+	HookStart(name string, duration time.Duration, err error)
+	HookStop(name string, duration time.Duration, err error)
 }
 
 type augmentedHook struct {
 	HookInterface
 	moduleID FullModuleID
+
+	// level is the dependency level of the constructor or invoke function
+	// that appended the hook, or -1 if not known.
+	level int
+
+	// frame is the call to the constructor or invoke function that appended
+	// the hook, or nil if not known.
+	frame *levelFrame
+
+	// location is the source location from where the hook was appended.
+	location string
 }
 
 func (lc *DefaultLifecycle) Append(hook HookInterface) {
+	location := internal.CallerLocation()
+	lc.checkNotStarted(location)
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
-	lc.hooks = append(lc.hooks, augmentedHook{hook, nil})
+	lc.firstAppend()
+	frame, level := lc.currentFrame()
+	lc.hooks = append(lc.hooks, augmentedHook{hook, nil, level, frame, location})
+}
+
+// checkNotStarted panics if the lifecycle has been started. Checked before
+// taking the lock as Start holds it while running the start hooks.
+func (lc *DefaultLifecycle) checkNotStarted(location string) {
+	if lc.started.Load() {
+		panic(fmt.Sprintf("cell.Lifecycle.Append called at %s after the lifecycle was started: "+
+			"the start hook would never run. Append the hooks in constructors or invoke functions instead",
+			location))
+	}
+}
+
+func (lc *DefaultLifecycle) firstAppend() {
+	if fn := lc.onFirstAppend; fn != nil {
+		lc.onFirstAppend = nil
+		fn()
+	}
 }
 
 func (lc *DefaultLifecycle) Start(log *slog.Logger, ctx context.Context) error {
+	lc.started.Store(true)
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
@@ -92,36 +294,182 @@ func (lc *DefaultLifecycle) Start(log *slog.Logger, ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	var inflight atomic.Value
+	if lc.StartWatchdog > 0 {
+		stop := lc.watchStart(log, &inflight)
+		defer stop()
+	}
+
+	if lc.ParallelStart > 1 {
+		lc.sortUnstarted()
+	}
+
+	progress := &startProgress{mu: &lc.progressMu, fn: lc.StartProgress}
 	for _, hook := range lc.hooks {
-		fnName, exists := getHookFuncName(hook, true)
+		if _, exists := getHookFuncName(hook, true); exists {
+			progress.total++
+		}
+	}
 
-		if !exists {
-			// Count as started as there might be a stop hook.
+	for lc.numStarted < len(lc.hooks) {
+		first, last := lc.numStarted, lc.startBatch()
+		if last-first == 1 {
+			if err := lc.startHook(log, ctx, lc.hooks[first], &inflight, progress); err != nil {
+				return err
+			}
 			lc.numStarted++
+		} else if err := lc.startHooksParallel(log, ctx, first, last, &inflight, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startProgress counts the executed start hooks for logging the progress
+// and calling StartProgress.
+type startProgress struct {
+	mu          *sync.Mutex
+	done, total int
+	fn          func(done, total int, name string)
+}
+
+// step counts the start hook as executed and returns the progress, e.g.
+// "3/10". StartProgress is called with the lock held to not call it
+// concurrently when starting hooks in parallel.
+func (p *startProgress) step(name string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.fn != nil {
+		p.fn(p.done, p.total, name)
+	}
+	return fmt.Sprintf("%d/%d", p.done, p.total)
+}
+
+func (lc *DefaultLifecycle) startHook(log *slog.Logger, ctx context.Context, hook augmentedHook, inflight *atomic.Value, progress *startProgress) error {
+	fnName, exists := getHookFuncName(hook, true)
+	if !exists {
+		// Counted as started as there might be a stop hook.
+		return nil
+	}
+
+	l := log.With("function", fnName)
+	l.Debug("Executing start hook")
+	inflight.Store(fnName)
+	d, err := lc.traceHook(ctx, fnName, hook.Start)
+	if lc.Metrics != nil {
+		lc.Metrics.HookStart(fnName, d, err)
+	}
+	if err != nil {
+		l.Error("Start hook failed", "error", err)
+		return fmt.Errorf("start hook %s failed: %w", fnName, err)
+	}
+	if p := progress.step(fnName); d > lc.LogThreshold {
+		l.Info("Start hook executed", "duration", d, "progress", p)
+	} else {
+		l.Debug("Start hook executed", "duration", d, "progress", p)
+	}
+	return nil
+}
+
+// sortUnstarted reorders each run of consecutive hooks appended by
+// constructors and invoke functions by their dependency levels if
+// ParallelStart is set, so that the hooks that do not depend on each other
+// are next to each other and can be started in parallel. The hooks of an
+// object are still started after the hooks of its dependencies as the
+// dependencies have lower levels. Hooks with the same level keep the order
+// of appending and the hooks appended elsewhere keep their position.
+func (lc *DefaultLifecycle) sortUnstarted() {
+	hooks := lc.hooks[lc.numStarted:]
+	for i := 0; i < len(hooks); {
+		if hooks[i].level < 0 {
+			i++
 			continue
 		}
+		j := i
+		for j < len(hooks) && hooks[j].level >= 0 {
+			j++
+		}
+		run := hooks[i:j]
+		sort.SliceStable(run, func(a, b int) bool {
+			return run[a].level < run[b].level
+		})
+		i = j
+	}
+}
 
-		l := log.With("function", fnName)
-		l.Debug("Executing start hook")
-		t0 := time.Now()
-		if err := hook.Start(ctx); err != nil {
-			l.Error("Start hook failed", "error", err)
-			return err
+// startBatch returns the index after the last hook in the batch of hooks to
+// start next. The batch consists of the first hook not started and the
+// following hooks with the same dependency level if ParallelStart is set.
+// The batch ends before a hook appended by the same constructor or invoke
+// function as a hook in the batch, as those hooks are started in order.
+func (lc *DefaultLifecycle) startBatch() int {
+	first := lc.numStarted
+	last := first + 1
+	if lc.ParallelStart > 1 && lc.hooks[first].level >= 0 {
+		for last < len(lc.hooks) && lc.hooks[last].level == lc.hooks[first].level &&
+			!sameFrame(lc.hooks[first:last], lc.hooks[last]) {
+			last++
+		}
+	}
+	return last
+}
+
+// sameFrame returns true if the hook was appended by the same call to a
+// constructor or invoke function as one of the hooks.
+func sameFrame(hooks []augmentedHook, hook augmentedHook) bool {
+	for _, h := range hooks {
+		if h.frame == hook.frame {
+			return true
 		}
-		d := time.Since(t0)
-		if d > lc.LogThreshold {
-			l.Info("Start hook executed", "duration", d)
+	}
+	return false
+}
+
+// startHooksParallel starts the hooks from first to last in parallel. The
+// hooks that started successfully are moved to the front of the batch and
+// counted as started, so that only they are stopped when rolling back after
+// the failures of the other hooks in the batch.
+func (lc *DefaultLifecycle) startHooksParallel(log *slog.Logger, ctx context.Context, first, last int, inflight *atomic.Value, progress *startProgress) error {
+	batch := lc.hooks[first:last]
+	var (
+		wg      sync.WaitGroup
+		results = make([]error, len(batch))
+		sem     = make(chan struct{}, lc.ParallelStart)
+	)
+	for i, hook := range batch {
+		i, hook := i, hook
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = lc.startHook(log, ctx, hook, inflight, progress)
+		}()
+	}
+	wg.Wait()
+
+	var (
+		started, failed []augmentedHook
+		errs            error
+	)
+	for i, err := range results {
+		if err != nil {
+			failed = append(failed, batch[i])
+			errs = errors.Join(errs, err)
 		} else {
-			l.Debug("Start hook executed", "duration", d)
+			started = append(started, batch[i])
 		}
-		lc.numStarted++
 	}
-	return nil
+	copy(batch, append(started, failed...))
+	lc.numStarted += len(started)
+	return errs
 }
 
 func (lc *DefaultLifecycle) Stop(log *slog.Logger, ctx context.Context) error {
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
+	defer lc.started.Store(false)
 
 	// Wrap the context to make sure it gets cancelled after
 	// stop hooks have completed in order to discourage using
@@ -129,69 +477,420 @@ func (lc *DefaultLifecycle) Stop(log *slog.Logger, ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	lc.sortStarted()
+
 	var errs error
-	for ; lc.numStarted > 0; lc.numStarted-- {
+	for lc.numStarted > 0 {
 		if ctx.Err() != nil {
-			return ctx.Err()
+			return errors.Join(errs, lc.abandon(log, ctx, nil))
 		}
-		hook := lc.hooks[lc.numStarted-1]
+		first := lc.stopBatch()
+		batch := lc.hooks[first:lc.numStarted]
 
-		fnName, exists := getHookFuncName(hook, false)
-		if !exists {
-			continue
-		}
-		l := log.With("function", fnName)
-		l.Debug("Executing stop hook")
-		t0 := time.Now()
-		if err := hook.Stop(ctx); err != nil {
-			l.Error("Stop hook failed", "error", err)
-			errs = errors.Join(errs, err)
-		} else {
-			d := time.Since(t0)
-			if d > lc.LogThreshold {
-				l.Info("Stop hook executed", "duration", d)
+		// Run the batch in a goroutine in order to abandon the hooks that
+		// do not respect the cancellation of the context instead of
+		// waiting for them forever.
+		outstanding := &outstandingHooks{}
+		done := make(chan error, 1)
+		go func() {
+			if len(batch) == 1 {
+				done <- lc.stopHook(log, ctx, batch[0], outstanding)
 			} else {
-				l.Debug("Stop hook executed", "duration", d)
+				done <- lc.stopHooksParallel(log, ctx, batch, outstanding)
+			}
+		}()
+		select {
+		case err := <-done:
+			errs = errors.Join(errs, err)
+			lc.numStarted = first
+		case <-ctx.Done():
+			// Give the hooks respecting the cancellation a moment to
+			// return before abandoning them. The grace period is measured
+			// with a real timer as a fake Clock may never advance.
+			select {
+			case err := <-done:
+				errs = errors.Join(errs, err)
+				lc.numStarted = first
+				continue
+			case <-time.After(abandonGracePeriod):
 			}
+			lc.numStarted = first
+			return errors.Join(errs, lc.abandon(log, ctx, outstanding.names()))
 		}
 	}
 	return errs
 }
 
+// abandonGracePeriod is how long the stop hooks have for returning after the
+// stop context is cancelled before they are abandoned.
+const abandonGracePeriod = 100 * time.Millisecond
+
+// abandon abandons the hooks that have not been stopped when the stop
+// context is cancelled. The hooks still running are logged and named in the
+// error, and the hooks that have not been run are never run.
+func (lc *DefaultLifecycle) abandon(log *slog.Logger, ctx context.Context, outstanding []string) error {
+	remaining := lc.numStarted
+	lc.numStarted = 0
+	log.Error("Stop cancelled, abandoning hooks",
+		"outstanding", outstanding,
+		"not-stopped", remaining,
+		"error", ctx.Err())
+	if len(outstanding) == 0 {
+		return ctx.Err()
+	}
+	return fmt.Errorf("stop hooks did not complete: %s: %w", strings.Join(outstanding, ", "), ctx.Err())
+}
+
+// outstandingHooks are the names of the stop hooks that are running.
+type outstandingHooks struct {
+	mu    sync.Mutex
+	hooks []string
+}
+
+func (o *outstandingHooks) add(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hooks = append(o.hooks, name)
+}
+
+func (o *outstandingHooks) remove(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if i := slices.Index(o.hooks, name); i >= 0 {
+		o.hooks = slices.Delete(o.hooks, i, i+1)
+	}
+}
+
+func (o *outstandingHooks) names() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.hooks)
+}
+
+// sortStarted reorders the started hooks so that the hooks for an object are
+// stopped after the hooks for the objects depending on it, even if they were
+// appended later. A hook is only moved ahead of the hooks it depends on, so the
+// hooks with no dependency relationship are stopped in reverse order of
+// appending.
+func (lc *DefaultLifecycle) sortStarted() {
+	started := lc.hooks[:lc.numStarted]
+	lc.deps = map[*levelFrame]*frameDeps{}
+
+	// Order the hooks topologically, picking the hook appended first among
+	// the hooks whose dependencies have all been picked.
+	deps := make([]int, len(started))
+	dependents := make([][]int, len(started))
+	for i := range started {
+		for j := range started {
+			if i != j && lc.dependsOn(started[i], started[j]) {
+				deps[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+	sorted := make([]augmentedHook, 0, len(started))
+	picked := make([]bool, len(started))
+	for len(sorted) < len(started) {
+		next := -1
+		for i := range started {
+			if !picked[i] && deps[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			// The dependencies of the objects are acyclic, but keep the
+			// remaining hooks in order should they not be.
+			for i := range started {
+				if !picked[i] {
+					sorted = append(sorted, started[i])
+				}
+			}
+			break
+		}
+		picked[next] = true
+		sorted = append(sorted, started[next])
+		for _, i := range dependents[next] {
+			deps[i]--
+		}
+	}
+	copy(started, sorted)
+}
+
+// frameDeps are the objects the hooks appended by a constructor or invoke
+// function call are for, and the objects these transitively depend on. The
+// hooks appended by a constructor are for the objects it constructs and the
+// hooks appended by an invoke function are for the objects given to it.
+type frameDeps struct {
+	objects []levelKey
+	uses    map[levelKey]bool
+}
+
+// dependsOn returns true if the hook a must be stopped before the hook b as
+// it is for an object depending on the objects b is for. Must be called with
+// the mutex held after sortStarted.
+func (lc *DefaultLifecycle) dependsOn(a, b augmentedHook) bool {
+	if a.frame == nil || b.frame == nil || a.frame == b.frame {
+		return false
+	}
+	da, db := lc.frameDeps(a.frame), lc.frameDeps(b.frame)
+
+	// The hooks of an invoke function are stopped before the hooks of the
+	// constructors of the objects given to it.
+	forInvoke := a.frame.outputs == nil && b.frame.outputs != nil
+	for _, obj := range db.objects {
+		if da.uses[obj] || forInvoke && slices.Contains(da.objects, obj) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lc *DefaultLifecycle) frameDeps(f *levelFrame) *frameDeps {
+	if d, ok := lc.deps[f]; ok {
+		return d
+	}
+	d := &frameDeps{objects: f.outputs, uses: map[levelKey]bool{}}
+	if f.outputs == nil {
+		// Only the inputs constructed by the constructors, e.g. not the
+		// Lifecycle.
+		for _, in := range f.inputKeys {
+			if _, ok := lc.inputsOf[in]; ok {
+				d.objects = append(d.objects, in)
+			}
+		}
+	}
+	var stack []levelKey
+	for _, obj := range d.objects {
+		stack = append(stack, lc.inputsOf[obj]...)
+	}
+	for len(stack) > 0 {
+		key := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !d.uses[key] {
+			d.uses[key] = true
+			stack = append(stack, lc.inputsOf[key]...)
+		}
+	}
+	lc.deps[f] = d
+	return d
+}
+
+// stopBatch returns the index of the first hook in the batch of started
+// hooks to stop next. The batch consists of the last started hook and
+// the preceding hooks with the same dependency level if ParallelStop is set.
+// The batch ends after a hook appended by the same constructor or invoke
+// function as a hook in the batch, as those hooks are stopped in reverse
+// order, or after a hook that a hook in the batch has a dependency with.
+func (lc *DefaultLifecycle) stopBatch() int {
+	last := lc.numStarted - 1
+	first := last
+	if lc.ParallelStop > 1 && lc.hooks[last].level >= 0 {
+		for first > 0 && lc.hooks[first-1].level == lc.hooks[last].level &&
+			!sameFrame(lc.hooks[first:last+1], lc.hooks[first-1]) &&
+			!lc.dependedOn(lc.hooks[first:last+1], lc.hooks[first-1]) {
+			first--
+		}
+	}
+	return first
+}
+
+// dependedOn returns true if one of the hooks depends on the hook or the
+// hook depends on one of them.
+func (lc *DefaultLifecycle) dependedOn(hooks []augmentedHook, hook augmentedHook) bool {
+	for _, h := range hooks {
+		if lc.dependsOn(h, hook) || lc.dependsOn(hook, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (lc *DefaultLifecycle) stopHooksParallel(log *slog.Logger, ctx context.Context, hooks []augmentedHook, outstanding *outstandingHooks) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs error
+		sem  = make(chan struct{}, lc.ParallelStop)
+	)
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			// The batch is being abandoned, do not start the hooks
+			// still queued.
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := lc.stopHook(log, ctx, hook, outstanding); err != nil {
+				mu.Lock()
+				errs = errors.Join(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func (lc *DefaultLifecycle) stopHook(log *slog.Logger, ctx context.Context, hook augmentedHook, outstanding *outstandingHooks) error {
+	fnName, exists := getHookFuncName(hook, false)
+	if !exists {
+		return nil
+	}
+	l := log.With("function", fnName)
+	l.Debug("Executing stop hook")
+	outstanding.add(fnName)
+	d, err := lc.traceHook(ctx, fnName, hook.Stop)
+	outstanding.remove(fnName)
+	if lc.Metrics != nil {
+		lc.Metrics.HookStop(fnName, d, err)
+	}
+	if err != nil {
+		l.Error("Stop hook failed", "error", err)
+		return fmt.Errorf("stop hook %s failed: %w", fnName, err)
+	}
+	if d > lc.LogThreshold {
+		l.Info("Stop hook executed", "duration", d)
+	} else {
+		l.Debug("Stop hook executed", "duration", d)
+	}
+	return nil
+}
+
+// traceHook runs the hook within a span if Tracer is set and returns its
+// duration and error.
+func (lc *DefaultLifecycle) traceHook(ctx context.Context, name string, fn func(HookContext) error) (time.Duration, error) {
+	var span Span
+	if lc.Tracer != nil {
+		ctx, span = lc.Tracer.Start(ctx, name)
+	}
+	t0 := clockOrReal(lc.Clock).Now()
+	err := lc.runHook(withClock(ctx, lc.Clock), fn)
+	d := clockOrReal(lc.Clock).Since(t0)
+	if span != nil {
+		span.End(err)
+	}
+	return d, err
+}
+
+// watchStart logs the in-flight start hook every StartWatchdog until the
+// returned function is called.
+func (lc *DefaultLifecycle) watchStart(log *slog.Logger, inflight *atomic.Value) (stop func()) {
+	done := make(chan struct{})
+	clock := clockOrReal(lc.Clock)
+	t0 := clock.Now()
+	go func() {
+		ticker := time.NewTicker(lc.StartWatchdog)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			args := []any{"duration", clock.Since(t0)}
+			if name, ok := inflight.Load().(string); ok {
+				args = append(args, "function", name)
+			}
+			if lc.WatchdogStacks {
+				buf := make([]byte, 1<<20)
+				args = append(args, "stacks", string(buf[:runtime.Stack(buf, true)]))
+			}
+			log.Warn("Start has not completed, start hook still running", args...)
+		}
+	}()
+	return func() { close(done) }
+}
+
+// runHook runs the hook with the HookTimeout if set.
+func (lc *DefaultLifecycle) runHook(ctx context.Context, fn func(HookContext) error) error {
+	if lc.HookTimeout <= 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, lc.HookTimeout, errHookTimeout)
+	defer cancel()
+
+	// Run the hook in a goroutine in order to not wait for it past the
+	// timeout if it does not respect the context.
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	// Only attribute the failure to the HookTimeout if it was the hook's own
+	// timer that expired and not the deadline of the parent context.
+	if err != nil && errors.Is(context.Cause(ctx), errHookTimeout) {
+		err = fmt.Errorf("timed out after %s: %w", lc.HookTimeout, err)
+	}
+	return err
+}
+
+// errHookTimeout is the cause of the cancellation of the hook context when
+// the HookTimeout expires.
+var errHookTimeout = errors.New("hook timeout")
+
 func (lc *DefaultLifecycle) PrintHooks() {
+	lc.WriteHooks(os.Stdout)
+}
+
+// WriteHooks writes the start and stop hooks to w in the order they are
+// executed.
+func (lc *DefaultLifecycle) WriteHooks(w io.Writer) {
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
-	fmt.Printf("Start hooks:\n\n")
+	fmt.Fprintf(w, "Start hooks:\n\n")
 	for _, hook := range lc.hooks {
 		fnName, exists := getHookFuncName(hook.HookInterface, true)
 		if !exists {
 			continue
 		}
-		fmt.Printf("  • %s (%s)\n", fnName, hook.moduleID)
+		fmt.Fprintf(w, "  • %s (%s)\n", fnName, hook.moduleID)
 	}
 
-	fmt.Printf("\nStop hooks:\n\n")
+	fmt.Fprintf(w, "\nStop hooks:\n\n")
 	for i := len(lc.hooks) - 1; i >= 0; i-- {
 		hook := lc.hooks[i]
 		fnName, exists := getHookFuncName(hook.HookInterface, false)
 		if !exists {
 			continue
 		}
-		fmt.Printf("  • %s (%s)\n", fnName, hook.moduleID)
+		fmt.Fprintf(w, "  • %s (%s)\n", fnName, hook.moduleID)
 	}
 }
 
 type augmentedLifecycle struct {
 	*DefaultLifecycle
 	moduleID FullModuleID
+
+	// optional if not nil is the optional cell the hooks are appended in.
+	optional *optionalState
 }
 
 func (lc augmentedLifecycle) Append(hook HookInterface) {
+	location := internal.CallerLocation()
+	lc.checkNotStarted(location)
 	lc.mu.Lock()
 	defer lc.mu.Unlock()
 
-	lc.hooks = append(lc.hooks, augmentedHook{hook, lc.moduleID})
+	lc.firstAppend()
+	if lc.optional != nil {
+		hook = &optionalHook{HookInterface: hook, state: lc.optional}
+	}
+	frame, level := lc.currentFrame()
+	lc.hooks = append(lc.hooks, augmentedHook{hook, lc.moduleID, level, frame, location})
 }
 
 func getHookFuncName(hook HookInterface, start bool) (name string, hasHook bool) {
@@ -208,10 +907,22 @@ func getHookFuncName(hook HookInterface, start bool) (name string, hasHook bool)
 	switch hook := hook.(type) {
 	case augmentedHook:
 		name, hasHook = getHookFuncName(hook.HookInterface, start)
+		if hasHook && !hasFuncName(hook.HookInterface) && hook.location != "" {
+			// Fall back to the location from where the hook was
+			// appended.
+			name = name + " (" + hook.location + ")"
+		}
 		if hasHook && len(hook.moduleID) > 0 {
 			name = name + " (" + hook.moduleID.String() + ")"
 		}
 		return
+	case namedHook:
+		_, hasHook = getHookFuncName(hook.Hook, start)
+		return hook.name, hasHook
+	case retryHook:
+		return getHookFuncName(hook.Hook, start)
+	case *optionalHook:
+		return getHookFuncName(hook.HookInterface, start)
 	case Hook:
 		if start {
 			if hook.OnStart == nil {
@@ -233,4 +944,122 @@ func getHookFuncName(hook HookInterface, start bool) (name string, hasHook bool)
 	}
 }
 
+// levelKey identifies an object or a value group for tracking dependency
+// levels.
+type levelKey struct {
+	typ         reflect.Type
+	name, group string
+}
+
+func inputLevelKey(in internal.DigValue) levelKey {
+	if in.Group != "" && in.Type != nil && in.Type.Kind() == reflect.Slice {
+		return levelKey{in.Type.Elem(), "", in.Group}
+	}
+	return levelKey{in.Type, in.Name, in.Group}
+}
+
+func outputLevelKey(out internal.DigValue) levelKey {
+	return levelKey{out.Type, out.Name, out.Group}
+}
+
+// levelFrame is a constructor or invoke function that is being called.
+// Its dependency level is one higher than the highest level of its inputs.
+// As the inputs are constructed before the function is called the level is
+// computed lazily.
+type levelFrame struct {
+	inputs   func() []levelKey
+	level    int
+	computed bool
+
+	// inputKeys are the inputs once the level has been computed and
+	// outputs the outputs once the call is done.
+	inputKeys, outputs []levelKey
+}
+
+// currentFrame returns the call to the constructor or invoke function being
+// called and its level, or nil and -1 if none. Must be called with the mutex
+// held.
+func (lc *DefaultLifecycle) currentFrame() (*levelFrame, int) {
+	if len(lc.frames) == 0 {
+		return nil, -1
+	}
+	f := lc.frames[len(lc.frames)-1]
+	return f, lc.frameLevel(f)
+}
+
+func (lc *DefaultLifecycle) frameLevel(f *levelFrame) int {
+	if !f.computed {
+		f.computed = true
+		f.level = 0
+		f.inputKeys = f.inputs()
+		for _, in := range f.inputKeys {
+			if level, ok := lc.levels[in]; ok && level+1 > f.level {
+				f.level = level + 1
+			}
+		}
+	}
+	return f.level
+}
+
+// enterFunc marks the start of a call to a constructor or invoke function
+// with the given inputs. The returned function must be called when the call
+// is done with the outputs of the function.
+func (lc *DefaultLifecycle) enterFunc(inputs func() []levelKey) (exit func(outputs []levelKey)) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	f := &levelFrame{inputs: inputs}
+	lc.frames = append(lc.frames, f)
+	return func(outputs []levelKey) {
+		lc.mu.Lock()
+		defer lc.mu.Unlock()
+
+		level := lc.frameLevel(f)
+		if lc.levels == nil {
+			lc.levels = map[levelKey]int{}
+			lc.inputsOf = map[levelKey][]levelKey{}
+		}
+		f.outputs = outputs
+		for _, out := range outputs {
+			lc.inputsOf[out] = append(lc.inputsOf[out], f.inputKeys...)
+			// Objects of the same type may be provided in multiple scopes
+			// and value groups have many members, so take the highest level.
+			if cur, ok := lc.levels[out]; !ok || level > cur {
+				lc.levels[out] = level
+			}
+		}
+		for i := len(lc.frames) - 1; i >= 0; i-- {
+			if lc.frames[i] == f {
+				lc.frames = append(lc.frames[:i], lc.frames[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// levelTracker returns the lifecycle for tracking the dependency levels
+// of the hooks or nil if the lifecycle is not a DefaultLifecycle.
+func levelTracker(lc Lifecycle) *DefaultLifecycle {
+	switch lc := lc.(type) {
+	case *DefaultLifecycle:
+		return lc
+	case *augmentedLifecycle:
+		return lc.DefaultLifecycle
+	}
+	return nil
+}
+
+// hasFuncName returns true if the name returned by getHookFuncName for the
+// hook is explicit or includes the location of the function.
+func hasFuncName(hook HookInterface) bool {
+	switch hook := hook.(type) {
+	case Hook, namedHook, retryHook:
+		return true
+	case *optionalHook:
+		return hasFuncName(hook.HookInterface)
+	default:
+		return false
+	}
+}
+
 var _ Lifecycle = &DefaultLifecycle{}
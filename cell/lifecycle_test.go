@@ -4,12 +4,18 @@
 package cell_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/cilium/hive/cell"
 )
@@ -123,6 +129,47 @@ func TestLifecycle(t *testing.T) {
 	stopped = 0
 }
 
+func TestLifecycleStopOnlyStarted(t *testing.T) {
+	log := slog.Default()
+	var events []string
+	hook := func(name string, startErr error) cell.Hook {
+		return cell.Hook{
+			OnStart: func(cell.HookContext) error {
+				events = append(events, "start "+name)
+				return startErr
+			},
+			OnStop: func(cell.HookContext) error {
+				events = append(events, "stop "+name)
+				return nil
+			},
+		}
+	}
+
+	// The stop of the failed start hook and of the hooks after it
+	// are not executed when rolling back.
+	var lc cell.DefaultLifecycle
+	lc.Append(hook("a", nil))
+	lc.Append(hook("b", errLifecycle))
+	lc.Append(hook("c", nil))
+	assert.ErrorIs(t, lc.Start(log, context.TODO()), errLifecycle)
+	assert.NoError(t, lc.Stop(log, context.TODO()))
+	assert.Equal(t, []string{"start a", "start b", "stop a"}, events)
+
+	// The stops of succeeded start hooks are executed in reverse order.
+	events = nil
+	lc = cell.DefaultLifecycle{}
+	lc.Append(hook("a", nil))
+	lc.Append(hook("b", nil))
+	assert.NoError(t, lc.Start(log, context.TODO()))
+	assert.NoError(t, lc.Stop(log, context.TODO()))
+	assert.Equal(t, []string{"start a", "start b", "stop b", "stop a"}, events)
+
+	// Stopping again does not execute the stop hooks twice.
+	events = nil
+	assert.NoError(t, lc.Stop(log, context.TODO()))
+	assert.Empty(t, events)
+}
+
 func TestLifecycleCancel(t *testing.T) {
 	log := slog.Default()
 	ctx, cancel := context.WithCancel(context.Background())
@@ -166,3 +213,257 @@ func TestLifecycleCancel(t *testing.T) {
 	err = lc.Stop(log, ctx)
 	assert.ErrorIs(t, err, expectedErr)
 }
+
+func TestLifecycleHookTimeout(t *testing.T) {
+	log := slog.Default()
+
+	// Test a hook that completes in time.
+	lc := cell.DefaultLifecycle{HookTimeout: time.Second}
+	lc.Append(cell.Hook{
+		OnStart: func(ctx cell.HookContext) error {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok, "expected context to have a deadline")
+			return nil
+		},
+	})
+	assert.NoError(t, lc.Start(log, context.TODO()), "expected Start to succeed")
+	assert.NoError(t, lc.Stop(log, context.TODO()), "expected Stop to succeed")
+
+	// Test a start hook that blocks past the deadline and ignores the context.
+	block := make(chan struct{})
+	defer close(block)
+	lc = cell.DefaultLifecycle{HookTimeout: 10 * time.Millisecond}
+	lc.Append(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			<-block
+			return nil
+		},
+	})
+	err := lc.Start(log, context.TODO())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "start hook cell_test.TestLifecycleHookTimeout.func2")
+
+	// Test a stop hook that blocks past the deadline.
+	lc = cell.DefaultLifecycle{HookTimeout: 10 * time.Millisecond}
+	lc.Append(cell.Hook{
+		OnStop: func(ctx cell.HookContext) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	assert.NoError(t, lc.Start(log, context.TODO()), "expected Start to succeed")
+	err = lc.Stop(log, context.TODO())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stop hook cell_test.TestLifecycleHookTimeout.func3")
+	assert.ErrorContains(t, err, "timed out after 10ms")
+
+	// Test a hook that fails due to the deadline of the parent context
+	// expiring before the HookTimeout.
+	lc = cell.DefaultLifecycle{HookTimeout: time.Minute}
+	lc.Append(cell.Hook{
+		OnStart: func(ctx cell.HookContext) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = lc.Start(log, ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.NotContains(t, err.Error(), "timed out after", "expected the parent deadline not to be reported as the hook timeout")
+}
+
+func TestRetryHook(t *testing.T) {
+	log := slog.Default()
+
+	// Test eventual success after transient failures.
+	attempts := 0
+	lc := cell.DefaultLifecycle{}
+	lc.Append(cell.RetryHook(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			attempts++
+			if attempts < 3 {
+				return errLifecycle
+			}
+			return nil
+		},
+	}, 5, time.Millisecond))
+	assert.NoError(t, lc.Start(log, context.TODO()), "expected Start to succeed")
+	assert.Equal(t, 3, attempts)
+	assert.NoError(t, lc.Stop(log, context.TODO()), "expected Stop to succeed")
+
+	// Test that exhausting the attempts fails the start and that the
+	// already started hooks are stopped.
+	attempts = 0
+	lc = cell.DefaultLifecycle{}
+	lc.Append(goodHook)
+	lc.Append(cell.RetryHook(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			attempts++
+			return errLifecycle
+		},
+		OnStop: func(cell.HookContext) error {
+			t.Error("unexpected stop of the failed hook")
+			return nil
+		},
+	}, 3, time.Millisecond))
+	err := lc.Start(log, context.TODO())
+	assert.ErrorIs(t, err, errLifecycle, "expected Start to fail")
+	assert.Equal(t, 3, attempts)
+	assert.NoError(t, lc.Stop(log, context.TODO()), "expected Stop to succeed")
+	assert.Equal(t, 1, started)
+	assert.Equal(t, 1, stopped)
+	started = 0
+	stopped = 0
+
+	// Test that cancelling the context interrupts the retrying.
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	lc = cell.DefaultLifecycle{}
+	lc.Append(cell.RetryHook(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			attempts++
+			cancel()
+			return errLifecycle
+		},
+	}, 3, time.Hour))
+	err = lc.Start(log, ctx)
+	assert.ErrorIs(t, err, errLifecycle)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+
+	// Test that the backoff is waited with the lifecycle's clock.
+	attempts = 0
+	clock := &afterClock{}
+	lc = cell.DefaultLifecycle{Clock: clock}
+	lc.Append(cell.RetryHook(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			attempts++
+			if attempts < 3 {
+				return errLifecycle
+			}
+			return nil
+		},
+	}, 3, time.Hour))
+	assert.NoError(t, lc.Start(log, context.TODO()), "expected Start to succeed")
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{time.Hour, 2 * time.Hour}, clock.waits)
+}
+
+// afterClock is a clock whose After fires immediately and records the
+// durations waited for.
+type afterClock struct {
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *afterClock) Now() time.Time                  { return time.Time{} }
+func (c *afterClock) Since(t time.Time) time.Duration { return 0 }
+
+func (c *afterClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+type failingHook struct{}
+
+func (failingHook) Start(cell.HookContext) error { return nil }
+func (failingHook) Stop(cell.HookContext) error  { return errLifecycle }
+
+func TestHookNames(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, nil))
+
+	// Test that an explicit name is used in the logs and errors.
+	lc := cell.DefaultLifecycle{}
+	lc.Append(cell.HookWithName("my-hook", cell.Hook{
+		OnStart: func(cell.HookContext) error { return errLifecycle },
+	}))
+	err := lc.Start(log, context.TODO())
+	assert.ErrorIs(t, err, errLifecycle)
+	assert.ErrorContains(t, err, "start hook my-hook failed")
+	assert.Contains(t, buf.String(), `msg="Start hook failed" function=my-hook`)
+
+	// Test that the location from where the hook was appended is used
+	// if the hook has no function name.
+	buf.Reset()
+	lc = cell.DefaultLifecycle{}
+	lc.Append(failingHook{}) // appended here
+	_, _, line, _ := runtime.Caller(0)
+	location := fmt.Sprintf("lifecycle_test.go:%d", line-1)
+	assert.NoError(t, lc.Start(log, context.TODO()))
+	err = lc.Stop(log, context.TODO())
+	assert.ErrorIs(t, err, errLifecycle)
+	assert.ErrorContains(t, err, "stop hook cell_test.failingHook.Stop (")
+	assert.ErrorContains(t, err, location)
+	assert.Contains(t, buf.String(), location)
+}
+
+type hookTiming struct {
+	event, name string
+	err         error
+}
+
+type lifecycleRecorder struct {
+	timings []hookTiming
+}
+
+func (r *lifecycleRecorder) HookStart(name string, d time.Duration, err error) {
+	r.timings = append(r.timings, hookTiming{"start", name, err})
+}
+
+func (r *lifecycleRecorder) HookStop(name string, d time.Duration, err error) {
+	r.timings = append(r.timings, hookTiming{"stop", name, err})
+}
+
+func TestLifecycleMetrics(t *testing.T) {
+	log := slog.Default()
+	recorder := &lifecycleRecorder{}
+	lc := cell.DefaultLifecycle{Metrics: recorder}
+	lc.Append(cell.HookWithName("first", cell.Hook{
+		OnStart: func(cell.HookContext) error { return nil },
+		OnStop:  func(cell.HookContext) error { return errLifecycle },
+	}))
+	lc.Append(cell.HookWithName("second", cell.Hook{
+		OnStart: func(cell.HookContext) error { return nil },
+	}))
+	lc.Append(cell.HookWithName("third", cell.Hook{
+		OnStart: func(cell.HookContext) error { return errLifecycle },
+	}))
+
+	assert.ErrorIs(t, lc.Start(log, context.TODO()), errLifecycle)
+	assert.ErrorIs(t, lc.Stop(log, context.TODO()), errLifecycle)
+
+	assert.Equal(t,
+		[]hookTiming{
+			{"start", "first", nil},
+			{"start", "second", nil},
+			{"start", "third", errLifecycle},
+			{"stop", "first", errLifecycle},
+		},
+		recorder.timings)
+}
+
+func TestLifecycleAppendAfterStart(t *testing.T) {
+	log := slog.Default()
+	var lc cell.DefaultLifecycle
+	lc.Append(goodHook)
+	require.NoError(t, lc.Start(log, context.TODO()))
+
+	defer func() {
+		err := recover()
+		require.NotNil(t, err, "expected Append after Start to panic")
+		assert.Contains(t, err, "cell.Lifecycle.Append called at")
+		assert.Contains(t, err, "lifecycle_test.go:")
+		assert.Contains(t, err, "after the lifecycle was started")
+
+		// Appending is allowed again after stopping.
+		require.NoError(t, lc.Stop(log, context.TODO()))
+		lc.Append(goodHook)
+	}()
+	lc.Append(goodHook)
+}
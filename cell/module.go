@@ -4,6 +4,7 @@
 package cell
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
@@ -28,9 +29,131 @@ import (
 //
 // Private constructors with a module (ProvidePrivate) are only accessible
 // within this module and its sub-modules.
+//
+// The *slog.Logger provided within the module has the "module" attribute set
+// to the full module ID, e.g. "agent.endpoint-manager" for module
+// "endpoint-manager" nested in module "agent". Use RootLogger for a logger
+// without the attribute.
+//
+// To prefix the flags of the config cells in the module with the module ID,
+// include WithFlagPrefix() in the cells. To allow enabling and disabling the
+// module with a flag, include WithEnableFlag().
 func Module(id, description string, cells ...Cell) Cell {
 	validateIDAndDescription(id, description)
-	return &module{id, description, cells}
+	m := &module{id: id, description: description}
+	for _, cell := range cells {
+		switch opt := cell.(type) {
+		case flagPrefixOption:
+			m.flagPrefix = true
+		case enableFlagOption:
+			m.enableFlag = &enableFlag{moduleID: id, enabledByDefault: opt.enabledByDefault}
+		default:
+			m.cells = append(m.cells, cell)
+		}
+	}
+	return m
+}
+
+// WithFlagPrefix when given to Module prefixes the flags registered by the
+// config cells in the module and in its sub-modules with the module ID, e.g.
+// flag "timeout" in module "foo" becomes "foo-timeout". With nested modules
+// the prefixes are compounded, e.g. "foo-bar-timeout". The fields of the
+// config structs are matched with the unprefixed flag names.
+func WithFlagPrefix() Cell {
+	return flagPrefixOption{}
+}
+
+type flagPrefixOption struct{}
+
+func (flagPrefixOption) Apply(*slog.Logger, Container, time.Duration) error { return nil }
+func (flagPrefixOption) Info(Container) Info                                { return NewInfoNode("") }
+
+// flagPrefixContainer is the container given to the cells within a module
+// with a flag prefix, within a module with an enable flag or within an
+// optional cell.
+type flagPrefixContainer struct {
+	Container
+	prefix string
+
+	// disabled if not nil returns an error if the module or a module it is
+	// nested in has been disabled with its enable flag, or if the optional
+	// cell it is nested in has been skipped.
+	disabled func() error
+}
+
+// Provide provides the constructor. If the cells can be disabled the
+// constructor is only provided when the hive is populated and if they have
+// not been disabled.
+func (c flagPrefixContainer) Provide(ctor any, opts ...dig.ProvideOption) error {
+	if c.disabled == nil {
+		return c.Container.Provide(ctor, opts...)
+	}
+	return c.deferProvide(
+		func() error {
+			if err := c.disabled(); err != nil {
+				return &DisabledProviderError{Outputs: outputsOf(ctor, opts), Err: err}
+			}
+			return c.Container.Provide(ctor, opts...)
+		},
+		func() error {
+			return c.Container.Provide(gateConstructor(ctor, c.disabled), opts...)
+		})
+}
+
+// Decorate decorates the objects. If the cells can be disabled the
+// decorator is only added when the hive is populated and if they have not
+// been disabled.
+func (c flagPrefixContainer) Decorate(fn any, opts ...dig.DecorateOption) error {
+	if c.disabled == nil {
+		return c.Container.Decorate(fn, opts...)
+	}
+	return c.deferProvide(
+		func() error {
+			if c.disabled() != nil {
+				return nil
+			}
+			return c.Container.Decorate(fn, opts...)
+		},
+		func() error {
+			return c.Container.Decorate(fn, opts...)
+		})
+}
+
+// deferProvide appends the provide function to be called when the hive is
+// populated, or calls now if the container has no InvokerList.
+func (c flagPrefixContainer) deferProvide(provide, now func() error) error {
+	err := c.Container.Invoke(func(l InvokerList) {
+		appendDeferredProvide(l, provide)
+	})
+	if err != nil {
+		return now()
+	}
+	return nil
+}
+
+// moduleDisabled returns the function for checking whether the module the
+// container is for has been disabled, or nil if it cannot be disabled.
+func moduleDisabled(c Container) func() error {
+	if c, ok := c.(flagPrefixContainer); ok {
+		return c.disabled
+	}
+	return nil
+}
+
+// flagPrefix returns the prefix for the flags registered to the container.
+func flagPrefix(c Container) string {
+	if c, ok := c.(flagPrefixContainer); ok {
+		return c.prefix
+	}
+	return ""
+}
+
+// withFlagPrefix returns the container with the flag prefix.
+func withFlagPrefix(c Container, prefix string) Container {
+	if prefix == "" {
+		return c
+	}
+	return flagPrefixContainer{c, prefix, nil}
 }
 
 // ModuleID is the module identifier. Provided in the module's scope.
@@ -97,6 +220,13 @@ type module struct {
 	// alongside the identifier.
 	description string
 
+	// flagPrefix if true prefixes the flags of the config cells in the
+	// module with the module ID.
+	flagPrefix bool
+
+	// enableFlag if not nil is the flag for enabling the module.
+	enableFlag *enableFlag
+
 	cells []Cell
 }
 
@@ -118,11 +248,13 @@ func (m *module) lifecycle(lc Lifecycle, fullID FullModuleID) Lifecycle {
 		return &augmentedLifecycle{
 			lc,
 			fullID,
+			nil,
 		}
 	case *augmentedLifecycle:
 		return &augmentedLifecycle{
 			lc.DefaultLifecycle,
 			fullID,
+			lc.optional,
 		}
 	default:
 		return lc
@@ -134,7 +266,7 @@ type moduleDecoratorParams struct {
 	ModuleDecorators ModuleDecorators
 }
 
-func (m *module) moduleDecorators(scope *dig.Scope) error {
+func (m *module) moduleDecorators(scope Container) error {
 	provide := func(p moduleDecoratorParams) error {
 		for _, d := range p.ModuleDecorators {
 			if err := scope.Decorate(d); err != nil {
@@ -151,7 +283,7 @@ type modulePrivateProviderParams struct {
 	ModulePrivateProviders ModulePrivateProviders
 }
 
-func (m *module) modulePrivateProviders(scope *dig.Scope) error {
+func (m *module) modulePrivateProviders(scope Container) error {
 	provide := func(p modulePrivateProviderParams) error {
 		for _, d := range p.ModulePrivateProviders {
 			if err := scope.Provide(d); err != nil {
@@ -163,16 +295,49 @@ func (m *module) modulePrivateProviders(scope *dig.Scope) error {
 	return scope.Invoke(provide)
 }
 
-func (m *module) Apply(log *slog.Logger, c container, logThreshold time.Duration) error {
-	scope := c.Scope(m.id)
+// MaxModuleDepth if positive is the maximum depth of nested modules, e.g.
+// module "bar" in module "foo" is at depth 2. Applying a module nested deeper
+// fails with an error naming the module.
+// Supplied with [hive.Options] field 'MaxModuleDepth'.
+type MaxModuleDepth int
+
+type moduleDepthParams struct {
+	In
+	ID       FullModuleID   `optional:"true"`
+	MaxDepth MaxModuleDepth `optional:"true"`
+}
+
+// checkDepth returns an error if the module is nested deeper than the
+// maximum module depth.
+func (m *module) checkDepth(c Container) error {
+	var err error
+	invokeErr := c.Invoke(func(p moduleDepthParams) {
+		fullID := m.fullModuleID(p.ID)
+		if p.MaxDepth > 0 && len(fullID) > int(p.MaxDepth) {
+			err = fmt.Errorf("module %s is nested %d deep, exceeding the maximum module depth %d",
+				fullID, len(fullID), p.MaxDepth)
+		}
+	})
+	return errors.Join(invokeErr, err)
+}
+
+func (m *module) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	if err := m.checkDepth(c); err != nil {
+		return err
+	}
+
+	scope := newScope(c, m.id)
 
-	// Provide ModuleID and FullModuleID in the module's scope.
+	// Provide ModuleID, FullModuleID and the description in the module's scope.
 	if err := scope.Provide(m.moduleID); err != nil {
 		return err
 	}
 	if err := scope.Decorate(m.fullModuleID); err != nil {
 		return err
 	}
+	if err := scope.Provide(func() moduleDescription { return moduleDescription(m.description) }); err != nil {
+		return err
+	}
 
 	if err := scope.Decorate(m.lifecycle); err != nil {
 		return err
@@ -190,16 +355,52 @@ func (m *module) Apply(log *slog.Logger, c container, logThreshold time.Duration
 		return err
 	}
 
-	for _, cell := range m.cells {
-		if err := cell.Apply(log, scope, logThreshold); err != nil {
+	prefix := flagPrefix(c)
+	disabled := moduleDisabled(c)
+	if m.enableFlag != nil {
+		if err := m.enableFlag.register(scope, prefix); err != nil {
+			return err
+		}
+		disabled = m.enableFlag.disabled(scope, prefix, disabled)
+		own := m.enableFlag.disabled(scope, prefix, nil)
+		err := scope.Decorate(func(l InvokerList) InvokerList {
+			return gatedInvokerList{l, own}
+		})
+		if err != nil {
 			return err
 		}
 	}
-	return nil
+	if m.flagPrefix {
+		prefix += m.id + "-"
+	}
+	cont := scope
+	if prefix != "" || disabled != nil {
+		cont = flagPrefixContainer{scope, prefix, disabled}
+	}
+
+	// Apply all the cells even if some fail to report all the errors at once.
+	var errs []error
+	for _, cell := range m.cells {
+		if err := cell.Apply(log, cont, logThreshold); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (m *module) Info(c container) Info {
-	n := NewInfoNode("Ⓜ️ " + m.id + " (" + m.description + ")")
+func (m *module) Info(c Container) Info {
+	if m.enableFlag != nil {
+		if enabled, _ := m.enableFlag.enabled(c, flagPrefix(c)); !enabled {
+			return NewInfoNode("")
+		}
+	}
+	n := newInfoNode("Ⓜ️", m.id+" ("+m.description+")")
+	n.module = &ModuleInfo{ID: m.id, Description: m.description}
+	prefix := flagPrefix(c)
+	if m.flagPrefix {
+		prefix += m.id + "-"
+	}
+	c = withFlagPrefix(c, prefix)
 	for _, cell := range m.cells {
 		n.Add(cell.Info(c))
 	}
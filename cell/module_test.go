@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+type Config struct {
+	Foo string
+	Bar int
+}
+
+func (Config) Flags(flags *pflag.FlagSet) {
+	flags.String("foo", "hello world", "sets the greeting")
+	flags.Int("bar", 123, "bar")
+}
+
+type FooTimeoutConfig struct{ Timeout time.Duration }
+type BarTimeoutConfig struct{ Timeout time.Duration }
+
+func (FooTimeoutConfig) Flags(flags *pflag.FlagSet) {
+	flags.Duration("timeout", time.Second, "foo timeout")
+}
+
+func (BarTimeoutConfig) Flags(flags *pflag.FlagSet) {
+	flags.Duration("timeout", time.Second, "bar timeout")
+}
+
+func TestModuleFlagPrefix(t *testing.T) {
+	var (
+		fooCfg FooTimeoutConfig
+		barCfg BarTimeoutConfig
+		cfg    Config
+	)
+	h := hive.New(
+		cell.Module("foo", "Foo",
+			cell.WithFlagPrefix(),
+			cell.Config(FooTimeoutConfig{}),
+			cell.Invoke(func(c FooTimeoutConfig) { fooCfg = c }),
+		),
+		cell.Module("outer", "Outer",
+			cell.WithFlagPrefix(),
+			cell.Module("bar", "Bar",
+				cell.WithFlagPrefix(),
+				cell.Config(BarTimeoutConfig{}),
+				cell.Invoke(func(c BarTimeoutConfig) { barCfg = c }),
+			),
+			// Nested module without its own prefix inherits the prefix.
+			cell.Module("inner", "Inner",
+				cell.Config(Config{}),
+				cell.Invoke(func(c Config) { cfg = c }),
+			),
+		),
+	)
+
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--foo-timeout=2s", "--outer-bar-timeout=3s", "--outer-foo=test"}))
+	require.NoError(t, h.Populate(), "Populate")
+
+	assert.Equal(t, 2*time.Second, fooCfg.Timeout)
+	assert.Equal(t, 3*time.Second, barCfg.Timeout)
+	assert.Equal(t, "test", cfg.Foo)
+	assert.Nil(t, flags.Lookup("timeout"), "expected unprefixed flag to not be registered")
+}
+
+func TestModuleID(t *testing.T) {
+	invoked := false
+	inner := cell.Module(
+		"inner",
+		"inner module",
+		cell.Invoke(func(id cell.ModuleID, fid cell.FullModuleID) error {
+			invoked = true
+			if id != "inner" {
+				return fmt.Errorf("inner id mismatch, expected 'inner', got %q", id)
+			}
+			if fid.String() != "outer.inner" {
+				return fmt.Errorf("outer id mismatch, expected 'outer.inner', got %q", fid)
+			}
+			return nil
+		}),
+	)
+
+	outer := cell.Module(
+		"outer",
+		"outer module",
+		inner,
+	)
+
+	err := hive.New(
+		outer,
+		shutdownOnStartCell,
+	).Run()
+	assert.NoError(t, err, "expected Run to succeed")
+
+	assert.True(t, invoked, "expected invoke to be called, but it was not")
+}
+
+func TestModulePrivateProvidersDecorators(t *testing.T) {
+	var intCalled bool
+	opts := hive.DefaultOptions()
+	opts.ModuleDecorators = cell.ModuleDecorators{
+		func(n int) int { intCalled = true; return n + 1 },
+		func(base string, mod cell.ModuleID) string { return base + string(mod) },
+	}
+	opts.ModulePrivateProviders = cell.ModulePrivateProviders{
+		func() float64 { return 3.1415 },
+	}
+
+	var stringContents string
+	var floatContents float64
+	h := hive.NewWithOptions(opts,
+		cell.Provide(
+			// Things to decorate
+			func() int { return 1 },
+			func() string { return "hello, " },
+		),
+		cell.Module("test", "test",
+			cell.Invoke(func(s string, f float64) {
+				stringContents = s
+				floatContents = f
+			}),
+		))
+
+	require.NoError(t, h.Populate())
+	require.Equal(t, "hello, test", stringContents)
+	require.Equal(t, 3.1415, floatContents)
+	require.False(t, intCalled, "did not expect unreferenced module decorator to be called")
+}
+
+func TestModuleLogger(t *testing.T) {
+	var buf bytes.Buffer
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := hive.NewWithOptions(
+		opts,
+		cell.Module("outer", "Outer",
+			cell.Module("inner", "Inner",
+				cell.Provide(func(log *slog.Logger) *SomeObject {
+					log.Info("Constructing inner")
+					return &SomeObject{}
+				}),
+			),
+			cell.Invoke(func(log *slog.Logger, root cell.RootLogger, _ *SomeObject) {
+				log.Info("Invoking outer")
+				(*slog.Logger)(root).Info("Invoking root")
+			}),
+		),
+	)
+	require.NoError(t, h.Populate())
+
+	modules := map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		modules[rec["msg"].(string)] = rec["module"]
+	}
+	assert.Equal(t, "outer.inner", modules["Constructing inner"])
+	assert.Equal(t, "outer", modules["Invoking outer"])
+	assert.Contains(t, modules, "Invoking root")
+	assert.Nil(t, modules["Invoking root"])
+}
+
+func TestMaxModuleDepth(t *testing.T) {
+	opts := hive.DefaultOptions()
+	opts.MaxModuleDepth = 2
+	nested := func(inner ...cell.Cell) cell.Cell {
+		return cell.Module("outer", "Outer",
+			cell.Module("middle", "Middle", inner...),
+		)
+	}
+
+	h := hive.NewWithOptions(opts, nested(cell.Provide(newSome)))
+	require.NoError(t, h.Populate())
+
+	var msg string
+	func() {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.NewWithOptions(opts, nested(cell.Module("inner", "Inner")))
+	}()
+	assert.Contains(t, msg, "module outer.middle.inner is nested 3 deep, exceeding the maximum module depth 2")
+}
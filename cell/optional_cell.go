@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cilium/hive/internal"
+)
+
+// OptionalCell constructs a cell whose failures do not abort the hive, e.g. for
+// plugins that should not prevent the rest of the hive from starting. If
+// applying the cell fails, or one of its invoke functions or config checks
+// fails, or one of its start hooks fails, the failure is logged and recorded
+// and the rest of the cell is skipped: its remaining invoke functions and
+// start hooks are not run. The skipped cells are reported by
+// hive.SkippedCells.
+//
+//	cell.OptionalCell(cell.Module("plugin-foo", "Foo plugin", ...))
+//
+// The objects of the cell are only added to the hive when it is populated
+// and if the cell has not been skipped by then, so a cell that fails to be
+// applied contributes nothing to the hive and its dependents are not wired
+// to it: the optional dependencies on its objects are not filled and the
+// dependents requiring them fail with an error naming the skipped cell.
+// Dependents of an object whose constructor fails fail with its error,
+// which skips them if they are in an optional cell themselves.
+func OptionalCell(c Cell) Cell {
+	return &optionalCell{cell: c}
+}
+
+type optionalCell struct {
+	cell Cell
+}
+
+// skippedCellRecorder is optionally implemented by InvokerList to record
+// the optional cells that were skipped.
+type skippedCellRecorder interface {
+	RecordSkippedCell(name string, err error)
+}
+
+// optionalState tracks whether an optional cell has been skipped in a hive.
+type optionalState struct {
+	name     string
+	log      *slog.Logger
+	recorder skippedCellRecorder
+	parent   *optionalState
+
+	mu  sync.Mutex
+	err error
+}
+
+// skipped returns true if the optional cell or the optional cell it is
+// nested in has been skipped.
+func (s *optionalState) skipped() bool {
+	if s.parent != nil && s.parent.skipped() {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err != nil
+}
+
+// skip marks the optional cell as skipped because of the error. Only the
+// first error is recorded.
+func (s *optionalState) skip(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	s.log.Warn("Optional cell skipped", "cell", s.name, "error", err)
+	if s.recorder != nil {
+		s.recorder.RecordSkippedCell(s.name, err)
+	}
+}
+
+type optionalParams struct {
+	In
+	InvokerList InvokerList
+	Lifecycle   Lifecycle `optional:"true"`
+}
+
+func (o *optionalCell) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	state := &optionalState{name: cellName(o.cell), log: log}
+	err := c.Invoke(func(p optionalParams) {
+		state.recorder, _ = p.InvokerList.(skippedCellRecorder)
+		if ol, ok := p.InvokerList.(optionalInvokerList); ok {
+			state.parent = ol.state
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	scope := newScope(c, "optional")
+	err = scope.Decorate(func(l InvokerList) InvokerList {
+		return optionalInvokerList{l, state}
+	})
+	if err != nil {
+		return err
+	}
+	if err := scope.Decorate(state.lifecycle); err != nil {
+		return err
+	}
+
+	// Defer providing the objects of the cell until the hive is populated
+	// in order to not provide any of them if the cell fails to be applied.
+	cont := flagPrefixContainer{scope, flagPrefix(c), state.disabled(moduleDisabled(c))}
+	if err := o.cell.Apply(log, cont, logThreshold); err != nil {
+		state.skip(err)
+	}
+	return nil
+}
+
+func (o *optionalCell) Info(c Container) Info {
+	return o.cell.Info(c)
+}
+
+// cellName returns a name for the cell for reporting it, e.g. the module ID
+// or the name of the first constructor.
+func cellName(c Cell) string {
+	switch c := c.(type) {
+	case *module:
+		return "module " + c.id
+	case *provider:
+		if len(c.ctors) > 0 {
+			return internal.FuncNameAndLocation(c.ctors[0])
+		}
+	case *invoker:
+		if len(c.funcs) > 0 {
+			return c.funcs[0].name
+		}
+	}
+	return internal.PrettyType(c)
+}
+
+// disabled returns a function that returns an error if the optional cell has
+// been skipped, or if the cell or module it is nested in has been.
+func (s *optionalState) disabled(parent func() error) func() error {
+	return func() error {
+		if parent != nil {
+			if err := parent(); err != nil {
+				return err
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.err != nil {
+			return fmt.Errorf("optional cell %s was skipped: %w", s.name, s.err)
+		}
+		return nil
+	}
+}
+
+// lifecycle wraps the hooks appended within the optional cell to skip the
+// cell on failure.
+func (s *optionalState) lifecycle(lc Lifecycle) Lifecycle {
+	switch lc := lc.(type) {
+	case *DefaultLifecycle:
+		return &augmentedLifecycle{lc, nil, s}
+	case *augmentedLifecycle:
+		return &augmentedLifecycle{lc.DefaultLifecycle, lc.moduleID, s}
+	default:
+		return lc
+	}
+}
+
+// optionalHook is a hook appended within an optional cell. A failing start
+// skips the cell instead of failing the start of the hive, and the hooks are
+// not started after the cell has been skipped.
+type optionalHook struct {
+	HookInterface
+	state *optionalState
+
+	// notStarted is true if the hook was skipped or its start failed, in
+	// which case it is not stopped.
+	notStarted bool
+}
+
+func (h *optionalHook) Start(ctx HookContext) error {
+	if h.state.skipped() {
+		h.notStarted = true
+		return nil
+	}
+	if err := h.HookInterface.Start(ctx); err != nil {
+		h.notStarted = true
+		h.state.skip(fmt.Errorf("start hook failed: %w", err))
+	}
+	return nil
+}
+
+func (h *optionalHook) Stop(ctx HookContext) error {
+	if h.notStarted {
+		return nil
+	}
+	return h.HookInterface.Stop(ctx)
+}
+
+// optionalInvokerList is the InvokerList within an optional cell. A failing
+// invoke function or config check skips the cell instead of failing the
+// hive, and the invoke functions are not invoked after the cell has been
+// skipped.
+type optionalInvokerList struct {
+	InvokerList
+	state *optionalState
+}
+
+func (l optionalInvokerList) guard(invoke func() error) func() error {
+	return func() error {
+		if l.state.skipped() {
+			return nil
+		}
+		if err := invoke(); err != nil {
+			l.state.skip(err)
+		}
+		return nil
+	}
+}
+
+func (l optionalInvokerList) AppendInvoke(invoke func() error) {
+	l.InvokerList.AppendInvoke(l.guard(invoke))
+}
+
+func (l optionalInvokerList) AppendInvokeAfter(invoke func() error, handle InvokeHandle, after []InvokeHandle) {
+	if ol, ok := l.InvokerList.(OrderedInvokerList); ok {
+		ol.AppendInvokeAfter(l.guard(invoke), handle, after)
+	} else {
+		l.InvokerList.AppendInvoke(l.guard(invoke))
+	}
+}
+
+func (l optionalInvokerList) AppendConfigCheck(check func() error) {
+	if checker, ok := l.InvokerList.(configChecker); ok {
+		checker.AppendConfigCheck(l.guard(check))
+	} else {
+		l.InvokerList.AppendInvoke(l.guard(check))
+	}
+}
+
+func (l optionalInvokerList) AppendConfigReload(reload func(AllSettings) (func(), error)) {
+	reloader, ok := l.InvokerList.(configReloader)
+	if !ok {
+		l.AppendInvoke(errConfigReloadUnsupported)
+		return
+	}
+	reloader.AppendConfigReload(func(settings AllSettings) (func(), error) {
+		// The objects of a skipped cell are not in the hive.
+		if l.state.skipped() {
+			return func() {}, nil
+		}
+		return reload(settings)
+	})
+}
+
+func (l optionalInvokerList) AppendDeferredProvide(provide func() error) {
+	appendDeferredProvide(l.InvokerList, func() error {
+		err := provide()
+		var disabledErr *DisabledProviderError
+		if errors.As(err, &disabledErr) {
+			return err
+		}
+		if err != nil {
+			l.state.skip(err)
+		}
+		return nil
+	})
+}
+
+func (l optionalInvokerList) RecordSkippedCell(name string, err error) {
+	if recorder, ok := l.InvokerList.(skippedCellRecorder); ok {
+		recorder.RecordSkippedCell(name, err)
+	}
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestOptionalCell(t *testing.T) {
+	var (
+		invoked, started, stopped []string
+		brokenErr                 = errors.New("broken")
+	)
+	hook := func(lc cell.Lifecycle, name string, startErr error) {
+		lc.Append(cell.Hook{
+			OnStart: func(cell.HookContext) error {
+				started = append(started, name)
+				return startErr
+			},
+			OnStop: func(cell.HookContext) error {
+				stopped = append(stopped, name)
+				return nil
+			},
+		})
+	}
+
+	rec := &logRecorder{}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(rec)
+	h := hive.NewWithOptions(opts,
+		cell.Module("core", "Core",
+			cell.Provide(newSome),
+			cell.Invoke(func(lc cell.Lifecycle, _ *SomeObject) {
+				invoked = append(invoked, "core")
+				hook(lc, "core", nil)
+			}),
+		),
+
+		// The constructor fails: the invoke functions depending on it
+		// and the rest of the module are skipped.
+		cell.OptionalCell(cell.Module("broken-ctor", "Broken constructor",
+			cell.Provide(func() (*OtherObject, error) { return nil, brokenErr }),
+			cell.Invoke(func(*OtherObject) { invoked = append(invoked, "broken-ctor") }),
+			cell.Invoke(func(lc cell.Lifecycle) {
+				invoked = append(invoked, "broken-ctor-2")
+				hook(lc, "broken-ctor", nil)
+			}),
+		)),
+
+		// The start hook fails: it is not stopped but the cells started
+		// before it still are.
+		cell.OptionalCell(cell.Module("broken-start", "Broken start",
+			cell.Invoke(func(lc cell.Lifecycle) {
+				invoked = append(invoked, "broken-start")
+				hook(lc, "broken-start-1", nil)
+				hook(lc, "broken-start-2", brokenErr)
+				hook(lc, "broken-start-3", nil)
+			}),
+		)),
+
+		// Applying the cell fails.
+		cell.OptionalCell(cell.ProvideAs(func() *ThirdObject { return nil }, new(Greeter))),
+
+		cell.OptionalCell(cell.Module("working", "Working",
+			cell.Invoke(func(lc cell.Lifecycle, _ *SomeObject) {
+				invoked = append(invoked, "working")
+				hook(lc, "working", nil)
+			}),
+		)),
+	)
+
+	require.NoError(t, h.Start(context.TODO()))
+	assert.Equal(t, []string{"core", "broken-start", "working"}, invoked)
+	assert.Equal(t, []string{"core", "broken-start-1", "broken-start-2", "working"}, started)
+
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.ElementsMatch(t, []string{"working", "broken-start-1", "core"}, stopped)
+
+	skipped := h.SkippedCells()
+	require.Len(t, skipped, 3)
+	assert.True(t, strings.HasPrefix(skipped[0].Name, "cell_test.TestOptionalCell.func"), skipped[0].Name)
+	assert.ErrorContains(t, skipped[0].Err, "does not implement cell_test.Greeter")
+	assert.Equal(t, "module broken-ctor", skipped[1].Name)
+	assert.ErrorIs(t, skipped[1].Err, brokenErr)
+	assert.Equal(t, "module broken-start", skipped[2].Name)
+	assert.ErrorIs(t, skipped[2].Err, brokenErr)
+	assert.ErrorContains(t, skipped[2].Err, "start hook failed")
+
+	assert.Len(t, rec.find(slog.LevelWarn, "Optional cell skipped", "cell"), 3)
+	assert.Equal(t,
+		[]string{"[" + skipped[0].Name + " module broken-ctor module broken-start]"},
+		rec.find(slog.LevelWarn, "Started with skipped optional cells", "skipped"))
+
+	// A cell that fails to be applied contributes nothing to the hive,
+	// including the objects provided before the failure: the optional
+	// dependencies on them are not filled and the required ones fail
+	// naming the skipped cell.
+	type optionalParams struct {
+		cell.In
+		Other *OtherObject `optional:"true"`
+	}
+	partial := cell.OptionalCell(cell.Module("partial", "Partially applied",
+		cell.Provide(func() *OtherObject { return &OtherObject{} }),
+		cell.ProvideAs(func() *ThirdObject { return nil }, new(Greeter)),
+	))
+	var other *OtherObject
+	h = hive.New(partial, cell.Invoke(func(p optionalParams) { other = p.Other }))
+	require.NoError(t, h.Populate())
+	assert.Nil(t, other, "expected the object of the skipped cell not to be wired")
+	h = hive.New(partial, cell.Invoke(func(*OtherObject) {}))
+	assert.ErrorContains(t, h.Populate(), "optional cell module partial was skipped")
+
+	// The configs of a skipped cell are not reloaded.
+	h = hive.New(cell.OptionalCell(cell.Group(
+		cell.ReloadableConfig(ReloadConfig{}),
+		cell.ProvideAs(func() *ThirdObject { return nil }, new(Greeter)),
+	)))
+	require.NoError(t, h.Populate())
+	require.Len(t, h.SkippedCells(), 1)
+	assert.NoError(t, h.Reload())
+
+	// Failures outside the optional cells still abort.
+	h = hive.New(
+		cell.OptionalCell(cell.Invoke(func() {})),
+		cell.Invoke(func() error { return brokenErr }),
+	)
+	assert.ErrorIs(t, h.Start(context.TODO()), brokenErr)
+}
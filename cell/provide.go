@@ -4,8 +4,11 @@
 package cell
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -22,66 +25,337 @@ type provider struct {
 	infosMu sync.Mutex
 	infos   []dig.ProvideInfo
 	export  bool
+
+	// filled is true for the constructors whose info has been filled.
+	filled []bool
+
+	// info is the Info of the constructors, cached once the infos of all
+	// of them have been filled as they do not change afterwards.
+	info *InfoNode
+
+	// opts are additional options given to dig when providing the
+	// constructors, e.g. dig.Name.
+	opts []dig.ProvideOption
+
+	// eager if true forces the constructors to be invoked when the
+	// hive is populated even if nothing depends on them.
+	eager bool
+
+	// logThreshold if set overrides the hive's log threshold for
+	// these constructors.
+	logThreshold    time.Duration
+	hasLogThreshold bool
+
+	// pure if true allows the results of the constructors to be cached
+	// with the ConstructorCache.
+	pure bool
+
+	// onFirstUse if not nil is called after the constructor has been
+	// called successfully.
+	onFirstUse func()
+
+	// as are the interface types the object returned by the constructor
+	// is additionally provided as.
+	as []reflect.Type
+
+	// location is where the cell was constructed, e.g. "foo/cells.go:12",
+	// for describing invalid constructors.
+	location string
 }
 
-func (p *provider) Apply(log *slog.Logger, c container, logThreshold time.Duration) error {
-	// Since the same Provide cell may be used multiple times
-	// in different hives we use a mutex to protect it and we
-	// fill the provide info only the first time.
+func (p *provider) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	// Since the same Provide cell may be used multiple times in different
+	// hives, possibly concurrently, we use a mutex to protect it and we fill
+	// the provide info of each constructor only the first time it is
+	// successfully provided. The mutex is held for the duration of Apply so
+	// that another hive never observes a partially filled info.
 	p.infosMu.Lock()
 	defer p.infosMu.Unlock()
 
-	fillInfo := false
 	if p.infos == nil {
 		p.infos = make([]dig.ProvideInfo, len(p.ctors))
-		fillInfo = true
+		p.filled = make([]bool, len(p.ctors))
+	}
+
+	if err := p.validate(); err != nil {
+		return err
 	}
 
+	if p.hasLogThreshold {
+		logThreshold = p.logThreshold
+	}
+
+	w := ctorWrapper{log: log, logThreshold: logThreshold, onConstructed: p.onFirstUse}
+	if err := w.fill(c, p.pure); err != nil {
+		return err
+	}
+
+	// Provide all the constructors even if some fail to report all the
+	// errors at once.
+	var errs []error
 	for i, ctor := range p.ctors {
-		opts := []dig.ProvideOption{dig.Export(p.export)}
+		fillInfo := !p.filled[i]
+		opts := append([]dig.ProvideOption{dig.Export(p.export)}, p.opts...)
 		if fillInfo {
 			opts = append(opts, dig.FillProvideInfo(&p.infos[i]))
 		}
-		if err := c.Provide(ctor, opts...); err != nil {
-			return err
+		if p.pure {
+			w.cacheKey = &p.ctors[i]
+		}
+		wrapped, ctorOpts := w.wrap(ctor, &p.infos[i].Inputs, &p.infos[i].Outputs)
+		opts = append(opts, ctorOpts...)
+		if err := c.Provide(wrapped, opts...); err != nil {
+			if fillInfo {
+				// dig does not fill the info when the constructor cannot be
+				// provided, e.g. when it introduces a cycle or provides an
+				// already provided type. Fill it from an empty container for
+				// the hive to describe the error.
+				infoOpts := append([]dig.ProvideOption{dig.FillProvideInfo(&p.infos[i])}, p.opts...)
+				dig.New().Provide(wrapped, infoOpts...)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", internal.FuncNameAndLocation(ctor), err))
+			continue
+		}
+		p.filled[i] = true
+		if err := p.provideAs(c, ctor); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", internal.FuncNameAndLocation(ctor), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if p.eager {
+		fns := make([]any, len(p.infos))
+		for i := range p.infos {
+			fns[i] = eagerInvokeFunc(&p.infos[i], reflect.TypeOf(p.ctors[i]))
 		}
+		return c.Invoke(func(l InvokerList) {
+			l.AppendInvoke(func() error {
+				for _, fn := range fns {
+					if err := c.Invoke(fn); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
 	}
 	return nil
 }
 
-func (p *provider) Info(container) Info {
+// validate checks that the constructors are functions returning at least
+// one value other than an error, in order to fail with an error naming the
+// invalid constructor and where it was given instead of dig's generic one.
+func (p *provider) validate() error {
+	var errs []error
+	for i, ctor := range p.ctors {
+		typ := reflect.TypeOf(ctor)
+		var err error
+		switch {
+		case typ == nil:
+			err = fmt.Errorf("nil is not a function")
+		case typ.Kind() != reflect.Func:
+			err = fmt.Errorf("%s is not a function", typ)
+		case typ.NumOut() == 0:
+			err = fmt.Errorf("%s %s returns no values, a constructor must return at least one",
+				internal.FuncNameAndLocation(ctor), typ)
+		case typ.NumOut() == 1 && typ.Out(0) == errorType:
+			err = fmt.Errorf("%s %s returns only an error, a constructor must return at least one other value. "+
+				"Use cell.Invoke for functions returning only an error", internal.FuncNameAndLocation(ctor), typ)
+		default:
+			continue
+		}
+		errs = append(errs, fmt.Errorf("invalid constructor at index %d given at %s: %w", i, p.location, err))
+	}
+	return errors.Join(errs...)
+}
+
+// eagerInvokeFunc constructs a function that depends on all the outputs of
+// a constructor of type ctorType. Invoking it forces the constructor to be
+// called.
+func eagerInvokeFunc(info *dig.ProvideInfo, ctorType reflect.Type) any {
+	fields := []reflect.StructField{
+		{Name: "In", Type: reflect.TypeOf(In{}), Anonymous: true},
+	}
+	for i, output := range info.Outputs {
+		out := internal.DigOutput(output, ctorType)
+		field := reflect.StructField{
+			Name: fmt.Sprintf("Out%d", i),
+			Type: out.Type,
+		}
+		switch {
+		case out.Group != "":
+			// Depending on a value group constructs all members of the group.
+			field.Type = reflect.SliceOf(out.Type)
+			field.Tag = reflect.StructTag(fmt.Sprintf("group:%q", out.Group))
+		case out.Name != "":
+			field.Tag = reflect.StructTag(fmt.Sprintf("name:%q", out.Name))
+		}
+		fields = append(fields, field)
+	}
+	fnType := reflect.FuncOf([]reflect.Type{reflect.StructOf(fields)}, nil, false)
+	return reflect.MakeFunc(fnType, func([]reflect.Value) []reflect.Value {
+		return nil
+	}).Interface()
+}
+
+func (p *provider) Info(Container) Info {
 	p.infosMu.Lock()
 	defer p.infosMu.Unlock()
 
-	n := &InfoNode{}
+	if p.info != nil {
+		return p.info
+	}
+
+	// Present the constructors in a deterministic order regardless of
+	// the order they were given in.
+	infos := make([]*ProviderInfo, len(p.ctors))
 	for i, ctor := range p.ctors {
-		info := p.infos[i]
+		infos[i] = p.providerInfo(i, ctor)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	n := &InfoNode{}
+	for _, info := range infos {
 		privateSymbol := ""
 		if !p.export {
 			privateSymbol = "🔒️"
 		}
 
-		ctorNode := NewInfoNode(fmt.Sprintf("🚧%s %s", privateSymbol, internal.FuncNameAndLocation(ctor)))
+		ctorNode := newInfoNode("🚧"+privateSymbol, info.Name)
 		ctorNode.condensed = true
+		ctorNode.provider = info
 
-		var ins, outs []string
-		for _, input := range info.Inputs {
-			ins = append(ins, input.String())
-		}
-		sort.Strings(ins)
-		for _, output := range info.Outputs {
-			outs = append(outs, output.String())
-		}
-		sort.Strings(outs)
-		if len(ins) > 0 {
-			ctorNode.AddLeaf("⇨ %s", strings.Join(ins, ", "))
+		if len(info.Inputs) > 0 {
+			addInfoValues(ctorNode, "⇨", "inputs", info.Inputs)
 		}
-		ctorNode.AddLeaf("⇦ %s", strings.Join(outs, ", "))
+		addInfoValues(ctorNode, "⇦", "outputs", info.Outputs)
 		n.Add(ctorNode)
 	}
+	if len(p.filled) == len(p.ctors) && !slices.Contains(p.filled, false) {
+		p.info = n
+	}
 	return n
 }
 
+func (p *provider) providerInfo(i int, ctor any) *ProviderInfo {
+	info := &ProviderInfo{
+		Name:     internal.FuncNameAndLocation(ctor),
+		Exported: p.export,
+		Eager:    p.eager,
+	}
+	if i >= len(p.infos) {
+		// Not applied to any hive yet.
+		return info
+	}
+	for _, input := range p.infos[i].Inputs {
+		info.inputTypes.add(&info.Inputs, internal.DigInput(input, reflect.TypeOf(ctor)))
+	}
+	for _, output := range p.infos[i].Outputs {
+		info.Outputs = append(info.Outputs, newInfoValue(internal.DigOutput(output, reflect.TypeOf(ctor))))
+	}
+	for _, iface := range p.as {
+		info.Outputs = append(info.Outputs, InfoValue{Type: iface.String()})
+	}
+	sortInfoValues(info.Inputs)
+	sortInfoValues(info.Outputs)
+	return info
+}
+
+// provideAs provides the object returned by the constructor as each of the
+// interfaces given to ProvideAs with a constructor converting it.
+func (p *provider) provideAs(c Container, ctor any) error {
+	if len(p.as) == 0 {
+		return nil
+	}
+	ctorType := reflect.TypeOf(ctor)
+	if ctorType.Kind() != reflect.Func || ctorType.NumOut() == 0 {
+		return fmt.Errorf("cannot provide %s as an interface, expected a constructor", ctorType)
+	}
+	impl := ctorType.Out(0)
+	var errs []error
+	for _, iface := range p.as {
+		if !impl.Implements(iface) {
+			errs = append(errs, fmt.Errorf("%s does not implement %s (%s)", impl, iface, missingMethod(impl, iface)))
+			continue
+		}
+		iface := iface
+		bind := reflect.MakeFunc(
+			reflect.FuncOf([]reflect.Type{impl}, []reflect.Type{iface}, false),
+			func(in []reflect.Value) []reflect.Value {
+				out := reflect.New(iface).Elem()
+				out.Set(in[0])
+				return []reflect.Value{out}
+			},
+		)
+		err := c.Provide(bind.Interface(),
+			dig.Export(p.export),
+			dig.LocationForPC(reflect.ValueOf(ctor).Pointer()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provide as %s: %w", iface, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// missingMethod describes the first method of the interface that the type
+// does not have or has with a different signature.
+func missingMethod(typ, iface reflect.Type) string {
+	for i := 0; i < iface.NumMethod(); i++ {
+		want := iface.Method(i)
+		got, ok := typ.MethodByName(want.Name)
+		switch {
+		case !ok:
+			return "missing method " + want.Name
+		case !want.IsExported() || got.Type.NumIn() == 0:
+			continue
+		}
+		// Drop the receiver for comparing with the method of the interface.
+		in := make([]reflect.Type, got.Type.NumIn()-1)
+		for j := range in {
+			in[j] = got.Type.In(j + 1)
+		}
+		out := make([]reflect.Type, got.Type.NumOut())
+		for j := range out {
+			out[j] = got.Type.Out(j)
+		}
+		if sig := reflect.FuncOf(in, out, got.Type.IsVariadic()); sig != want.Type {
+			return fmt.Sprintf("method %s has signature %s, expected %s", want.Name, sig, want.Type)
+		}
+	}
+	return "missing methods"
+}
+
+// maxCondensedValues is the number of inputs or outputs up to which they are
+// printed on a single line.
+const maxCondensedValues = 5
+
+// addInfoValues adds the inputs or outputs to the node, either as a single
+// leaf or, if there are many of them, as a sub-node with a leaf for each.
+func addInfoValues(n *InfoNode, glyph, label string, vs []InfoValue) {
+	if len(vs) <= maxCondensedValues {
+		n.AddLeaf("%s %s", glyph, joinInfoValues(vs))
+		return
+	}
+	sub := newInfoNode(glyph, label)
+	sub.condensed = true
+	for _, v := range vs {
+		sub.AddLeaf("%s", v)
+	}
+	n.Add(sub)
+}
+
+func joinInfoValues(vs []InfoValue) string {
+	strs := make([]string, len(vs))
+	for i, v := range vs {
+		strs[i] = v.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
 // Provide constructs a new cell with the given constructors.
 // Constructor is any function that takes zero or more parameters and returns
 // one or more values and optionally an error. For example, the following forms
@@ -93,6 +367,13 @@ func (p *provider) Info(container) Info {
 // If the constructor depends on a type that is not provided by any constructor
 // the hive will fail to run with an error pointing at the missing type.
 //
+// A constructor may return a Cleanup after its other results, e.g.
+// func() (*DB, cell.Cleanup, error). The cleanup is executed when the hive
+// is stopped, in reverse order of construction, and only if the constructor
+// was called and succeeded. Only the Cleanup type is recognized: a plain
+// func() or func() error result is provided as an object like any other
+// result, so convert it with e.g. cell.Cleanup(db.Close).
+//
 // A constructor can also take as parameter a structure of parameters annotated
 // with `cell.In`, or return a struct annotated with `cell.Out`:
 //
@@ -109,12 +390,127 @@ func (p *provider) Info(container) Info {
 //	}
 //
 //	func newBee(params) (out, error)
+//
+// Each constructor must be a function returning at least one value other than
+// an error. Functions returning only an error are invoke functions, see
+// Invoke. Otherwise constructing the hive fails with an error naming the index
+// of the invalid constructor and where it was given.
 func Provide(ctors ...any) Cell {
-	return &provider{ctors: ctors, export: true}
+	return &provider{ctors: ctors, export: true, location: internal.CallerLocation()}
 }
 
 // ProvidePrivate is like Provide, but the constructed objects are only
 // available within the module it is defined and nested modules.
 func ProvidePrivate(ctors ...any) Cell {
-	return &provider{ctors: ctors, export: false}
+	return &provider{ctors: ctors, export: false, location: internal.CallerLocation()}
+}
+
+// ProvidePure is like Provide, but marks the constructors as pure: their
+// results depend only on their inputs and calling them has no side effects,
+// e.g. no hooks are appended. The results of pure constructors may be reused
+// across hives with the ConstructorCache, which is useful for expensive
+// constructors in tests that build the same hive many times.
+func ProvidePure(ctors ...any) Cell {
+	return &provider{ctors: ctors, export: true, pure: true, location: internal.CallerLocation()}
+}
+
+// LazySingleton constructs a cell that provides the objects returned by the
+// constructor like Provide, and calls onFirstUse when the constructor is
+// called, that is when something in the hive depends on the objects. As the
+// constructor is called at most once in a hive, onFirstUse is called at most
+// once per hive, and only if the constructor succeeds. Useful for logging or
+// counting which optional features are activated:
+//
+//	cell.LazySingleton(newTracing, func() { featuresActivated.WithLabelValues("tracing").Inc() })
+func LazySingleton(ctor any, onFirstUse func()) Cell {
+	return &provider{ctors: []any{ctor}, export: true, onFirstUse: onFirstUse, location: internal.CallerLocation()}
+}
+
+// ProvideNamed is like Provide, but the object returned by the constructor is
+// provided with the given name. This is a shorthand for returning a struct
+// annotated with cell.Out with a `name:"..."` tagged field. The object can be
+// depended on with a field tagged with the name in a struct annotated with
+// cell.In:
+//
+//	cell.ProvideNamed("primary", newDatabase)
+//
+//	type params struct {
+//		cell.In
+//		DB *Database `name:"primary"`
+//	}
+func ProvideNamed(name string, ctor any) Cell {
+	return &provider{ctors: []any{ctor}, export: true, opts: []dig.ProvideOption{dig.Name(name)}, location: internal.CallerLocation()}
+}
+
+// ProvideAs is like Provide, but the object returned by the constructor is
+// provided both as its own type and as each of the given interfaces. The
+// interfaces are given as pointers, e.g. new(io.Writer). This is a shorthand
+// for a constructor converting the object to the interface:
+//
+//	cell.ProvideAs(newFileStore, new(Store), new(io.Closer))
+//
+// The first result of the constructor must implement the interfaces,
+// otherwise constructing the hive fails with an error naming the missing or
+// mismatching method. Unlike dig.As, the object is still provided as its own
+// type too.
+func ProvideAs(ctor any, ifaces ...any) Cell {
+	p := &provider{ctors: []any{ctor}, export: true, location: internal.CallerLocation()}
+	for _, iface := range ifaces {
+		typ := reflect.TypeOf(iface)
+		if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Interface {
+			panic(fmt.Sprintf("Invalid cell.ProvideAs interface %v, expected a pointer to an interface, e.g. new(io.Writer)", typ))
+		}
+		p.as = append(p.as, typ.Elem())
+	}
+	return p
+}
+
+// ProvideGroup is like Provide, but the object returned by the constructor is
+// provided into the given value group. This is a shorthand for returning a
+// struct annotated with cell.Out with a `group:"..."` tagged field. The members
+// of the group can be depended on with a slice field tagged with the group in
+// a struct annotated with cell.In:
+//
+//	cell.ProvideGroup("handlers", newHelloHandler)
+//
+//	type params struct {
+//		cell.In
+//		Handlers []Handler `group:"handlers"`
+//	}
+func ProvideGroup(group string, ctor any) Cell {
+	return &provider{ctors: []any{ctor}, export: true, opts: []dig.ProvideOption{dig.Group(group)}, location: internal.CallerLocation()}
+}
+
+// ProvideEager is like Provide, but the constructors are invoked when the
+// hive is populated even if no invoke function depends on them. Useful for
+// constructors with side-effects, e.g. a background poller. If a constructor
+// fails, populating the hive fails.
+//
+// If a constructor provides into a value group, then all members of the
+// group are constructed.
+func ProvideEager(ctors ...any) Cell {
+	return &provider{ctors: ctors, export: true, eager: true, location: internal.CallerLocation()}
+}
+
+// ProvideWithThreshold is like Provide, but overrides the hive's log threshold
+// (hive.Options.LogThreshold) for the given constructors. Constructors that take
+// longer than the threshold to run are logged at Info level, otherwise at Debug
+// level. Useful to silence constructors that are expected to be slow, or to tighten
+// the threshold for ones that should be near-instant.
+func ProvideWithThreshold(threshold time.Duration, ctors ...any) Cell {
+	return &provider{ctors: ctors, export: true, logThreshold: threshold, hasLogThreshold: true, location: internal.CallerLocation()}
+}
+
+// ProvideIf is like Provide, but only if the condition is true. Otherwise
+// the cell is empty. The condition is evaluated when the cell is constructed,
+// not when the hive is populated or started, so it cannot depend on objects
+// in the hive:
+//
+//	cell.ProvideIf(useFake, newFakeBackend),
+//	cell.ProvideIf(!useFake, newBackend),
+func ProvideIf(cond bool, ctors ...any) Cell {
+	if !cond {
+		return Group()
+	}
+	return &provider{ctors: ctors, export: true, location: internal.CallerLocation()}
 }
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+type groupOut struct {
+	cell.Out
+
+	Object *SomeObject `group:"objects"`
+}
+
+func newUsed() *SomeObject { return &SomeObject{} }
+
+func TestProvidePrivate(t *testing.T) {
+	invoked := false
+
+	testCell := cell.Module(
+		"test",
+		"Test Module",
+		cell.ProvidePrivate(func() *SomeObject { return &SomeObject{10} }),
+		cell.Invoke(func(*SomeObject) { invoked = true }),
+	)
+
+	// Test happy path.
+	err := hive.New(
+		testCell,
+		shutdownOnStartCell,
+	).Run()
+	assert.NoError(t, err, "expected Start to succeed")
+
+	if !invoked {
+		t.Fatal("expected invoke to be called, but it was not")
+	}
+
+	// Now test that we can't access it from root scope.
+	h := hive.New(
+		testCell,
+		cell.Invoke(func(*SomeObject) {}),
+		shutdownOnStartCell,
+	)
+	err = h.Start(context.TODO())
+	assert.ErrorContains(t, err, "missing type: *cell_test.SomeObject", "expected Start to fail to find *SomeObject")
+}
+
+func TestProvideEager(t *testing.T) {
+	var constructed []string
+	h := hive.New(
+		cell.ProvideEager(
+			func() *SomeObject {
+				constructed = append(constructed, "some")
+				return &SomeObject{}
+			},
+			func() groupOut {
+				constructed = append(constructed, "group")
+				return groupOut{}
+			},
+		),
+		cell.Provide(func() *OtherObject {
+			constructed = append(constructed, "other")
+			return &OtherObject{}
+		}),
+	)
+	require.NoError(t, h.Populate())
+	assert.ElementsMatch(t, []string{"some", "group"}, constructed)
+
+	// A failing eager constructor fails the start.
+	errEager := errors.New("eager")
+	h = hive.New(
+		cell.ProvideEager(func() (*SomeObject, error) { return nil, errEager }),
+	)
+	assert.ErrorIs(t, h.Start(context.TODO()), errEager)
+}
+
+func TestProvideNamedGroup(t *testing.T) {
+	type params struct {
+		cell.In
+
+		Named   *SomeObject   `name:"named"`
+		Objects []*SomeObject `group:"objects"`
+	}
+
+	named := cell.ProvideNamed("named", func() *SomeObject { return &SomeObject{1} })
+	group1 := cell.ProvideGroup("objects", func() *SomeObject { return &SomeObject{2} })
+	group2 := cell.ProvideGroup("objects", func() *SomeObject { return &SomeObject{3} })
+
+	var p params
+	h := hive.New(
+		named, group1, group2,
+		cell.Invoke(func(p_ params) { p = p_ }),
+	)
+	require.NoError(t, h.Populate())
+	require.NotNil(t, p.Named)
+	assert.Equal(t, 1, p.Named.X)
+	require.Len(t, p.Objects, 2)
+	assert.ElementsMatch(t, []int{2, 3}, []int{p.Objects[0].X, p.Objects[1].X})
+
+	ctorInfo := func(c cell.Cell) *cell.ProviderInfo {
+		return c.Info(nil).(*cell.InfoNode).Children()[0].(*cell.InfoNode).Provider()
+	}
+	assert.Equal(t, `*cell_test.SomeObject[name = "named"]`, ctorInfo(named).Outputs[0].String())
+	assert.Equal(t, `*cell_test.SomeObject[group = "objects"]`, ctorInfo(group1).Outputs[0].String())
+}
+
+func TestProvideIf(t *testing.T) {
+	for _, useFast := range []bool{true, false} {
+		var obj *SomeObject
+		c := cell.Group(
+			cell.ProvideIf(useFast, newFast),
+			cell.ProvideIf(!useFast, newUsed),
+		)
+		h := hive.New(
+			c,
+			cell.Invoke(func(o *SomeObject) { obj = o }),
+		)
+		require.NoError(t, h.Populate(), "Populate")
+		if useFast {
+			assert.Equal(t, 1, obj.X)
+		} else {
+			assert.Equal(t, 0, obj.X)
+		}
+
+		var buf bytes.Buffer
+		c.Info(nil).Print(0, &cell.InfoPrinter{Writer: &buf})
+		assert.Equal(t, 1, strings.Count(buf.String(), "🚧"), "expected only one constructor in Info")
+	}
+}
+
+func newDuplicate() *SomeObject { return &SomeObject{} }
+func newBadSignature()          {}
+
+func TestProvideErrorsAggregated(t *testing.T) {
+	var msg string
+	func() {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(
+			cell.Provide(newFast),
+			cell.Module("test", "Test Module",
+				cell.Provide(newDuplicate),
+			),
+			cell.Provide(newBadSignature),
+		)
+	}()
+	assert.Contains(t, msg, "Failed to apply cell")
+	assert.Contains(t, msg, "cell_test.newDuplicate")
+	assert.Contains(t, msg, "cell_test.newBadSignature")
+}
+
+func TestProvideInvalidConstructor(t *testing.T) {
+	apply := func(c cell.Cell) (msg string) {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(c)
+		return
+	}
+
+	msg := apply(cell.Provide(newSome, 42))
+	assert.Regexp(t, `invalid constructor at index 1 given at \S*provide_test\.go:\d+: int is not a function$`, msg)
+
+	msg = apply(cell.Provide(nil))
+	assert.Regexp(t, `invalid constructor at index 0 given at \S*provide_test\.go:\d+: nil is not a function$`, msg)
+
+	msg = apply(cell.ProvidePrivate(newBadSignature))
+	assert.Regexp(t, `invalid constructor at index 0 given at \S*provide_test\.go:\d+: `+
+		`cell_test\.newBadSignature \(\S*provide_test\.go:\d+\) func\(\) returns no values, a constructor must return at least one$`, msg)
+
+	msg = apply(cell.Provide(func() error { return nil }))
+	assert.Regexp(t, `invalid constructor at index 0 given at \S*provide_test\.go:\d+: `+
+		`cell_test\.TestProvideInvalidConstructor\.func\d+ \(\S*provide_test\.go:\d+\) func\(\) error returns only an error`, msg)
+	assert.Contains(t, msg, "Use cell.Invoke for functions returning only an error")
+}
+
+func TestLazySingleton(t *testing.T) {
+	var firstUses int
+	lazy := cell.LazySingleton(
+		func() *SomeObject { return &SomeObject{X: 1} },
+		func() { firstUses++ },
+	)
+
+	// Not called when nothing depends on the object.
+	h := hive.New(lazy)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Zero(t, firstUses)
+
+	// Called once on the first use even if used many times.
+	h = hive.New(
+		lazy,
+		cell.Provide(func(o *SomeObject) *OtherObject { return &OtherObject{Y: o.X} }),
+		cell.Invoke(func(*SomeObject) {}),
+		cell.Invoke(func(*SomeObject, *OtherObject) {}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, 1, firstUses)
+}
+
+type Namer interface{ Name() string }
+
+type namedGreeter struct{ name string }
+
+func (g *namedGreeter) Greet() string { return "Hello, " + g.name }
+func (g *namedGreeter) Name() string  { return g.name }
+
+// badGreeter implements Namer, but has Greet with the wrong signature.
+type badGreeter struct{}
+
+func (*badGreeter) Greet(name string) string { return "Hello, " + name }
+func (*badGreeter) Name() string             { return "bad" }
+
+func TestProvideAs(t *testing.T) {
+	var constructed int
+	bind := cell.ProvideAs(
+		func() *namedGreeter {
+			constructed++
+			return &namedGreeter{name: "hive"}
+		},
+		new(Greeter), new(Namer),
+	)
+
+	var (
+		concrete *namedGreeter
+		greeter  Greeter
+		namer    Namer
+	)
+	h := hive.New(
+		bind,
+		cell.Invoke(func(c *namedGreeter, g Greeter, n Namer) {
+			concrete, greeter, namer = c, g, n
+		}),
+	)
+	require.NoError(t, h.Populate())
+	assert.Equal(t, 1, constructed)
+	assert.Same(t, concrete, greeter)
+	assert.Same(t, concrete, namer)
+	assert.Equal(t, "Hello, hive", greeter.Greet())
+
+	var outputs []string
+	info := bind.Info(nil).(*cell.InfoNode).Children()[0].(*cell.InfoNode).Provider()
+	for _, out := range info.Outputs {
+		outputs = append(outputs, out.String())
+	}
+	assert.Equal(t, []string{"*cell_test.namedGreeter", "cell_test.Greeter", "cell_test.Namer"}, outputs)
+
+	// The constructor must return an implementation of the interfaces.
+	msg := func() (msg string) {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(cell.ProvideAs(func() *SomeObject { return &SomeObject{} }, new(Greeter)))
+		return
+	}()
+	assert.Contains(t, msg, "*cell_test.SomeObject does not implement cell_test.Greeter (missing method Greet)")
+
+	msg = func() (msg string) {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(cell.ProvideAs(func() *badGreeter { return &badGreeter{} }, new(Namer), new(Greeter)))
+		return
+	}()
+	assert.Contains(t, msg, "*cell_test.badGreeter does not implement cell_test.Greeter "+
+		"(method Greet has signature func(string) string, expected func() string)")
+	assert.NotContains(t, msg, "cell_test.Namer", "expected only the invalid binding to fail")
+
+	assert.Panics(t, func() { cell.ProvideAs(func() *SomeObject { return nil }, Greeter(nil)) })
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/dig"
+)
+
+// ReloadableConfig constructs a config cell like Config, but which is
+// re-read when the hive's configuration is reloaded with Hive.Reload(). In
+// addition to the config struct, the cell provides *Reloadable[Cfg] for
+// getting the current config and subscribing to the changes:
+//
+//	type Config struct {
+//		Address  string
+//		LogLevel string `reloadable:"true"`
+//	}
+//
+//	func newServer(cfg *cell.Reloadable[Config]) *Server {
+//		s := &Server{addr: cfg.Get().Address}
+//		cfg.Subscribe(func(cfg Config) { s.setLogLevel(cfg.LogLevel) })
+//		return s
+//	}
+//
+// Only the fields tagged with `reloadable:"true"` may change in a reload. A
+// reload that changes any other field is rejected and none of the configs
+// are changed.
+func ReloadableConfig[Cfg Flagger](def Cfg) Cell {
+	return &reloadableConfig[Cfg]{config: Config(def).(*config[Cfg])}
+}
+
+// Reloadable is the current value of the config of a ReloadableConfig cell.
+type Reloadable[Cfg Flagger] struct {
+	mu          sync.Mutex
+	cfg         Cfg
+	subscribers []func(Cfg)
+}
+
+// Get returns the current config.
+func (r *Reloadable[Cfg]) Get() Cfg {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg
+}
+
+// Subscribe registers a function to call with the new config after each
+// reload that changed the config.
+func (r *Reloadable[Cfg]) Subscribe(fn func(Cfg)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+func (r *Reloadable[Cfg]) set(cfg Cfg) {
+	r.mu.Lock()
+	if reflect.DeepEqual(r.cfg, cfg) {
+		r.mu.Unlock()
+		return
+	}
+	r.cfg = cfg
+	subscribers := r.subscribers
+	r.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(cfg)
+	}
+}
+
+// configReloader is optionally implemented by the InvokerList for reloading
+// the configs. The reload function is given the new settings and returns a
+// function for committing the reloaded config, which is only called if all
+// configs could be reloaded.
+type configReloader interface {
+	AppendConfigReload(func(AllSettings) (commit func(), err error))
+}
+
+// errConfigReloadUnsupported is appended as an invoke function in place of a
+// reload function to an InvokerList that cannot reload the configs.
+func errConfigReloadUnsupported() error {
+	return errors.New("cell.ReloadableConfig: the InvokerList does not support reloading the configs")
+}
+
+type reloadableConfig[Cfg Flagger] struct {
+	*config[Cfg]
+}
+
+func (c *reloadableConfig[Cfg]) Apply(log *slog.Logger, cont Container, logThreshold time.Duration) error {
+	if err := c.config.Apply(log, cont, logThreshold); err != nil {
+		return err
+	}
+	err := cont.Provide(
+		func(cfg Cfg) *Reloadable[Cfg] {
+			return &Reloadable[Cfg]{cfg: cfg}
+		},
+		dig.Export(true))
+	if err != nil {
+		return err
+	}
+
+	prefix := flagPrefix(cont)
+	return cont.Invoke(func(l InvokerList) {
+		reloader, ok := l.(configReloader)
+		if !ok {
+			l.AppendInvoke(errConfigReloadUnsupported)
+			return
+		}
+		reloader.AppendConfigReload(func(settings AllSettings) (commit func(), err error) {
+			invokeErr := cont.Invoke(func(p configParams[Cfg], current *Reloadable[Cfg]) {
+				p.AllSettings = settings
+				var cfg Cfg
+				cfg, err = c.provideConfig(p, prefix)
+				if err == nil {
+					err = c.checkReloadable(current.Get(), cfg, prefix)
+				}
+				commit = func() { current.set(cfg) }
+			})
+			if invokeErr != nil {
+				return nil, invokeErr
+			}
+			return commit, err
+		})
+	})
+}
+
+// checkReloadable returns an error if the fields that are not tagged
+// reloadable differ between the configs.
+func (c *reloadableConfig[Cfg]) checkReloadable(old, new Cfg, prefix string) error {
+	oldFields := configFields(reflect.ValueOf(old), "", c.flags, prefix)
+	newFields := configFields(reflect.ValueOf(new), "", c.flags, prefix)
+	var changed []string
+	for i, f := range oldFields {
+		if !f.Reloadable && !reflect.DeepEqual(f.Value, newFields[i].Value) {
+			changed = append(changed, fmt.Sprintf("%s (flag %s)", f.Name, f.Flag))
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("reload of config %T changes fields that are not reloadable: %s.\n"+
+			"Hint: tag the field with `reloadable:\"true\"` if it can be changed at runtime",
+			old, strings.Join(changed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestReloadableConfig(t *testing.T) {
+	var (
+		cfg     *cell.Reloadable[ReloadConfig]
+		updates []ReloadConfig
+	)
+	h := hive.New(
+		cell.ReloadableConfig(ReloadConfig{}),
+		cell.Invoke(func(r *cell.Reloadable[ReloadConfig]) {
+			cfg = r
+			r.Subscribe(func(c ReloadConfig) { updates = append(updates, c) })
+		}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	t.Cleanup(func() { h.Stop(context.TODO()) })
+	assert.Equal(t, ReloadConfig{"localhost", "info"}, cfg.Get())
+
+	// Reloading without changes does not notify the subscribers.
+	require.NoError(t, h.Reload())
+	assert.Empty(t, updates)
+
+	h.Viper().Set("reload-level", "debug")
+	require.NoError(t, h.Reload())
+	assert.Equal(t, ReloadConfig{"localhost", "debug"}, cfg.Get())
+	assert.Equal(t, []ReloadConfig{{"localhost", "debug"}}, updates)
+
+	// Changing a field that is not reloadable rejects the whole reload.
+	h.Viper().Set("reload-level", "warn")
+	h.Viper().Set("reload-address", "0.0.0.0")
+	err := h.Reload()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "changes fields that are not reloadable: ReloadAddress (flag reload-address)")
+	}
+	assert.Equal(t, ReloadConfig{"localhost", "debug"}, cfg.Get())
+	assert.Len(t, updates, 1)
+}
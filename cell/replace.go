@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/dig"
+
+	"github.com/cilium/hive/internal"
+)
+
+// ReplaceProvide constructs a cell that replaces the objects provided by
+// other constructors with the ones returned by the given constructors.
+// The replaced constructors will not be called.
+//
+// The objects are replaced in the scope the cell is used in and in its
+// sub-scopes, so to replace an object across the whole hive, use it at the
+// top-level:
+//
+//	hive.New(
+//		foo.Cell,
+//		cell.ReplaceProvide(func() foo.Store { return &fakeStore{} }),
+//	)
+//
+// This is meant to be used in tests for replacing a real implementation
+// with a fake without having to modify the cells under test.
+func ReplaceProvide(ctors ...any) Cell {
+	return &replacer{ctors: ctors}
+}
+
+// replacer is a set of constructors that are registered as decorators
+// in order to shadow existing constructors.
+type replacer struct {
+	ctors   []any
+	infosMu sync.Mutex
+	infos   []dig.DecorateInfo
+	filled  []bool
+}
+
+func (r *replacer) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	// The same cell may be applied to multiple hives concurrently. Fill the
+	// info of each constructor the first time it is successfully registered.
+	r.infosMu.Lock()
+	defer r.infosMu.Unlock()
+
+	if r.infos == nil {
+		r.infos = make([]dig.DecorateInfo, len(r.ctors))
+		r.filled = make([]bool, len(r.ctors))
+	}
+
+	w := ctorWrapper{log: log, logThreshold: logThreshold}
+	if err := w.fill(c, false); err != nil {
+		return err
+	}
+
+	// Register all the constructors even if some fail to report all the
+	// errors at once.
+	var errs []error
+	for i, ctor := range r.ctors {
+		// dig panics when decorating with a value that is not a function.
+		if typ := reflect.TypeOf(ctor); typ == nil || typ.Kind() != reflect.Func {
+			errs = append(errs, fmt.Errorf("invalid replacement at index %d: %s is not a function", i, internal.PrettyType(ctor)))
+			continue
+		}
+		var opts []dig.DecorateOption
+		if !r.filled[i] {
+			opts = append(opts, dig.FillDecorateInfo(&r.infos[i]))
+		}
+		wrapped, _ := w.wrap(ctor, &r.infos[i].Inputs, &r.infos[i].Outputs)
+		if err := c.Decorate(wrapped, opts...); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", internal.FuncNameAndLocation(ctor), err))
+			continue
+		}
+		r.filled[i] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (r *replacer) Info(Container) Info {
+	r.infosMu.Lock()
+	defer r.infosMu.Unlock()
+
+	n := &InfoNode{}
+	for i, ctor := range r.ctors {
+		info := &ProviderInfo{
+			Name:     internal.FuncNameAndLocation(ctor),
+			Exported: true,
+		}
+		// The infos are only filled once applied to a hive.
+		if i < len(r.infos) {
+			for _, input := range r.infos[i].Inputs {
+				info.Inputs = append(info.Inputs, newInfoValue(internal.DigInput(input, reflect.TypeOf(ctor))))
+			}
+			for _, output := range r.infos[i].Outputs {
+				info.Outputs = append(info.Outputs, newInfoValue(internal.DigOutput(output, reflect.TypeOf(ctor))))
+			}
+		}
+		sortInfoValues(info.Inputs)
+		sortInfoValues(info.Outputs)
+
+		ctorNode := newInfoNode("🔁", info.Name)
+		ctorNode.condensed = true
+		ctorNode.replacement = info
+		if len(info.Inputs) > 0 {
+			addInfoValues(ctorNode, "⇨", "inputs", info.Inputs)
+		}
+		addInfoValues(ctorNode, "⇦", "outputs", info.Outputs)
+		n.Add(ctorNode)
+	}
+	return n
+}
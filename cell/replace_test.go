@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+// buildErr returns the error hive.New panics with for the cells.
+func buildErr(cells ...cell.Cell) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	hive.New(cells...)
+	return nil
+}
+
+func TestReplaceProvide(t *testing.T) {
+	realCalled := false
+	var got *SomeObject
+	h := hive.New(
+		cell.Module(
+			"test",
+			"Test Module",
+			cell.ProvidePrivate(func() *SomeObject {
+				realCalled = true
+				return &SomeObject{1}
+			}),
+			cell.Invoke(func(o *SomeObject) { got = o }),
+		),
+		cell.ReplaceProvide(func(o *OtherObject) *SomeObject { return &SomeObject{o.Y} }),
+		cell.Provide(func() *OtherObject { return &OtherObject{2} }),
+	)
+	require.NoError(t, h.Populate())
+	assert.False(t, realCalled, "expected replaced constructor to not be called")
+	require.NotNil(t, got)
+	assert.Equal(t, 2, got.X)
+
+	// The replacements are wrapped like the constructors: a panic is
+	// turned into an error.
+	h = hive.New(
+		cell.Provide(newSome),
+		cell.ReplaceProvide(func() *SomeObject { panic("replacement failed") }),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	assert.ErrorContains(t, h.Populate(), "panicked: replacement failed")
+
+	// The errors of all the replacements are reported.
+	err := buildErr(cell.ReplaceProvide(42, "foo"))
+	assert.ErrorContains(t, err, "invalid replacement at index 0: int is not a function")
+	assert.ErrorContains(t, err, "invalid replacement at index 1: string is not a function")
+
+	// The info is available before the cell is applied.
+	assert.NotPanics(t, func() { cell.ReplaceProvide(func() int { return 1 }).Info(nil) })
+}
@@ -1,17 +1,24 @@
 package cell
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"sync"
 )
 
 type simpleHealthRoot struct {
 	sync.Mutex
-	all map[string]*SimpleHealth
+	all  map[string]*SimpleHealth
+	subs []*healthSubscriber
 }
 
 type SimpleHealth struct {
 	*simpleHealthRoot
 
+	parent   *SimpleHealth
+	children []*SimpleHealth
+
 	Scope  string
 	Level  Level
 	Status string
@@ -23,11 +30,18 @@ func (h *SimpleHealth) NewScope(name string) Health {
 	h.Lock()
 	defer h.Unlock()
 
+	scope := name
+	if h.Scope != "" {
+		scope = h.Scope + "." + name
+	}
 	h2 := &SimpleHealth{
 		simpleHealthRoot: h.simpleHealthRoot,
-		Scope:            h.Scope + "." + name,
+		parent:           h,
+		Scope:            scope,
+		Level:            StatusUnknown,
 	}
-	h.all[name] = h2
+	h.children = append(h.children, h2)
+	h.all[scope] = h2
 	return h2
 }
 
@@ -49,6 +63,7 @@ func (h *SimpleHealth) Degraded(reason string, err error) {
 	h.Level = StatusDegraded
 	h.Status = reason
 	h.Error = err
+	h.notify()
 }
 
 // OK implements cell.Health.
@@ -59,6 +74,7 @@ func (h *SimpleHealth) OK(status string) {
 	h.Level = StatusOK
 	h.Status = status
 	h.Error = nil
+	h.notify()
 }
 
 // Stopped implements cell.Health.
@@ -69,13 +85,149 @@ func (h *SimpleHealth) Stopped(reason string) {
 	h.Level = StatusStopped
 	h.Status = reason
 	h.Error = nil
+	h.notify()
 }
 
 func (h *SimpleHealth) Close() {
 	h.Lock()
 	defer h.Unlock()
 
-	delete(h.all, h.Scope)
+	if h.all[h.Scope] == h {
+		delete(h.all, h.Scope)
+	}
+	if h.parent != nil {
+		for i, child := range h.parent.children {
+			if child == h {
+				h.parent.children = append(h.parent.children[:i:i], h.parent.children[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Statuses implements cell.HealthStatuser. Returns the statuses of the
+// scopes created from this one.
+func (h *SimpleHealth) Statuses() []HealthStatus {
+	h.Lock()
+	defer h.Unlock()
+	return h.statuses()
+}
+
+func (h *SimpleHealth) statuses() []HealthStatus {
+	if len(h.children) == 0 {
+		return nil
+	}
+	statuses := make([]HealthStatus, len(h.children))
+	for i, child := range h.children {
+		statuses[i] = HealthStatus{
+			Scope:    child.Scope,
+			Level:    child.Level,
+			Message:  child.Status,
+			Error:    child.Error,
+			Children: child.statuses(),
+		}
+		statuses[i].rollUp()
+	}
+	return statuses
+}
+
+// Subscribe returns a channel for the status changes of this scope and of
+// the scopes created from it. The channel is closed when the context is
+// cancelled. The changes are coalesced for a slow subscriber rather than
+// blocking the reporters: it receives only the latest status of each scope
+// that changed since it last received. The statuses do not include the
+// children.
+func (h *SimpleHealth) Subscribe(ctx context.Context) <-chan HealthStatus {
+	sub := &healthSubscriber{
+		scope:   h.Scope,
+		indices: map[string]int{},
+		wake:    make(chan struct{}, 1),
+	}
+	h.Lock()
+	h.subs = append(h.subs, sub)
+	h.Unlock()
+
+	out := make(chan HealthStatus)
+	go func() {
+		defer close(out)
+		defer h.unsubscribe(sub)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sub.wake:
+			}
+			for _, s := range sub.take() {
+				select {
+				case out <- s:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func (h *SimpleHealth) unsubscribe(sub *healthSubscriber) {
+	h.Lock()
+	defer h.Unlock()
+	h.subs = slices.DeleteFunc(h.subs, func(s *healthSubscriber) bool { return s == sub })
+}
+
+// notify queues the status of the scope to the subscribers. Must be called
+// with the lock held.
+func (h *SimpleHealth) notify() {
+	s := HealthStatus{
+		Scope:   h.Scope,
+		Level:   h.Level,
+		Message: h.Status,
+		Error:   h.Error,
+	}
+	for _, sub := range h.subs {
+		if sub.matches(h.Scope) {
+			sub.push(s)
+		}
+	}
+}
+
+// healthSubscriber queues the status changes for a subscriber. The queue
+// holds at most one status per scope.
+type healthSubscriber struct {
+	scope string
+
+	mu      sync.Mutex
+	pending []HealthStatus
+	indices map[string]int // scope => index in pending
+	wake    chan struct{}
+}
+
+func (sub *healthSubscriber) matches(scope string) bool {
+	return sub.scope == "" || scope == sub.scope || strings.HasPrefix(scope, sub.scope+".")
+}
+
+func (sub *healthSubscriber) push(s HealthStatus) {
+	sub.mu.Lock()
+	if i, ok := sub.indices[s.Scope]; ok {
+		sub.pending[i] = s
+	} else {
+		sub.indices[s.Scope] = len(sub.pending)
+		sub.pending = append(sub.pending, s)
+	}
+	sub.mu.Unlock()
+	select {
+	case sub.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (sub *healthSubscriber) take() []HealthStatus {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	pending := sub.pending
+	sub.pending = nil
+	clear(sub.indices)
+	return pending
 }
 
 func NewSimpleHealth() (Health, *SimpleHealth) {
@@ -83,10 +235,14 @@ func NewSimpleHealth() (Health, *SimpleHealth) {
 		simpleHealthRoot: &simpleHealthRoot{
 			all: make(map[string]*SimpleHealth),
 		},
+		Level: StatusUnknown,
 	}
 	return h, h
 }
 
-var _ Health = &SimpleHealth{}
+var (
+	_ Health         = &SimpleHealth{}
+	_ HealthStatuser = &SimpleHealth{}
+)
 
 var SimpleHealthCell = Provide(NewSimpleHealth)
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleHealthSubscribe(t *testing.T) {
+	_, root := NewSimpleHealth()
+	module := root.NewScope("module")
+	component := module.NewScope("component")
+	other := root.NewScope("other")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	all := root.Subscribe(ctx)
+	moduleOnly := module.(*SimpleHealth).Subscribe(ctx)
+
+	receive := func(ch <-chan HealthStatus) HealthStatus {
+		select {
+		case s := <-ch:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for status")
+		}
+		return HealthStatus{}
+	}
+
+	component.OK("Ready")
+	assert.Equal(t, HealthStatus{Scope: "module.component", Level: StatusOK, Message: "Ready"}, receive(all))
+	assert.Equal(t, StatusOK, receive(moduleOnly).Level)
+
+	other.OK("Ready")
+	assert.Equal(t, HealthStatus{Scope: "other", Level: StatusOK, Message: "Ready"}, receive(all))
+
+	component.Degraded("Connection lost", errors.New("timeout"))
+	s := receive(all)
+	assert.Equal(t, "module.component", s.Scope)
+	assert.Equal(t, StatusDegraded, s.Level)
+	assert.EqualError(t, s.Error, "timeout")
+	assert.Equal(t, StatusDegraded, receive(moduleOnly).Level, "expected other scope to be filtered out")
+}
+
+func TestSimpleHealthSubscriberCoalesce(t *testing.T) {
+	sub := &healthSubscriber{indices: map[string]int{}, wake: make(chan struct{}, 1)}
+	sub.push(HealthStatus{Scope: "a", Level: StatusOK, Message: "Ready"})
+	sub.push(HealthStatus{Scope: "b", Level: StatusOK, Message: "Ready"})
+	sub.push(HealthStatus{Scope: "a", Level: StatusDegraded, Message: "Connection lost"})
+
+	// The changes of a scope are coalesced into its latest status, in the
+	// order the scopes first changed.
+	assert.Equal(t, []HealthStatus{
+		{Scope: "a", Level: StatusDegraded, Message: "Connection lost"},
+		{Scope: "b", Level: StatusOK, Message: "Ready"},
+	}, sub.take())
+
+	sub.push(HealthStatus{Scope: "a", Level: StatusOK, Message: "Recovered"})
+	assert.Equal(t, []HealthStatus{{Scope: "a", Level: StatusOK, Message: "Recovered"}}, sub.take())
+}
+
+func TestSimpleHealthSubscribeBounded(t *testing.T) {
+	_, root := NewSimpleHealth()
+	scopes := []Health{root.NewScope("a"), root.NewScope("b")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	root.Subscribe(ctx)
+
+	// Reporting does not block on a subscriber that never receives, which
+	// holds at most one status per scope.
+	for i := 0; i < 1000; i++ {
+		scopes[i%2].OK("Ready")
+	}
+	root.Lock()
+	sub := root.subs[0]
+	root.Unlock()
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	assert.LessOrEqual(t, len(sub.pending), 2)
+}
+
+func TestSimpleHealthSubscribeCancel(t *testing.T) {
+	_, root := NewSimpleHealth()
+	scope := root.NewScope("scope")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := root.Subscribe(ctx)
+	require.Equal(t, 1, root.subscriberCount())
+
+	scope.OK("Ready")
+	cancel()
+
+	// The channel is closed after cancellation, possibly after delivering
+	// the pending status.
+	for range ch {
+	}
+	assert.Eventually(t,
+		func() bool { return root.subscriberCount() == 0 },
+		5*time.Second, 10*time.Millisecond,
+		"expected subscriber to be removed")
+
+	// Reporting after the subscriber is gone does not block.
+	scope.Degraded("Failed", nil)
+}
+
+func TestSimpleHealthRollUp(t *testing.T) {
+	_, root := NewSimpleHealth()
+
+	a := root.NewScope("a")
+	a.NewScope("x").OK("Ready")
+	y := a.NewScope("y")
+	y.NewScope("p").Degraded("Connection lost", errors.New("timeout"))
+	y.NewScope("q").OK("Ready")
+	z := a.NewScope("z")
+	z.Stopped("Done")
+	z.NewScope("w").Degraded("Failed", nil)
+
+	b := root.NewScope("b")
+	b.NewScope("u").OK("Ready")
+	b.NewScope("v")
+
+	c := root.NewScope("c")
+	c.OK("Ready")
+	c.NewScope("s").Stopped("Done")
+
+	root.NewScope("d").NewScope("t").Stopped("Done")
+
+	type rolledUp struct {
+		Level Level
+		Cause string
+	}
+	got := map[string]rolledUp{}
+	var walk func([]HealthStatus)
+	walk = func(statuses []HealthStatus) {
+		for _, s := range statuses {
+			got[s.Scope] = rolledUp{s.Level, s.Cause}
+			walk(s.Children)
+		}
+	}
+	walk(root.Statuses())
+
+	assert.Equal(t, map[string]rolledUp{
+		// The worst child rolls up through the unreported scopes, but not
+		// through a stopped one.
+		"a":     {StatusDegraded, "a.y.p"},
+		"a.x":   {StatusOK, ""},
+		"a.y":   {StatusDegraded, "a.y.p"},
+		"a.y.p": {StatusDegraded, ""},
+		"a.y.q": {StatusOK, ""},
+		"a.z":   {StatusStopped, ""},
+		"a.z.w": {StatusDegraded, ""},
+
+		// A child that has not reported is worse than an OK one.
+		"b":   {StatusUnknown, "b.v"},
+		"b.u": {StatusOK, ""},
+		"b.v": {StatusUnknown, ""},
+
+		// A stopped child does not override the scope's own level.
+		"c":   {StatusOK, ""},
+		"c.s": {StatusStopped, ""},
+
+		"d":   {StatusStopped, "d.t"},
+		"d.t": {StatusStopped, ""},
+	}, got)
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/dig"
+)
+
+// SubHive constructs a cell that runs the given cells in an isolated
+// container, e.g. for a plugin with its own set of objects. The cells can
+// only depend on the objects provided by each other and on the imported
+// objects from the parent, and only the exported objects are provided to
+// the parent. The imports and exports are given as pointers to the types:
+//
+//	cell.SubHive(
+//		[]any{new(*Database), new(hive.Shutdowner)},
+//		[]any{new(Plugin)},
+//		pluginCells...,
+//	)
+//
+// The infrastructure of the hive is imported implicitly, e.g. the logger,
+// InvokerList and the flags. The lifecycle of the sub-hive is nested in the
+// lifecycle of the parent: its start hooks are run together when the first
+// constructor appending a hook to the sub-hive lifecycle would have had its
+// hooks run, and its stop hooks are run together in reverse. The sub-hive
+// lifecycle has the same options as the parent lifecycle, e.g. the hook
+// timeout and the tracer.
+func SubHive(imports, exports []any, cells ...Cell) Cell {
+	return &subHive{
+		imports: subHiveTypes("import", imports),
+		exports: subHiveTypes("export", exports),
+		cells:   cells,
+	}
+}
+
+// subHiveImports are the types implicitly imported to a sub-hive.
+var subHiveImports = []reflect.Type{
+	reflect.TypeOf((*slog.Logger)(nil)),
+	reflect.TypeOf((*RootLogger)(nil)).Elem(),
+	reflect.TypeOf((*RootContext)(nil)).Elem(),
+	reflect.TypeOf((*InvokerList)(nil)).Elem(),
+	reflect.TypeOf((*pflag.FlagSet)(nil)),
+	reflect.TypeOf((*AllSettings)(nil)).Elem(),
+	reflect.TypeOf((*DecodeHooks)(nil)).Elem(),
+	reflect.TypeOf((*FullModuleID)(nil)).Elem(),
+	reflect.TypeOf((*ModuleDecorators)(nil)).Elem(),
+	reflect.TypeOf((*ModulePrivateProviders)(nil)).Elem(),
+	reflect.TypeOf((*ConstructorMetrics)(nil)).Elem(),
+	reflect.TypeOf((*Clock)(nil)).Elem(),
+	reflect.TypeOf((*StrictProvideThreshold)(nil)).Elem(),
+	reflect.TypeOf((*ContainerOptions)(nil)).Elem(),
+}
+
+// ContainerOptions are the options the hive's container was created with,
+// e.g. dig.DeferAcyclicVerification. The sub-hives create their containers
+// with them as well. Provided by the hive.
+type ContainerOptions []dig.Option
+
+func subHiveTypes(what string, ptrs []any) []reflect.Type {
+	types := make([]reflect.Type, len(ptrs))
+	for i, ptr := range ptrs {
+		typ := reflect.TypeOf(ptr)
+		if typ == nil || typ.Kind() != reflect.Pointer {
+			panic(fmt.Sprintf("Invalid cell.SubHive %s %T, expected a pointer to the type, e.g. new(*Foo)", what, ptr))
+		}
+		types[i] = typ.Elem()
+	}
+	return types
+}
+
+type subHive struct {
+	imports []reflect.Type
+	exports []reflect.Type
+	cells   []Cell
+}
+
+type subHiveParams struct {
+	In
+	Lifecycle        Lifecycle
+	ContainerOptions ContainerOptions `optional:"true"`
+}
+
+// appliedSubHive is the container a sub-hive has been applied to. Provided
+// to the parent for showing the objects of the sub-hive in Info.
+type appliedSubHive struct {
+	hive      *subHive
+	container Container
+}
+
+type appliedSubHives struct {
+	In
+	SubHives []appliedSubHive `group:"applied-sub-hives"`
+}
+
+func (s *subHive) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	// Nest the lifecycle of the sub-hive in the parent lifecycle.
+	lc := &DefaultLifecycle{}
+	var opts ContainerOptions
+	err := c.Invoke(func(p subHiveParams) {
+		opts = p.ContainerOptions
+		parent := p.Lifecycle
+		if dlc := levelTracker(parent); dlc != nil {
+			lc.LogThreshold = dlc.LogThreshold
+			lc.HookTimeout = dlc.HookTimeout
+			lc.ParallelStop = dlc.ParallelStop
+			lc.ParallelStart = dlc.ParallelStart
+			lc.StartWatchdog = dlc.StartWatchdog
+			lc.WatchdogStacks = dlc.WatchdogStacks
+			if dlc.StartProgress != nil {
+				lc.StartProgress = func(done, total int, name string) {
+					dlc.progressMu.Lock()
+					defer dlc.progressMu.Unlock()
+					dlc.StartProgress(done, total, name)
+				}
+			}
+			lc.Clock = dlc.Clock
+			lc.Tracer = dlc.Tracer
+			lc.Metrics = dlc.Metrics
+		}
+		lc.onFirstAppend = func() {
+			parent.Append(HookWithName(s.name(), Hook{
+				OnStart: func(ctx HookContext) error {
+					if err := lc.Start(log, ctx); err != nil {
+						// Stop the hooks that did start as the parent does
+						// not stop a hook that failed to start.
+						lc.Stop(log, ctx)
+						return err
+					}
+					return nil
+				},
+				OnStop: func(ctx HookContext) error {
+					return lc.Stop(log, ctx)
+				},
+			}))
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	sub := newContainer(c, opts...)
+	imported := map[reflect.Type]bool{}
+	for _, typ := range append(append([]reflect.Type{}, subHiveImports...), s.imports...) {
+		if imported[typ] {
+			continue
+		}
+		imported[typ] = true
+		if err := sub.Provide(objectFrom(c, typ)); err != nil {
+			return fmt.Errorf("sub-hive import %s: %w", typ, err)
+		}
+	}
+
+	if err := sub.Provide(func() Lifecycle { return lc }); err != nil {
+		return err
+	}
+
+	cont := withFlagPrefix(sub, flagPrefix(c))
+	var errs []error
+	for _, cell := range s.cells {
+		if err := cell.Apply(log, cont, logThreshold); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, typ := range s.exports {
+		if err := c.Provide(objectFrom(sub, typ), dig.Export(true)); err != nil {
+			return fmt.Errorf("sub-hive export %s: %w", typ, err)
+		}
+	}
+	return c.Provide(
+		func() appliedSubHive { return appliedSubHive{s, cont} },
+		dig.Group("applied-sub-hives"), dig.Export(true))
+}
+
+func (s *subHive) name() string {
+	if len(s.exports) == 0 {
+		return "sub-hive"
+	}
+	return "sub-hive exporting " + typeNames(s.exports)
+}
+
+func (s *subHive) Info(c Container) Info {
+	n := NewInfoNode("🛸 Sub-hive")
+	if len(s.imports) > 0 {
+		n.AddLeaf("⇨ imports: %s", typeNames(s.imports))
+	}
+	if len(s.exports) > 0 {
+		n.AddLeaf("⇦ exports: %s", typeNames(s.exports))
+	}
+	// Show the objects in the container the sub-hive was applied to, e.g.
+	// for showing the populated configs.
+	var cont Container = withFlagPrefix(dig.New(), flagPrefix(c))
+	if c != nil {
+		c.Invoke(func(p appliedSubHives) {
+			for _, applied := range p.SubHives {
+				if applied.hive == s {
+					cont = applied.container
+					break
+				}
+			}
+		})
+	}
+	for _, cell := range s.cells {
+		n.Add(cell.Info(cont))
+	}
+	return n
+}
+
+func typeNames(types []reflect.Type) string {
+	names := make([]string, len(types))
+	for i, typ := range types {
+		names[i] = typ.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// objectFrom returns a constructor for the object of the given type that
+// gets the object from the container.
+func objectFrom(c Container, typ reflect.Type) any {
+	ctorType := reflect.FuncOf(nil, []reflect.Type{typ, errorType}, false)
+	setType := reflect.FuncOf([]reflect.Type{typ}, nil, false)
+	return reflect.MakeFunc(ctorType, func([]reflect.Value) []reflect.Value {
+		obj := reflect.New(typ).Elem()
+		set := reflect.MakeFunc(setType, func(args []reflect.Value) []reflect.Value {
+			obj.Set(args[0])
+			return nil
+		})
+		errV := reflect.Zero(errorType)
+		if err := c.Invoke(set.Interface()); err != nil {
+			errV = reflect.ValueOf(&err).Elem()
+		}
+		return []reflect.Value{obj, errV}
+	}).Interface()
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+type fakeSpanKey struct{}
+
+// fakeTracer records the finished spans as "parent/child" paths with their
+// errors.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type fakeSpan struct {
+	t    *fakeTracer
+	path string
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, cell.Span) {
+	path := name
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		path = parent.path + "/" + name
+	}
+	span := &fakeSpan{t, path}
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (s *fakeSpan) End(err error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	entry := s.path
+	if err != nil {
+		entry += ": " + err.Error()
+	}
+	s.t.spans = append(s.t.spans, entry)
+}
+
+func TestSubHive(t *testing.T) {
+	var events []string
+	hook := func(lc cell.Lifecycle, name string) {
+		lc.Append(cell.HookWithName(name, cell.Hook{
+			OnStart: func(cell.HookContext) error { events = append(events, "start "+name); return nil },
+			OnStop:  func(cell.HookContext) error { events = append(events, "stop "+name); return nil },
+		}))
+	}
+
+	plugin := cell.SubHive(
+		[]any{new(*SomeObject)},
+		[]any{new(*OtherObject)},
+		cell.Provide(
+			func(lc cell.Lifecycle, s *SomeObject, _ *ThirdObject) *OtherObject {
+				hook(lc, "plugin")
+				return &OtherObject{Y: s.X + 1}
+			},
+			func() *ThirdObject { return &ThirdObject{} },
+		),
+	)
+
+	var other *OtherObject
+	h := hive.New(
+		cell.Provide(func(lc cell.Lifecycle) *SomeObject {
+			hook(lc, "some")
+			return &SomeObject{X: 1}
+		}),
+		plugin,
+		cell.Invoke(func(lc cell.Lifecycle, o *OtherObject) {
+			hook(lc, "consumer")
+			other = o
+		}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, 2, other.Y)
+	assert.Equal(t, []string{
+		"start some", "start plugin", "start consumer",
+		"stop consumer", "stop plugin", "stop some",
+	}, events)
+
+	// The objects provided within the sub-hive are not visible to the parent.
+	h = hive.New(plugin, cell.Invoke(func(*ThirdObject) {}))
+	assert.ErrorContains(t, h.Populate(), "missing type: *cell_test.ThirdObject")
+
+	// The objects of the parent are only visible in the sub-hive if imported.
+	h = hive.New(
+		cell.Provide(func() *SomeObject { return &SomeObject{} }),
+		cell.SubHive(nil, nil, cell.Invoke(func(*SomeObject) {})),
+	)
+	assert.ErrorContains(t, h.Populate(), "missing type: *cell_test.SomeObject")
+
+	// The sub-hive lifecycle has the options of the parent lifecycle.
+	var progress []string
+	tracer := &fakeTracer{}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.Tracer = tracer
+	opts.StartProgress = func(done, total int, name string) { progress = append(progress, name) }
+	h = hive.NewWithOptions(opts,
+		cell.SubHive(nil, nil, cell.Invoke(func(lc cell.Lifecycle) { hook(lc, "plugin") })),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Contains(t, progress, "plugin")
+	assert.Condition(t, func() bool {
+		for _, span := range tracer.spans {
+			if strings.HasPrefix(span, "hive start/") && strings.HasSuffix(span, "/plugin") {
+				return true
+			}
+		}
+		return false
+	}, "expected a span for the sub-hive hook, got %v", tracer.spans)
+}
+
+func TestSubHiveContainer(t *testing.T) {
+	var opts cell.ContainerOptions
+	h := hive.NewWithOptions(
+		hive.Options{DeferCycleCheck: true},
+		cell.SubHive(nil, nil,
+			cell.Config(Config{}),
+			cell.Invoke(func(o cell.ContainerOptions) { opts = o }),
+		),
+	)
+
+	// The container of the sub-hive is created with the options of the hive.
+	require.NoError(t, h.Populate())
+	assert.Len(t, opts, 1)
+
+	// The info of the sub-hive shows the objects of its container, e.g.
+	// the populated config and not the default one.
+	h = hive.New(cell.SubHive(nil, nil, cell.Config(Config{})))
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--foo=sub"}))
+	var buf bytes.Buffer
+	h.PrintConfig(&buf)
+	assert.Regexp(t, `(?m)^\s+Foo\s+sub\s+flag\s+--foo$`, buf.String())
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"go.uber.org/dig"
+)
+
+// Supply constructs a cell that provides the given values as if they were
+// returned by trivial constructors. Useful for constants, pre-built objects
+// and test doubles:
+//
+//	cell.Supply(&Options{Retries: 3}, defaultRegistry)
+//
+// The values are provided under their dynamic type, e.g. supplying an
+// *os.File that was stored in an io.Writer variable provides *os.File. Use
+// As to provide a value under an interface type:
+//
+//	cell.Supply(cell.As[io.Writer](os.Stdout))
+func Supply(values ...any) Cell {
+	s := &supplier{}
+	for _, v := range values {
+		if as, ok := v.(asValue); ok {
+			s.values = append(s.values, as.value)
+		} else {
+			s.values = append(s.values, reflect.ValueOf(v))
+		}
+	}
+	return s
+}
+
+// As wraps the value given to Supply in order to provide it as type T
+// instead of its dynamic type.
+func As[T any](value T) any {
+	return asValue{reflect.ValueOf(&value).Elem()}
+}
+
+type asValue struct {
+	value reflect.Value
+}
+
+// supplier is a set of values provided to the hive.
+type supplier struct {
+	values []reflect.Value
+}
+
+func (s *supplier) Apply(log *slog.Logger, c Container, logThreshold time.Duration) error {
+	var errs []error
+	for _, v := range s.values {
+		if !v.IsValid() {
+			errs = append(errs, errors.New("cannot supply untyped nil, use As to supply a nil interface"))
+			continue
+		}
+		v := v
+		ctor := reflect.MakeFunc(
+			reflect.FuncOf(nil, []reflect.Type{v.Type()}, false),
+			func([]reflect.Value) []reflect.Value { return []reflect.Value{v} },
+		)
+		if err := c.Provide(ctor.Interface(), dig.Export(true)); err != nil {
+			errs = append(errs, fmt.Errorf("supply %s: %w", v.Type(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *supplier) Info(Container) Info {
+	n := &InfoNode{}
+	for _, v := range s.values {
+		if !v.IsValid() {
+			continue
+		}
+		typ := v.Type().String()
+		valNode := newInfoNode("📦", typ)
+		valNode.provider = &ProviderInfo{
+			Name:     "supplied " + typ,
+			Exported: true,
+			Outputs:  []InfoValue{{Type: typ}},
+		}
+		n.Add(valNode)
+	}
+	return n
+}
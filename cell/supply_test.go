@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestSupply(t *testing.T) {
+	var (
+		some    *SomeObject
+		greeter Greeter
+		english englishGreeter
+	)
+	supply := cell.Supply(&SomeObject{X: 1}, cell.As[Greeter](englishGreeter{}), englishGreeter{})
+	h := hive.New(
+		supply,
+		cell.Invoke(func(s *SomeObject, g Greeter, e englishGreeter) {
+			some, greeter, english = s, g, e
+		}),
+	)
+	require.NoError(t, h.Populate())
+	assert.Equal(t, 1, some.X)
+	assert.Equal(t, "hello", greeter.Greet())
+	assert.Equal(t, "hello", english.Greet())
+
+	// Without As an interface value is supplied under its dynamic type.
+	h = hive.New(
+		cell.Supply(Greeter(englishGreeter{})),
+		cell.Invoke(func(Greeter) {}),
+	)
+	assert.ErrorContains(t, h.Populate(), "missing type: cell_test.Greeter")
+
+	var outputs []string
+	for _, child := range supply.Info(nil).(*cell.InfoNode).Children() {
+		for _, out := range child.(*cell.InfoNode).Provider().Outputs {
+			outputs = append(outputs, out.String())
+		}
+	}
+	assert.Equal(t, []string{"*cell_test.SomeObject", "cell_test.Greeter", "cell_test.englishGreeter"}, outputs)
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/dig"
+
+	"github.com/cilium/hive/internal"
+)
+
+// WithEnableFlag when given to Module registers the flag "enable-<id>" for
+// enabling or disabling the module at startup, e.g. "--enable-foo=false" for
+// module "foo". The flag defaults to the given value. When the module is
+// disabled nothing in it or in its sub-modules is invoked, constructed or
+// started and it is not shown in the object dump: its constructors and
+// decorators are only added to the hive when it is populated, once the flags
+// have been parsed, and only if the module is enabled. Depending on an object
+// provided by a disabled module fails with an error naming the module.
+func WithEnableFlag(enabledByDefault bool) Cell {
+	return enableFlagOption{enabledByDefault}
+}
+
+type enableFlagOption struct {
+	enabledByDefault bool
+}
+
+func (enableFlagOption) Apply(*slog.Logger, Container, time.Duration) error { return nil }
+func (enableFlagOption) Info(Container) Info                                { return NewInfoNode("") }
+
+// enableFlag is the enable flag of a module.
+type enableFlag struct {
+	moduleID         string
+	enabledByDefault bool
+}
+
+// name returns the name of the flag for a module with the given flag prefix.
+func (f *enableFlag) name(prefix string) string {
+	return prefix + "enable-" + f.moduleID
+}
+
+// register registers the flag to the flags of the hive.
+func (f *enableFlag) register(scope Container, prefix string) error {
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	flags.Bool(f.name(prefix), f.enabledByDefault, fmt.Sprintf("Enable the %s module", f.moduleID))
+	return registerFlags(scope, "cell.WithEnableFlag", flags)
+}
+
+// enabled returns whether the module is enabled and whether the flags have
+// been parsed. The default is returned if the flags have not been parsed yet.
+func (f *enableFlag) enabled(c Container, prefix string) (enabled, parsed bool) {
+	enabled = f.enabledByDefault
+	name := f.name(prefix)
+	err := c.Invoke(func(settings AllSettings) {
+		if v, ok := settings[name]; ok {
+			if b, err := strconv.ParseBool(fmt.Sprint(v)); err == nil {
+				enabled = b
+			}
+		}
+	})
+	return enabled, err == nil
+}
+
+// disabled returns a function that returns an error if the module has been
+// disabled, or if the module it is nested in has been disabled. Whether the
+// module is enabled is only looked up until the flags have been parsed.
+func (f *enableFlag) disabled(c Container, prefix string, parent func() error) func() error {
+	var (
+		mu      sync.Mutex
+		enabled bool
+		parsed  bool
+	)
+	return func() error {
+		if parent != nil {
+			if err := parent(); err != nil {
+				return err
+			}
+		}
+		mu.Lock()
+		if !parsed {
+			enabled, parsed = f.enabled(c, prefix)
+		}
+		mu.Unlock()
+		if !enabled {
+			return fmt.Errorf("module %s is disabled with flag --%s=false", f.moduleID, f.name(prefix))
+		}
+		return nil
+	}
+}
+
+// deferredProvider is optionally implemented by the InvokerList for adding
+// the constructors and decorators of the modules with an enable flag and of
+// the optional cells to the hive when populating it, before the config
+// checks and the invoke functions.
+type deferredProvider interface {
+	AppendDeferredProvide(func() error)
+}
+
+// appendDeferredProvide appends the function adding the deferred constructor
+// or decorator to the InvokerList, or appends it as an invoke function if the
+// InvokerList cannot defer it.
+func appendDeferredProvide(l InvokerList, provide func() error) {
+	if p, ok := l.(deferredProvider); ok {
+		p.AppendDeferredProvide(provide)
+		return
+	}
+	l.AppendInvoke(func() error {
+		var disabledErr *DisabledProviderError
+		if err := provide(); err != nil && !errors.As(err, &disabledErr) {
+			return err
+		}
+		return nil
+	})
+}
+
+// DisabledProviderError is the error for a constructor that is not added to
+// the hive as its module has been disabled with its enable flag or as the
+// optional cell it is in has been skipped. The hive names the module or the
+// cell when an object of the constructor is missing. See WithEnableFlag and
+// OptionalCell.
+type DisabledProviderError struct {
+	// Outputs are the objects the constructor would have provided.
+	Outputs []InfoValue
+
+	Err error
+}
+
+func (e *DisabledProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DisabledProviderError) Unwrap() error {
+	return e.Err
+}
+
+// outputsOf returns the objects the constructor provides with the options.
+func outputsOf(ctor any, opts []dig.ProvideOption) []InfoValue {
+	var info dig.ProvideInfo
+	if err := dig.New().Provide(ctor, append(slices.Clone(opts), dig.FillProvideInfo(&info))...); err != nil {
+		return nil
+	}
+	outputs := make([]InfoValue, len(info.Outputs))
+	for i, output := range info.Outputs {
+		outputs[i] = newInfoValue(internal.DigOutput(output, reflect.TypeOf(ctor)))
+	}
+	return outputs
+}
+
+// gatedInvokerList is the InvokerList within a module with an enable flag.
+// The invoke functions and the config checks and reloads are skipped if the
+// module has been disabled.
+type gatedInvokerList struct {
+	InvokerList
+	disabled func() error
+}
+
+func (l gatedInvokerList) gate(invoke func() error) func() error {
+	return func() error {
+		if l.disabled() != nil {
+			return nil
+		}
+		return invoke()
+	}
+}
+
+func (l gatedInvokerList) AppendInvoke(invoke func() error) {
+	l.InvokerList.AppendInvoke(l.gate(invoke))
+}
+
+func (l gatedInvokerList) AppendInvokeAfter(invoke func() error, handle InvokeHandle, after []InvokeHandle) {
+	if ol, ok := l.InvokerList.(OrderedInvokerList); ok {
+		ol.AppendInvokeAfter(l.gate(invoke), handle, after)
+	} else {
+		l.InvokerList.AppendInvoke(l.gate(invoke))
+	}
+}
+
+func (l gatedInvokerList) AppendConfigCheck(check func() error) {
+	if checker, ok := l.InvokerList.(configChecker); ok {
+		checker.AppendConfigCheck(l.gate(check))
+	} else {
+		l.InvokerList.AppendInvoke(l.gate(check))
+	}
+}
+
+func (l gatedInvokerList) AppendConfigReload(reload func(AllSettings) (func(), error)) {
+	reloader, ok := l.InvokerList.(configReloader)
+	if !ok {
+		l.AppendInvoke(errConfigReloadUnsupported)
+		return
+	}
+	reloader.AppendConfigReload(func(settings AllSettings) (func(), error) {
+		if l.disabled() != nil {
+			return func() {}, nil
+		}
+		return reload(settings)
+	})
+}
+
+// AppendDeferredProvide appends the function without gating it as the
+// function checks whether its own module has been disabled.
+func (l gatedInvokerList) AppendDeferredProvide(provide func() error) {
+	appendDeferredProvide(l.InvokerList, provide)
+}
+
+func (l gatedInvokerList) RecordSkippedCell(name string, err error) {
+	if recorder, ok := l.InvokerList.(skippedCellRecorder); ok {
+		recorder.RecordSkippedCell(name, err)
+	}
+}
+
+// gateConstructor wraps the constructor to fail with the error from
+// disabled, if any, instead of constructing the objects.
+func gateConstructor(ctor any, disabled func() error) any {
+	v := reflect.ValueOf(ctor)
+	if v.Kind() != reflect.Func {
+		// Let dig complain about it.
+		return ctor
+	}
+	typ := v.Type()
+	call := v.Call
+	if typ.IsVariadic() {
+		call = v.CallSlice
+	}
+	ins, outs := funcTypes(typ)
+	returnsError := len(outs) > 0 && outs[len(outs)-1] == errorType
+	if !returnsError {
+		outs = append(outs, errorType)
+	}
+	return reflect.MakeFunc(
+		reflect.FuncOf(ins, outs, typ.IsVariadic()),
+		func(args []reflect.Value) []reflect.Value {
+			if err := disabled(); err != nil {
+				return panicResults(outs, err)
+			}
+			results := call(args)
+			if !returnsError {
+				results = append(results, reflect.Zero(errorType))
+			}
+			return results
+		},
+	).Interface()
+}
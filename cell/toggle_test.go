@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+func TestModuleEnableFlag(t *testing.T) {
+	var events []string
+	feature := cell.Module("feature", "Feature",
+		cell.WithEnableFlag(true),
+		cell.Provide(func(lc cell.Lifecycle) *SomeObject {
+			lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+				events = append(events, "start")
+				return nil
+			}})
+			return &SomeObject{}
+		}),
+		cell.Invoke(func(*SomeObject) { events = append(events, "invoke") }),
+	)
+	objects := func(h *hive.Hive) string {
+		rec := httptest.NewRecorder()
+		h.IntrospectionHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/objects", nil))
+		return rec.Body.String()
+	}
+
+	// Enabled by default.
+	h := hive.New(feature)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, []string{"invoke", "start"}, events)
+	assert.Contains(t, objects(h), "feature")
+
+	// Disabled with the flag.
+	events = nil
+	h = hive.New(feature)
+	h.Viper().Set("enable-feature", false)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Empty(t, events)
+	assert.NotContains(t, objects(h), "feature")
+
+	// Depending on an object of a disabled module fails.
+	h = hive.New(
+		feature,
+		cell.Module("dependent", "Dependent",
+			cell.Invoke(func(*SomeObject) {}),
+		),
+	)
+	h.Viper().Set("enable-feature", "false")
+	assert.ErrorContains(t, h.Populate(), "module feature is disabled with flag --enable-feature=false")
+	assert.Empty(t, events)
+
+	// A disabled module contributes nothing to the graph: its decorators are
+	// not run and its constructors do not conflict with the ones providing
+	// the same objects outside of it.
+	decorated := false
+	h = hive.New(
+		cell.Provide(
+			func() *SomeObject { return &SomeObject{} },
+			func() *OtherObject { return &OtherObject{} },
+		),
+		cell.Module("feature", "Feature",
+			cell.WithEnableFlag(false),
+			cell.Decorate(
+				func(o *SomeObject) *SomeObject {
+					decorated = true
+					return o
+				},
+				cell.Provide(func(*SomeObject) *OtherObject { return &OtherObject{} }),
+			),
+		),
+		cell.Invoke(func(*OtherObject) {}),
+	)
+	require.NoError(t, h.Populate())
+	assert.False(t, decorated, "expected the decorator of the disabled module not to be run")
+
+	// A reloadable config in the module fails if the InvokerList cannot
+	// reload it instead of being silently not reloaded.
+	h = hive.New(
+		cell.Decorate(
+			func(l cell.InvokerList) cell.InvokerList { return plainInvokerList{l} },
+			cell.Module("reloading", "Reloading",
+				cell.WithEnableFlag(true),
+				cell.ReloadableConfig(ReloadConfig{}),
+			),
+		),
+	)
+	assert.ErrorContains(t, h.Populate(), "the InvokerList does not support reloading the configs")
+}
+
+// plainInvokerList is an InvokerList that implements none of the optional
+// interfaces.
+type plainInvokerList struct {
+	l cell.InvokerList
+}
+
+func (p plainInvokerList) AppendInvoke(invoke func() error) {
+	p.l.AppendInvoke(invoke)
+}
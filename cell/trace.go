@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package cell
+
+import (
+	"context"
+	"reflect"
+)
+
+// Tracer creates the spans for tracing the constructors and the lifecycle
+// hooks, e.g. with OpenTelemetry. The spans are named after the
+// constructors and the hooks, e.g. "foo.NewBar (bar.go:12)". The spans of
+// the lifecycle hooks are children of the span in the context given to Start
+// and Stop, and the hooks are given the context of their span. Constructors
+// have no context, so the hive decides the parent of their spans.
+// Supplied with [hive.Options] field 'Tracer'.
+//
+// An OpenTelemetry tracer can be adapted with:
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, cell.Span) {
+//		ctx, span := t.tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.span.RecordError(err)
+//			s.span.SetStatus(codes.Error, err.Error())
+//		}
+//		s.span.End()
+//	}
+type Tracer interface {
+	// Start starts a span with the given name as a child of the span in
+	// the context, if any, and returns the context with the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span. The error is non-nil if the traced operation
+	// failed.
+	End(err error)
+}
+
+// resultError returns the error of the results of a wrapped constructor,
+// whose last result is an error.
+func resultError(results []reflect.Value) error {
+	if len(results) == 0 {
+		return nil
+	}
+	err, _ := results[len(results)-1].Interface().(error)
+	return err
+}
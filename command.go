@@ -4,31 +4,87 @@
 package hive
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/spf13/cobra"
+	"go.uber.org/dig"
 )
 
 // Command constructs the cobra command for hive. The hive
-// command can be used to inspect the dependency graph.
+// command can be used to inspect the dependency graph with
+// the following subcommands:
+//
+//	objects  Output the cells and lifecycle hooks (--output=text|json|dot)
+//	dot      Output the dependency graph in graphviz dot format
+//	config   Output the effective configuration
 func (h *Hive) Command() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "hive",
 		Short: "Inspect the hive",
-		Run: func(cmd *cobra.Command, args []string) {
-			h.PrintObjects()
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.writeObjectsAs(cmd, "text")
 		},
 		TraverseChildren: false,
 	}
 	h.RegisterFlags(cmd.PersistentFlags())
 
+	var output string
+	objectsCmd := &cobra.Command{
+		Use:   "objects",
+		Short: "Output the cells and lifecycle hooks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.writeObjectsAs(cmd, output)
+		},
+		TraverseChildren: false,
+	}
+	objectsCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format, one of: text, json, dot")
+
 	cmd.AddCommand(
+		objectsCmd,
 		&cobra.Command{
-			Use:   "dot-graph",
-			Short: "Output the dependencies graph in graphviz dot format",
-			Run: func(cmd *cobra.Command, args []string) {
-				h.PrintDotGraph()
+			Use:     "dot",
+			Aliases: []string{"dot-graph"},
+			Short:   "Output the dependencies graph in graphviz dot format",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return h.writeObjectsAs(cmd, "dot")
+			},
+			TraverseChildren: false,
+		},
+		&cobra.Command{
+			Use:   "config",
+			Short: "Output the effective configuration",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := h.Populate(); err != nil {
+					return err
+				}
+				h.PrintConfig(cmd.OutOrStdout())
+				return nil
 			},
 			TraverseChildren: false,
 		})
 
 	return cmd
 }
+
+// writeObjectsAs writes the objects of the hive to the output of the
+// command in the given format.
+func (h *Hive) writeObjectsAs(cmd *cobra.Command, format string) error {
+	if err := h.Populate(); err != nil {
+		return err
+	}
+	w := cmd.OutOrStdout()
+	switch format {
+	case "text":
+		h.writeObjects(w)
+		return nil
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(h.infos())
+	case "dot":
+		return dig.Visualize(h.container, w)
+	default:
+		return fmt.Errorf("unknown output format %q, expected one of: text, json, dot", format)
+	}
+}
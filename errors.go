@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/hive/cell"
+)
+
+// The errors below describe the classes of failures in constructing and
+// populating the hive. They are returned wrapped and can be matched with
+// errors.As. New and NewWithOptions panic with the error when the hive cannot
+// be constructed:
+//
+//	var cycleErr *hive.CycleError
+//	if errors.As(err, &cycleErr) {
+//		...
+//	}
+
+// CycleError is the error for a dependency cycle between constructors.
+type CycleError struct {
+	// Types are the types in the cycle, starting and ending with the same
+	// type, e.g. []string{"*foo.A", "*foo.B", "*foo.A"}.
+	Types []string
+
+	// Constructors are the names and locations of the constructors
+	// providing the types, e.g. Constructors[0] provides Types[0].
+	Constructors []string
+}
+
+func (e *CycleError) Error() string {
+	if len(e.Types) == 0 {
+		return "dependency cycle detected"
+	}
+	var b strings.Builder
+	b.WriteString("dependency cycle detected: ")
+	for i, ctor := range e.Constructors {
+		if i >= len(e.Types)-1 {
+			break
+		}
+		fmt.Fprintf(&b, "%s (%s) -> ", e.Types[i], describeProvider(&cell.ProviderInfo{Name: ctor}))
+	}
+	b.WriteString(e.Types[len(e.Types)-1])
+	return b.String()
+}
+
+// DuplicateProvideError is the error for an object that is provided by
+// more than one constructor.
+type DuplicateProvideError struct {
+	// Type is the type of the object, e.g. "*foo.A" or
+	// `*foo.A[name = "primary"]`.
+	Type string
+
+	// Constructors are the names and locations of the constructors
+	// providing the object.
+	Constructors []string
+}
+
+func (e *DuplicateProvideError) Error() string {
+	ctors := make([]string, len(e.Constructors))
+	for i, ctor := range e.Constructors {
+		ctors[i] = describeProvider(&cell.ProviderInfo{Name: ctor})
+	}
+	return fmt.Sprintf("%s is provided by both %s", e.Type, strings.Join(ctors, " and "))
+}
+
+// MissingDependencyError is the error for constructors or invoke functions
+// depending on objects that are not provided.
+type MissingDependencyError struct {
+	// Types are the missing types, e.g. "*foo.A". Empty if the missing
+	// types could not be determined.
+	Types []string
+
+	// Err is the error from dig with hints for fixing it.
+	Err error
+}
+
+func (e *MissingDependencyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *MissingDependencyError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,12 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import "github.com/cilium/hive/cell"
+
+// NewWithError is like NewWithOptions, but returns the error instead of
+// panicking with its message.
+func NewWithError(opts Options, cells ...cell.Cell) (*Hive, error) {
+	return newHive(opts, false, cells)
+}
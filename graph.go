@@ -0,0 +1,701 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/dig"
+
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/internal"
+)
+
+// walkInfo calls fn for every node in the Info trees of the hive's cells.
+func (h *Hive) walkInfo(fn func(*cell.InfoNode)) {
+	for _, c := range h.cells {
+		walkInfoNode(c.Info(h.container), fn)
+	}
+}
+
+func walkInfoNode(info cell.Info, fn func(*cell.InfoNode)) {
+	n, ok := info.(*cell.InfoNode)
+	if !ok {
+		return
+	}
+	fn(n)
+	for _, child := range n.Children() {
+		walkInfoNode(child, fn)
+	}
+}
+
+// configs returns the populated configuration structs of the config cells.
+func (h *Hive) configs() []*cell.InfoStruct {
+	var configs []*cell.InfoStruct
+	h.walkInfo(func(n *cell.InfoNode) {
+		for _, child := range n.Children() {
+			if s, ok := child.(*cell.InfoStruct); ok {
+				configs = append(configs, s)
+			}
+		}
+	})
+	for _, c := range h.cells {
+		// Config cells at the top-level are not children of any node.
+		if s, ok := c.Info(h.container).(*cell.InfoStruct); ok {
+			configs = append(configs, s)
+		}
+	}
+	return configs
+}
+
+// scopedProvider is a constructor and the path of the module it is in.
+type scopedProvider struct {
+	info   *cell.ProviderInfo
+	module []string
+}
+
+// walkScopedInfo is like walkInfo, but also gives the path of the module
+// the node is in.
+func (h *Hive) walkScopedInfo(fn func(n *cell.InfoNode, module []string)) {
+	var walk func(info cell.Info, module []string)
+	walk = func(info cell.Info, module []string) {
+		n, ok := info.(*cell.InfoNode)
+		if !ok {
+			return
+		}
+		if m := n.Module(); m != nil {
+			module = append(slices.Clip(module), m.ID)
+		}
+		fn(n, module)
+		for _, child := range n.Children() {
+			walk(child, module)
+		}
+	}
+	for _, c := range h.cells {
+		walk(c.Info(h.container), nil)
+	}
+}
+
+// scopedProviders returns all constructors in the hive with their modules.
+func (h *Hive) scopedProviders() []scopedProvider {
+	var providers []scopedProvider
+	h.walkScopedInfo(func(n *cell.InfoNode, module []string) {
+		if p := n.Provider(); p != nil {
+			providers = append(providers, scopedProvider{p, module})
+		}
+	})
+	return providers
+}
+
+// visibleTo returns true if the constructor's outputs can be depended on
+// from the module, that is if it is exported or if the module is the same
+// or a sub-module.
+func (p scopedProvider) visibleTo(module []string) bool {
+	return p.info.Exported ||
+		(len(module) >= len(p.module) && slices.Equal(module[:len(p.module)], p.module))
+}
+
+// findCycle returns a dependency cycle between the constructors, or nil if
+// there is none.
+func (h *Hive) findCycle() *CycleError {
+	providers := h.scopedProviders()
+
+	// edge is a dependency of a constructor on the constructor
+	// providing the input.
+	type edge struct {
+		to    int
+		input cell.InfoValue
+	}
+	edges := make([][]edge, len(providers))
+	for i, consumer := range providers {
+		for _, in := range consumer.info.Inputs {
+			for j, producer := range providers {
+				if !producer.visibleTo(consumer.module) {
+					continue
+				}
+				for _, out := range producer.info.Outputs {
+					if providesInput(out, in) {
+						edges[i] = append(edges[i], edge{j, in})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(providers))
+	var (
+		stack []edge
+		cycle *CycleError
+	)
+	var visit func(i int) bool
+	visit = func(i int) bool {
+		state[i] = visiting
+		for _, e := range edges[i] {
+			switch state[e.to] {
+			case visiting:
+				// Found a cycle. Describe it starting from the constructor
+				// depended on by the edge closing the cycle.
+				j := len(stack) - 1
+				for stack[j].to != e.to {
+					j--
+				}
+				cycle = &CycleError{}
+				for _, s := range append([]edge{e}, stack[j+1:]...) {
+					cycle.Types = append(cycle.Types, s.input.String())
+					cycle.Constructors = append(cycle.Constructors, providers[s.to].info.Name)
+				}
+				cycle.Types = append(cycle.Types, e.input.String())
+				return true
+			case unvisited:
+				stack = append(stack, e)
+				if visit(e.to) {
+					return true
+				}
+				stack = stack[:len(stack)-1]
+			}
+		}
+		state[i] = visited
+		return false
+	}
+	for i := range providers {
+		if state[i] == unvisited {
+			stack = []edge{{i, cell.InfoValue{}}}
+			if visit(i) {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// findDuplicates returns the objects provided by more than one constructor.
+// Value groups and objects provided in modules not visible to each other are
+// not considered.
+func (h *Hive) findDuplicates() []error {
+	providers := h.scopedProviders()
+	var dups []error
+	for i, p := range providers {
+		for _, q := range providers[i+1:] {
+			if !p.visibleTo(q.module) && !q.visibleTo(p.module) {
+				continue
+			}
+			for _, out := range p.info.Outputs {
+				if out.Group != "" {
+					continue
+				}
+				for _, other := range q.info.Outputs {
+					if other.Group == "" && keyOf(out) == keyOf(other) {
+						dups = append(dups, &DuplicateProvideError{
+							Type:         out.String(),
+							Constructors: []string{p.info.Name, q.info.Name},
+						})
+					}
+				}
+			}
+		}
+	}
+	return dups
+}
+
+// describeProvider returns the constructor name and location in the form
+// "foo.newBar at .../bar.go:10".
+func describeProvider(info *cell.ProviderInfo) string {
+	name, location, found := strings.Cut(info.Name, " (")
+	if !found {
+		return info.Name
+	}
+	return name + " at " + strings.TrimSuffix(location, ")")
+}
+
+// valueKey identifies a non-grouped object in the graph.
+type valueKey struct {
+	typ, name string
+}
+
+func keyOf(v cell.InfoValue) valueKey {
+	return valueKey{v.Type, v.Name}
+}
+
+// providesInput returns true if the output satisfies the input, either by
+// being of the same type and name or by being a member of the value group.
+func providesInput(out, in cell.InfoValue) bool {
+	return internal.ProvidesInput(digValue(out), digValue(in))
+}
+
+// digValue returns the input or output as a value for internal.
+func digValue(v cell.InfoValue) internal.DigValue {
+	return internal.DigValue{TypeName: v.Type, Name: v.Name, Group: v.Group, Optional: v.Optional}
+}
+
+// WriteDotGraph writes the constructor dependency graph in the Graphviz
+// dot format. Each constructor is a node and has an edge to the constructors
+// providing its inputs that are visible to it. Private constructors are drawn
+// with a dashed line.
+//
+// Populates the hive if it has not been populated yet.
+func (h *Hive) WriteDotGraph(w io.Writer) error {
+	if err := h.Populate(); err != nil {
+		return err
+	}
+
+	providers := h.scopedProviders()
+
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	b.WriteString("\tnode [shape=box];\n")
+	for i, p := range providers {
+		style := ""
+		if !p.info.Exported {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "\tctor%d [label=%q%s];\n", i, p.info.Name, style)
+	}
+	for i, consumer := range providers {
+		for _, in := range consumer.info.Inputs {
+			for j, producer := range providers {
+				if !producer.visibleTo(consumer.module) {
+					continue
+				}
+				for _, out := range producer.info.Outputs {
+					if providesInput(out, in) {
+						fmt.Fprintf(&b, "\tctor%d -> ctor%d [label=%q];\n", i, j, in.String())
+						break
+					}
+				}
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// UnusedProviders returns the names and locations of the constructors whose
+// outputs are not depended on by any other constructor, invoke function,
+// decorator or replacement constructor. Constructors that provide into a
+// value group and the eager constructors are not considered.
+//
+// Populates the hive if it has not been populated yet.
+func (h *Hive) UnusedProviders() ([]string, error) {
+	if err := h.Populate(); err != nil {
+		return nil, err
+	}
+
+	used := map[valueKey]struct{}{}
+	var providers []*cell.ProviderInfo
+	markUsed := func(inputs []cell.InfoValue) {
+		for _, in := range inputs {
+			if in.Group == "" {
+				used[keyOf(in)] = struct{}{}
+			}
+		}
+	}
+	h.walkInfo(func(n *cell.InfoNode) {
+		if info := n.Provider(); info != nil {
+			if !info.Eager {
+				providers = append(providers, info)
+			}
+			markUsed(info.Inputs)
+		}
+		if info := n.Invoke(); info != nil {
+			markUsed(info.Inputs)
+		}
+		if info := n.Decorator(); info != nil {
+			markUsed(info.Inputs)
+		}
+		if info := n.Replacement(); info != nil {
+			markUsed(info.Inputs)
+		}
+	})
+
+	var unused []string
+outer:
+	for _, info := range providers {
+		for _, out := range info.Outputs {
+			if out.Group != "" {
+				continue outer
+			}
+			if _, ok := used[keyOf(out)]; ok {
+				continue outer
+			}
+		}
+		unused = append(unused, info.Name)
+	}
+	return unused, nil
+}
+
+// GraphMetrics are metrics about the constructor dependency graph for
+// finding the types that too much depends on and the long dependency chains.
+type GraphMetrics struct {
+	// FanIn is the number of constructors depending on each type, e.g.
+	// FanIn["*foo.Bar"] is 2 if two constructors have *foo.Bar as an input.
+	// The types depended on by no constructor are not included.
+	FanIn map[string]int
+
+	// FanOut is the number of inputs of each constructor by its name and
+	// location.
+	FanOut map[string]int
+
+	// LongestChain is the longest chain of constructors depending on each
+	// other, starting from the constructor that depends on the next one and
+	// ending with a constructor with no dependencies on other constructors.
+	LongestChain []string
+}
+
+// GraphMetrics computes the metrics of the constructor dependency graph from
+// the inputs and outputs of the constructors. Does not populate the hive.
+func (h *Hive) GraphMetrics() GraphMetrics {
+	providers := h.scopedProviders()
+	metrics := GraphMetrics{
+		FanIn:  map[string]int{},
+		FanOut: map[string]int{},
+	}
+
+	deps := make([][]int, len(providers))
+	for i, consumer := range providers {
+		metrics.FanOut[consumer.info.Name] = len(consumer.info.Inputs)
+		seen := map[string]struct{}{}
+		for _, in := range consumer.info.Inputs {
+			if _, ok := seen[in.String()]; !ok {
+				seen[in.String()] = struct{}{}
+				metrics.FanIn[in.String()]++
+			}
+			for j, producer := range providers {
+				if j == i || !producer.visibleTo(consumer.module) {
+					continue
+				}
+				for _, out := range producer.info.Outputs {
+					if providesInput(out, in) {
+						deps[i] = append(deps[i], j)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// chains[i] is the longest chain starting from the constructor i. The
+	// constructors being visited are skipped in case of a cycle.
+	chains := make([][]int, len(providers))
+	visiting := make([]bool, len(providers))
+	var chain func(i int) []int
+	chain = func(i int) []int {
+		if chains[i] != nil {
+			return chains[i]
+		}
+		visiting[i] = true
+		var longest []int
+		for _, j := range deps[i] {
+			if visiting[j] {
+				continue
+			}
+			if c := chain(j); len(c) > len(longest) {
+				longest = c
+			}
+		}
+		visiting[i] = false
+		chains[i] = append([]int{i}, longest...)
+		return chains[i]
+	}
+	var longest []int
+	for i := range providers {
+		if c := chain(i); len(c) > len(longest) {
+			longest = c
+		}
+	}
+	for _, i := range longest {
+		metrics.LongestChain = append(metrics.LongestChain, providers[i].info.Name)
+	}
+	return metrics
+}
+
+// InstantiatedConstructors returns the names and locations of the
+// constructors that have been called, in the order they were called. Unlike
+// UnusedProviders this reflects the objects actually constructed by the
+// invoke functions rather than the declared dependencies. Does not populate
+// the hive.
+func (h *Hive) InstantiatedConstructors() []string {
+	return h.ctors.get()
+}
+
+// constructorRecorder records the names and durations of the called
+// constructors and passes the durations on to the ConstructorMetrics given
+// in the options.
+type constructorRecorder struct {
+	next cell.ConstructorMetrics
+
+	mu      sync.Mutex
+	timings []ConstructorTiming
+}
+
+func (r *constructorRecorder) ObserveConstructor(name string, d time.Duration) {
+	r.mu.Lock()
+	r.timings = append(r.timings, ConstructorTiming{Name: name, Duration: d})
+	r.mu.Unlock()
+	if r.next != nil {
+		r.next.ObserveConstructor(name, d)
+	}
+}
+
+func (r *constructorRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for _, t := range r.timings {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func (r *constructorRecorder) getTimings() []ConstructorTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timings == nil {
+		return []ConstructorTiming{}
+	}
+	return slices.Clone(r.timings)
+}
+
+// ProvidersOf returns the constructors that have an output of the given type,
+// e.g. "*foo.Bar", and transitively the constructors that provide their inputs
+// and are visible to them.
+// The constructors are returned in the order they appear in the hive.
+//
+// Populates the hive if it has not been populated yet.
+func (h *Hive) ProvidersOf(typeName string) ([]*cell.ProviderInfo, error) {
+	if err := h.Populate(); err != nil {
+		return nil, err
+	}
+
+	providers := h.scopedProviders()
+	selected := make([]bool, len(providers))
+	var queue []int
+	for i, p := range providers {
+		for _, out := range p.info.Outputs {
+			if out.Type == typeName {
+				selected[i] = true
+				queue = append(queue, i)
+				break
+			}
+		}
+	}
+	for len(queue) > 0 {
+		consumer := providers[queue[0]]
+		queue = queue[1:]
+		for _, in := range consumer.info.Inputs {
+			for j, producer := range providers {
+				if selected[j] || !producer.visibleTo(consumer.module) {
+					continue
+				}
+				for _, out := range producer.info.Outputs {
+					if providesInput(out, in) {
+						selected[j] = true
+						queue = append(queue, j)
+						break
+					}
+				}
+			}
+		}
+	}
+
+	var result []*cell.ProviderInfo
+	for i, p := range providers {
+		if selected[i] {
+			result = append(result, p.info)
+		}
+	}
+	return result, nil
+}
+
+// withMissingHints turns an error about missing dependencies into a
+// MissingDependencyError with hints. The missing inputs are the ones of the
+// invoke functions, and of the constructors they depend on, that have no
+// visible provider among the recorded constructors and are not provided by
+// the hive itself. For each missing input it suggests the outputs with a
+// similar type, e.g. "Foo" for "*Foo", and tells if the input is provided
+// privately in a module not visible to the dependent.
+func (h *Hive) withMissingHints(err error) error {
+	// Only errors from dig itself, and not from the constructors or the
+	// invoke functions, may be about missing dependencies.
+	var digErr dig.Error
+	if err == nil || !errors.As(dig.RootCause(err), &digErr) {
+		return err
+	}
+
+	providers := h.scopedProviders()
+	providedBy := func(p scopedProvider, in cell.InfoValue, module []string) bool {
+		if !p.visibleTo(module) {
+			return false
+		}
+		for _, out := range p.info.Outputs {
+			if providesInput(out, in) {
+				return true
+			}
+		}
+		return false
+	}
+	provided := func(in cell.InfoValue, module []string) bool {
+		for _, out := range internal.HiveObjects {
+			if internal.ProvidesInput(out, digValue(in)) {
+				return true
+			}
+		}
+		for _, p := range providers {
+			if providedBy(p, in, module) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Find the constructors the invoke functions depend on.
+	needed := map[*cell.ProviderInfo]bool{}
+	var queue []scopedProvider
+	need := func(inputs []cell.InfoValue, module []string) {
+		for _, in := range inputs {
+			for _, p := range providers {
+				if !needed[p.info] && providedBy(p, in, module) {
+					needed[p.info] = true
+					queue = append(queue, p)
+				}
+			}
+		}
+	}
+	h.walkScopedInfo(func(n *cell.InfoNode, module []string) {
+		if info := n.Invoke(); info != nil {
+			need(info.Inputs, module)
+		}
+	})
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		need(p.info.Inputs, p.module)
+	}
+
+	// missing is an input that has no visible provider.
+	type missing struct {
+		input  cell.InfoValue
+		module []string
+	}
+	var missings []missing
+	seen := map[string]bool{}
+	checkInputs := func(inputs []cell.InfoValue, module []string) {
+		for _, in := range inputs {
+			if in.Optional || in.Group != "" {
+				continue
+			}
+			key := in.String()
+			if seen[key] || provided(in, module) {
+				continue
+			}
+			seen[key] = true
+			missings = append(missings, missing{in, module})
+		}
+	}
+	h.walkScopedInfo(func(n *cell.InfoNode, module []string) {
+		if p := n.Provider(); p != nil && needed[p] {
+			checkInputs(p.Inputs, module)
+		}
+		if info := n.Invoke(); info != nil {
+			checkInputs(info.Inputs, module)
+		}
+	})
+	if len(missings) == 0 {
+		return err
+	}
+
+	missingErr := &MissingDependencyError{Err: err}
+	var b strings.Builder
+	for _, m := range missings {
+		missingErr.Types = append(missingErr.Types, m.input.String())
+		var hints []string
+		for _, p := range providers {
+			for _, out := range p.info.Outputs {
+				if out.Group != "" {
+					continue
+				}
+				switch {
+				case keyOf(out) == keyOf(m.input):
+					hints = append(hints, fmt.Sprintf("%s is provided privately (🔒️) by %s in %s and is not visible to %s. "+
+						"Move the dependent into module %q or a module nested in it, or use cell.Provide instead of cell.ProvidePrivate to export it",
+						out, describeProvider(p.info), describeModule(p.module), describeModule(m.module), strings.Join(p.module, ".")))
+				case out.Name == m.input.Name && similarTypes(out.Type, m.input.Type):
+					hints = append(hints, fmt.Sprintf("did you mean %s provided by %s?", out, describeProvider(p.info)))
+				}
+			}
+		}
+		for _, d := range h.disabled {
+			for _, out := range d.Outputs {
+				if providesInput(out, m.input) {
+					hints = append(hints, fmt.Sprintf("%s is not provided as %s", out, d.Err))
+				}
+			}
+		}
+		if len(hints) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\nHints for missing type %s:", m.input)
+		for _, hint := range hints {
+			fmt.Fprintf(&b, "\n  - %s", hint)
+		}
+	}
+	if b.Len() > 0 {
+		missingErr.Err = fmt.Errorf("%w%s", err, b.String())
+	}
+	return missingErr
+}
+
+// describeModule describes the module by its full ID, e.g. `module "foo.bar"`,
+// or the top level of the hive if the module path is empty.
+func describeModule(module []string) string {
+	if len(module) == 0 {
+		return "the top level of the hive"
+	}
+	return fmt.Sprintf("module %q", strings.Join(module, "."))
+}
+
+// similarTypes returns true if the type names are similar enough to suggest
+// one for the other, e.g. they differ only by being a pointer or the edit
+// distance between them is small.
+func similarTypes(a, b string) bool {
+	if strings.TrimLeft(a, "*") == strings.TrimLeft(b, "*") {
+		return true
+	}
+	// Allow one edit per 8 characters, e.g. a typo or a different package
+	// with a similar name.
+	return editDistance(a, b) <= max(1, min(len(a), len(b))/8)
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"context"
+	"time"
+
+	"github.com/cilium/hive/cell"
+)
+
+type healthParams struct {
+	cell.In
+	Health cell.Health `optional:"true"`
+}
+
+// Health returns the statuses reported to cell.Health by the cells of the
+// hive. The statuses are scoped by module if cell.HealthModuleDecorator is
+// included in the ModuleDecorators of the options. Returns
+// nil if cell.Health is not provided or does not implement
+// cell.HealthStatuser, e.g. with cell.SimpleHealthCell it does.
+//
+// Populates the hive if it has not been populated yet.
+func (h *Hive) Health() ([]cell.HealthStatus, error) {
+	if err := h.Populate(); err != nil {
+		return nil, err
+	}
+	var statuses []cell.HealthStatus
+	err := h.container.Invoke(func(p healthParams) {
+		if s, ok := p.Health.(cell.HealthStatuser); ok {
+			statuses = s.Statuses()
+		}
+	})
+	return statuses, err
+}
+
+// Ready returns true if the hive has been started and none of the health
+// scopes are degraded. A scope that becomes degraded after the hive is ready
+// makes it not ready until the scope reports OK again. The scopes that have
+// not reported their status are excluded unless Options.RequireHealthReports
+// is set. Scopes with children are excluded if they have not reported, as
+// these are e.g. the scopes of the modules.
+//
+// Meant for implementing a readiness probe.
+func (h *Hive) Ready() bool {
+	if !h.started.Load() {
+		return false
+	}
+	statuses, err := h.Health()
+	if err != nil {
+		return false
+	}
+	return h.healthy(statuses)
+}
+
+func (h *Hive) healthy(statuses []cell.HealthStatus) bool {
+	for _, s := range statuses {
+		switch {
+		case s.Level == cell.StatusDegraded:
+			return false
+		case s.Level == cell.StatusUnknown && len(s.Children) == 0 && h.opts.RequireHealthReports:
+			return false
+		case !h.healthy(s.Children):
+			return false
+		}
+	}
+	return true
+}
+
+// readyPollInterval is how often WaitReady checks whether the hive is ready.
+const readyPollInterval = 100 * time.Millisecond
+
+// WaitReady blocks until the hive is ready (see Ready) or the context is
+// cancelled.
+func (h *Hive) WaitReady(ctx context.Context) error {
+	if err := h.Populate(); err != nil {
+		return err
+	}
+
+	var changes <-chan cell.HealthStatus
+	err := h.container.Invoke(func(p healthParams) {
+		if s, ok := p.Health.(interface {
+			Subscribe(context.Context) <-chan cell.HealthStatus
+		}); ok {
+			changes = s.Subscribe(ctx)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// Poll in addition to the health changes as the hive needs to be
+	// started and the health implementation might not support
+	// subscriptions.
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	for !h.Ready() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changes:
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// flattenHealth adds the statuses and the statuses of their children to the
+// map by their scopes.
+func flattenHealth(statuses []cell.HealthStatus, m map[string]cell.HealthStatus) {
+	for _, s := range statuses {
+		m[s.Scope] = s
+		flattenHealth(s.Children, m)
+	}
+}
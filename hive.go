@@ -7,12 +7,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/rand"
 	"os"
 	"os/signal"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
-	"syscall"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/pflag"
@@ -20,14 +26,25 @@ import (
 	"go.uber.org/dig"
 
 	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/internal"
 )
 
 type Options struct {
+	// Logger is the logger provided to the cells as *slog.Logger, scoped by
+	// module. Defaults to slog.Default() if nil, also with the zero Options.
 	Logger *slog.Logger
 
+	// FrameworkLogger is an optional logger for the logs of the hive itself,
+	// e.g. the constructors taking longer than the LogThreshold and the
+	// progress of the start and stop, in order to tune their verbosity
+	// separately from the logs of the cells. Defaults to Logger.
+	FrameworkLogger *slog.Logger
+
 	// EnvPrefix is the prefix to use for environment variables, e.g.
-	// with prefix "CILIUM" the flag "foo" can be set with environment
-	// variable "CILIUM_FOO".
+	// with prefix "CILIUM" the flag "foo-timeout" can be set with environment
+	// variable "CILIUM_FOO_TIMEOUT". A flag given on the command-line takes
+	// precedence over the environment variable, which in turn takes
+	// precedence over the default value.
 	EnvPrefix string
 
 	// ModuleDecorator is an optional set of decorator functions to use for each
@@ -43,6 +60,9 @@ type Options struct {
 	// The above would give each cell within a module an augmented version of 'Foo'.
 	// The object that is being decorated (the return value) must already exist in
 	// the object graph.
+	//
+	// Include cell.HealthModuleDecorator for scoping cell.Health to each
+	// module.
 	ModuleDecorators cell.ModuleDecorators
 
 	// ModulePrivateProvider is an optional set of private provide functions to
@@ -62,16 +82,136 @@ type Options struct {
 	// in [cell/config.go] for examples.
 	DecodeHooks cell.DecodeHooks
 
+	// StartTimeout and StopTimeout are the time allotted for running the
+	// start and the stop hooks in Run and RunContext. The context of the
+	// hooks is cancelled after the timeout. Stop hooks that do not return
+	// shortly after the cancellation are abandoned: they are logged and named
+	// in the error returned by Run, and the hooks not yet run are skipped, so
+	// that a hanging stop hook does not prevent the process from exiting.
 	StartTimeout time.Duration
 	StopTimeout  time.Duration
 
 	// LogThreshold is an optional threshold to reduce logging verbosity.
-	// When an Invoke or Lifecycle Start/Stop hook takes longer than this
-	// threshold, it will be logged at Info level. Otherwise it is logged
-	// at Debug level.
+	// When a constructor, Invoke or Lifecycle Start/Stop hook takes longer
+	// than this threshold, it will be logged at Info level. Otherwise it is
+	// logged at Debug level. The threshold for constructors can be overridden
+	// with cell.ProvideWithThreshold.
 	LogThreshold time.Duration
+
+	// StrictProvideThreshold if true makes populating the hive fail with an
+	// error naming the constructor if a constructor takes longer than the
+	// LogThreshold, or the threshold set with cell.ProvideWithThreshold.
+	// Useful in CI for catching slow constructors. Has no effect if the
+	// threshold is zero.
+	StrictProvideThreshold bool
+
+	// MaxModuleDepth if positive is the maximum depth of nested modules,
+	// e.g. 2 allows modules within modules but not deeper. Constructing the
+	// hive fails with an error naming the module nested too deep. Helps to
+	// keep the module tree flat and comprehensible.
+	MaxModuleDepth int
+
+	// StartupReport is an optional path of a file to write the StartupReport
+	// to as JSON once the hive has started, e.g. for diagnosing slow startups
+	// without scraping the logs.
+	StartupReport string
+
+	// ASCIIInfo if true prints the cells in PrintObjects and the objects
+	// command with plain ASCII glyphs, e.g. "[ctor]" instead of "🚧". Can
+	// also be enabled with the environment variable HIVE_ASCII_INFO=true.
+	ASCIIInfo bool
+
+	// DeferCycleCheck if true defers the check for dependency cycles from
+	// providing each constructor to the next time the container is invoked,
+	// e.g. when the next cell is applied or when the hive is populated. This
+	// makes constructing hives with cells providing many constructors faster,
+	// e.g. in tests that build the same hive many times. The tradeoff is that
+	// a cycle is detected later, so it is best left disabled in production.
+	DeferCycleCheck bool
+
+	// InfoHealth if true annotates the modules printed by PrintObjects with
+	// their rolled up health status, e.g. "[health: Degraded]", once the
+	// hive has been started. Not shown before the start.
+	InfoHealth bool
+
+	// HookTimeout is an optional timeout for each lifecycle start and stop
+	// hook. If a hook does not complete in time, the start or stop fails with
+	// an error naming the hook. Disabled when zero. Unlike StartTimeout and
+	// StopTimeout this limits the individual hooks and not all of them.
+	HookTimeout time.Duration
+
+	// ParallelStop if larger than one is the maximum number of lifecycle stop
+	// hooks to run in parallel. Hooks of objects that do not depend on each
+	// other can be stopped in parallel. The stop hooks of an object are still
+	// run before the stop hooks of its dependencies.
+	ParallelStop int
+
+	// ParallelStart if larger than one is the maximum number of lifecycle
+	// start hooks to run in parallel. Hooks of objects that do not depend on
+	// each other can be started in parallel. The start hooks of an object are
+	// still run after the start hooks of its dependencies.
+	ParallelStart int
+
+	// StartWatchdog is an optional duration after which a start that has not
+	// completed logs the start hook that is still running, repeating every
+	// StartWatchdog. Turns hangs during start into actionable logs. Disabled
+	// when zero. If WatchdogStacks is true the stacks of all goroutines are
+	// logged as well.
+	StartWatchdog  time.Duration
+	WatchdogStacks bool
+
+	// StartProgress is an optional callback invoked after each successful
+	// lifecycle start hook with the number of start hooks executed so far,
+	// the total number of start hooks and the name of the hook. Useful for
+	// reporting the progress of a long start.
+	StartProgress func(done, total int, name string)
+
+	// ConstructorMetrics is an optional sink for the durations of the
+	// constructors. If nil, the durations are only logged.
+	ConstructorMetrics cell.ConstructorMetrics
+
+	// ConstructorCache is an optional cache for the results of the pure
+	// constructors provided with cell.ProvidePure. Sharing the cache between
+	// hives avoids calling the pure constructors again with the same inputs.
+	// See hivetest.WithConstructorCache.
+	ConstructorCache *cell.ConstructorCache
+
+	// LifecycleMetrics is an optional sink for the durations of the lifecycle
+	// start and stop hooks. If nil, the durations are only logged.
+	LifecycleMetrics cell.LifecycleMetrics
+
+	// Clock is an optional source of time for measuring the durations of
+	// the constructors, invoke functions, lifecycle hooks and the startup.
+	// Also provided to the cells as cell.Clock. Defaults to cell.RealClock.
+	// Useful for testing the log thresholds without sleeping.
+	Clock cell.Clock
+
+	// RandSeed is an optional seed for the random source provided to the
+	// cells as *rand.Rand (math/rand), in order to make the behaviour of the
+	// hive reproducible in tests. If zero, the source is seeded from
+	// crypto/rand. The source is safe for concurrent use, with the exception
+	// of its Read method.
+	RandSeed int64
+
+	// Tracer is an optional tracer for tracing the start and stop of the
+	// hive, e.g. with OpenTelemetry. Start and Stop create the root spans
+	// "hive start" and "hive stop" with a child span for each lifecycle hook,
+	// and for each constructor called while starting.
+	Tracer cell.Tracer
+
+	// PhaseHooks are the functions run at the boundaries of the lifecycle of
+	// the hive, e.g. before the start hooks are run. See PhaseHook.
+	PhaseHooks []PhaseHook
+
+	// RequireHealthReports if true makes Hive.Ready() false until every
+	// health scope without children has reported its status. Otherwise the
+	// scopes that have not reported are excluded.
+	RequireHealthReports bool
 }
 
+// DefaultOptions returns the options used by New. The Logger is left unset
+// for the hive to use slog.Default(), so that the *slog.Logger injected into
+// the cells is never nil even if no logger is given.
 func DefaultOptions() Options {
 	return Options{
 		Logger:           nil, // Will use slog.Default()
@@ -105,12 +245,48 @@ type Hive struct {
 	container       *dig.Container
 	cells           []cell.Cell
 	shutdown        chan error
+	shutdownMu      sync.Mutex
+	shutdownCause   *shutdownCause
 	flags           *pflag.FlagSet
 	viper           *viper.Viper
 	lifecycle       cell.Lifecycle
 	populated       bool
-	invokes         []func() error
+	invokes         []orderedInvoke
+	bestEffortErrs  []error
+	configChecks    []func() error
+	deferred        []func() error
+	disabled        []*cell.DisabledProviderError
+	reloadMu        sync.Mutex
+	configReloads   []func(cell.AllSettings) (func(), error)
+	skippedMu       sync.Mutex
+	skipped         []SkippedCell
 	configOverrides []any
+	started         atomic.Bool
+	timings         *hookTimings
+	ctors           *constructorRecorder
+	rootCtx         context.Context
+	rootCancel      context.CancelFunc
+	startup         StartupDurations
+	tracer          *constructorTracer
+}
+
+// StartupDurations is the breakdown of the time it took to start the hive.
+type StartupDurations struct {
+	// Build is the time it took to apply the cells when constructing the
+	// hive, e.g. registering the constructors and the flags.
+	Build time.Duration
+
+	// Invoke is the time it took to populate the hive, that is to check
+	// the configs and to run the invoke functions and the constructors.
+	Invoke time.Duration
+
+	// Start is the time it took to run the start hooks.
+	Start time.Duration
+}
+
+// Total is the sum of the durations.
+func (d StartupDurations) Total() time.Duration {
+	return d.Build + d.Invoke + d.Start
 }
 
 // New returns a new hive that can be run, or inspected.
@@ -127,47 +303,129 @@ func New(cells ...cell.Cell) *Hive {
 }
 
 func NewWithOptions(opts Options, cells ...cell.Cell) *Hive {
+	h, err := newHive(opts, false, cells)
+	if err != nil {
+		panic(err.Error())
+	}
+	return h
+}
+
+// newContainer returns the dig container for a hive with the options. No
+// constructors or invoke functions are called in a container in dry run mode.
+func newContainer(opts Options, dryRun bool) *dig.Container {
+	return dig.New(append(containerOptions(opts), dig.DryRun(dryRun))...)
+}
+
+// containerOptions returns the options for the containers of the hive and
+// its sub-hives.
+func containerOptions(opts Options) cell.ContainerOptions {
+	var digOpts cell.ContainerOptions
+	if opts.DeferCycleCheck {
+		digOpts = append(digOpts, dig.DeferAcyclicVerification())
+	}
+	return digOpts
+}
+
+// newHive constructs the hive and applies the cells to its container, which
+// is in dry run mode if dryRun is true.
+func newHive(opts Options, dryRun bool, cells []cell.Cell) (*Hive, error) {
 	if opts.Logger == nil {
 		opts.Logger = slog.Default()
 	}
+	if opts.FrameworkLogger == nil {
+		opts.FrameworkLogger = opts.Logger
+	}
+	if opts.Clock == nil {
+		opts.Clock = cell.RealClock
+	}
+	timings := &hookTimings{next: opts.LifecycleMetrics}
 	h := &Hive{
-		log:       opts.Logger,
+		log:       opts.FrameworkLogger,
 		opts:      opts,
-		container: dig.New(),
+		container: newContainer(opts, dryRun),
 		cells:     cells,
 		viper:     viper.New(),
 		flags:     pflag.NewFlagSet("", pflag.ContinueOnError),
 		lifecycle: &cell.DefaultLifecycle{
-			LogThreshold: opts.LogThreshold,
+			LogThreshold:   opts.LogThreshold,
+			HookTimeout:    opts.HookTimeout,
+			ParallelStop:   opts.ParallelStop,
+			ParallelStart:  opts.ParallelStart,
+			StartWatchdog:  opts.StartWatchdog,
+			WatchdogStacks: opts.WatchdogStacks,
+			StartProgress:  opts.StartProgress,
+			Clock:          opts.Clock,
+			Tracer:         opts.Tracer,
+			Metrics:        timings,
 		},
+		timings:         timings,
+		tracer:          &constructorTracer{next: opts.Tracer},
+		ctors:           &constructorRecorder{next: opts.ConstructorMetrics},
 		shutdown:        make(chan error, 1),
 		configOverrides: nil,
 	}
+	h.rootCtx, h.rootCancel = context.WithCancel(context.Background())
 
-	if err := h.provideDefaults(); err != nil {
-		panic(fmt.Sprintf("Failed to provide defaults: %s", err))
+	// The cells invoke functions when they are applied, e.g. to append their
+	// invoke functions, which are not called in dry run mode. Apply them to
+	// a mirror of the container as well in which they are called.
+	var cont cell.Container = h.container
+	applied := func() {}
+	if dryRun {
+		cont, applied = cell.NewDryRunContainer(h.container, newContainer(opts, false))
+	}
+
+	if err := h.provideDefaults(cont); err != nil {
+		return nil, fmt.Errorf("Failed to provide defaults: %w", err)
 	}
 
 	// Apply all cells to the container. This registers all constructors
 	// and adds all config flags. Invokes are delayed until Start() is
 	// called.
+	t0 := opts.Clock.Now()
+	var errs []error
 	for _, cell := range cells {
-		if err := cell.Apply(opts.Logger, h.container, opts.LogThreshold); err != nil {
-			panic(fmt.Sprintf("Failed to apply cell: %s", err))
+		if err := cell.Apply(opts.FrameworkLogger, cont, opts.LogThreshold); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	applied()
+	h.startup.Build = opts.Clock.Since(t0)
+	if err := errors.Join(errs...); err != nil {
+		if dig.IsCycleDetected(err) {
+			// Describe the cycle in terms of the types and constructors
+			// rather than the signatures of the wrapped constructors.
+			if cycle := h.findCycle(); cycle != nil {
+				return nil, fmt.Errorf("Failed to apply cell: %w", cycle)
+			}
 		}
+		// Name both constructors providing the same type ahead of the
+		// errors from dig.
+		if dups := h.findDuplicates(); len(dups) > 0 {
+			return nil, fmt.Errorf("Failed to apply cell: %w.\n"+
+				"Hint: use cell.ProvideNamed to provide distinct named objects, or cell.ProvideGroup to provide them into a value group\n%w",
+				errors.Join(dups...), err)
+		}
+		return nil, fmt.Errorf("Failed to apply cell: %w", err)
 	}
 
 	// Bind the newly registered flags to viper.
+	var err error
 	h.flags.VisitAll(func(f *pflag.Flag) {
-		if err := h.viper.BindPFlag(f.Name, f); err != nil {
-			panic(fmt.Sprintf("BindPFlag: %s", err))
+		if err != nil {
+			return
 		}
-		if err := h.viper.BindEnv(f.Name, h.getEnvName(f.Name)); err != nil {
-			panic(fmt.Sprintf("BindEnv: %s", err))
+		if bindErr := h.viper.BindPFlag(f.Name, f); bindErr != nil {
+			err = fmt.Errorf("BindPFlag: %s", bindErr)
+		} else if bindErr := h.viper.BindEnv(f.Name, h.getEnvName(f.Name)); bindErr != nil {
+			err = fmt.Errorf("BindEnv: %s", bindErr)
 		}
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return h
+	return h, nil
 }
 
 // RegisterFlags adds all flags in the hive to the given flag set.
@@ -185,6 +443,65 @@ func (h *Hive) RegisterFlags(flags *pflag.FlagSet) {
 	})
 }
 
+// FlagUsages returns the usages of the hive's flags grouped by the modules
+// they were registered in, for showing in the help of the command running
+// the hive. The flags that are not in a module come first, followed by a
+// section for each module in the order of the module IDs. The flags are
+// sorted by name within each section. Use with e.g. cobra.Command:
+//
+//	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
+//		fmt.Fprintf(cmd.OutOrStderr(), "Usage:\n  %s\n\n%s", cmd.UseLine(), h.FlagUsages())
+//		return nil
+//	})
+func (h *Hive) FlagUsages() string {
+	var (
+		top          = pflag.NewFlagSet("", pflag.ContinueOnError)
+		modules      = map[string]*pflag.FlagSet{}
+		descriptions = map[string]string{}
+	)
+	h.flags.VisitAll(func(f *pflag.Flag) {
+		id, description := cell.FlagModule(f)
+		if id == "" {
+			top.AddFlag(f)
+			return
+		}
+		if modules[id] == nil {
+			modules[id] = pflag.NewFlagSet(id, pflag.ContinueOnError)
+			descriptions[id] = description
+		}
+		modules[id].AddFlag(f)
+	})
+
+	var sections []string
+	if top.HasFlags() {
+		sections = append(sections, "Flags:\n"+top.FlagUsages())
+	}
+	ids := make([]string, 0, len(modules))
+	for id := range modules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sections = append(sections, fmt.Sprintf("Module %s (%s):\n%s", id, descriptions[id], modules[id].FlagUsages()))
+	}
+	return strings.Join(sections, "\n")
+}
+
+// Container returns the dig container of the hive. It is an escape hatch for
+// the advanced uses not covered by the hive, e.g. custom decorations or
+// introspection, and is not covered by the stability guarantees of the hive:
+// the hive's use of the container may change between versions.
+//
+// The constructors of the cells are provided to the container when the hive
+// is constructed, but the configs can only be constructed after the hive has
+// been populated. Decorations need to be added before Populate.
+func (h *Hive) Container() *dig.Container {
+	if h.container == nil {
+		panic("hive.Container() called on a hive not constructed with hive.New or hive.NewWithOptions")
+	}
+	return h.container
+}
+
 // Viper returns the hive's viper instance.
 func (h *Hive) Viper() *viper.Viper {
 	return h.viper
@@ -197,28 +514,56 @@ type defaults struct {
 	Lifecycle              cell.Lifecycle
 	Logger                 *slog.Logger
 	RootLogger             cell.RootLogger
+	RootContext            cell.RootContext
 	Shutdowner             Shutdowner
 	InvokerList            cell.InvokerList
 	EmptyFullModuleID      cell.FullModuleID
 	DecodeHooks            cell.DecodeHooks
 	ModuleDecorators       cell.ModuleDecorators
 	ModulePrivateProviders cell.ModulePrivateProviders
+	ConstructorMetrics     cell.ConstructorMetrics
+	Clock                  cell.Clock
+	Rand                   *rand.Rand
+	StrictProvideThreshold cell.StrictProvideThreshold
+	ConstructorCache       *cell.ConstructorCache
+	Tracer                 cell.Tracer
+	MaxModuleDepth         cell.MaxModuleDepth
+	ContainerOptions       cell.ContainerOptions
 }
 
-func (h *Hive) provideDefaults() error {
+func init() {
+	// The objects provided with provideDefaults.
+	typ := reflect.TypeOf(defaults{})
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); !f.Anonymous {
+			internal.HiveObjects = append(internal.HiveObjects,
+				internal.DigValue{Type: f.Type, TypeName: f.Type.String()})
+		}
+	}
+}
 
-	return h.container.Provide(func() defaults {
+func (h *Hive) provideDefaults(c cell.Container) error {
+	return c.Provide(func() defaults {
 		return defaults{
 			Flags:                  h.flags,
 			Lifecycle:              h.lifecycle,
 			Logger:                 h.opts.Logger,
 			RootLogger:             cell.RootLogger(h.opts.Logger),
+			RootContext:            h.rootCtx,
 			Shutdowner:             h,
 			InvokerList:            h,
 			EmptyFullModuleID:      nil,
 			DecodeHooks:            h.opts.DecodeHooks,
 			ModuleDecorators:       h.opts.ModuleDecorators,
 			ModulePrivateProviders: h.opts.ModulePrivateProviders,
+			ConstructorMetrics:     h.ctors,
+			Clock:                  h.opts.Clock,
+			Rand:                   newRand(h.opts.RandSeed),
+			StrictProvideThreshold: cell.StrictProvideThreshold(h.opts.StrictProvideThreshold),
+			ConstructorCache:       h.opts.ConstructorCache,
+			Tracer:                 h.ctorTracer(),
+			MaxModuleDepth:         cell.MaxModuleDepth(h.opts.MaxModuleDepth),
+			ContainerOptions:       containerOptions(h.opts),
 		}
 	})
 }
@@ -233,50 +578,165 @@ func AddConfigOverride[Cfg cell.Flagger](h *Hive, override func(*Cfg)) {
 
 // Run populates the cell configurations and runs the hive cells.
 // Interrupt signal or call to Shutdowner.Shutdown() will cause the hive to stop.
-func (h *Hive) Run() error {
-	startCtx, cancel := context.WithTimeout(context.Background(), h.opts.StartTimeout)
+// The signals can be changed with WithSignals or disabled with WithoutSignals.
+func (h *Hive) Run(opts ...RunOption) error {
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	var errs error
-	if err := h.Start(startCtx); err != nil {
-		errs = errors.Join(errs, fmt.Errorf("failed to start: %w", err))
+	ro := newRunOptions(opts)
+	signals := ro.channel
+	if signals == nil && len(ro.signals) > 0 {
+		ch := make(chan os.Signal, 1)
+		defer signal.Stop(ch)
+		signal.Notify(ch, ro.signals...)
+		signals = ch
+	}
+	if len(ro.signals) > 0 {
+		go h.handleSignals(ctx, cancel, signals, &ro)
 	}
 
-	// If start was successful, wait for Shutdown() or interrupt.
+	return h.RunContext(ctx)
+}
+
+// handleSignals cancels the context when a signal in the signal set is
+// received, or calls the reload function on SIGHUP if one is set.
+func (h *Hive) handleSignals(ctx context.Context, cancel context.CancelFunc, signals <-chan os.Signal, ro *runOptions) {
+	for {
+		select {
+		case sig := <-signals:
+			switch {
+			case !slices.Contains(ro.signals, sig):
+				continue
+			case ro.isReload(sig):
+				h.log.Info("Signal received, reloading", "signal", sig)
+				ro.reload()
+			default:
+				h.log.Info("Signal received", "signal", sig)
+				h.setShutdownReason(ShutdownSignal, nil)
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunContext populates the cell configurations and runs the hive cells
+// until the context is cancelled or Shutdowner.Shutdown() is called. The
+// stop hooks are then run with a fresh context limited by the stop timeout.
+// Cancelling the context during start aborts the start.
+//
+// If the start fails the started hooks are rolled back by running their stop
+// hooks. When the rollback fails too, the returned error joins the start error,
+// labeled as the primary cause, with the rollback error.
+//
+// Use RunContext instead of Run when embedding the hive in a larger program
+// that handles the signals itself.
+func (h *Hive) RunContext(ctx context.Context) error {
+	startCtx, cancel := context.WithTimeout(ctx, h.opts.StartTimeout)
+	defer cancel()
+
+	var errs, startErr error
+	if startErr = h.Start(startCtx); startErr != nil {
+		errs = fmt.Errorf("failed to start: %w", startErr)
+		h.setShutdownReason(ShutdownError, startErr)
+	}
+
+	// If start was successful, wait for Shutdown() or for the context
+	// to be cancelled.
 	if errs == nil {
-		errs = errors.Join(errs, h.waitForSignalOrShutdown())
+		errs = errors.Join(errs, h.waitForContextOrShutdown(ctx))
 	}
 
 	stopCtx, cancel := context.WithTimeout(context.Background(), h.opts.StopTimeout)
 	defer cancel()
 
 	if err := h.Stop(stopCtx); err != nil {
-		errs = errors.Join(errs, fmt.Errorf("failed to stop: %w", err))
+		if startErr != nil {
+			errs = errors.Join(
+				fmt.Errorf("failed to start (primary cause): %w", startErr),
+				fmt.Errorf("failed to roll back the start: %w", err))
+		} else {
+			errs = errors.Join(errs, fmt.Errorf("failed to stop: %w", err))
+		}
+	}
+
+	// Report the failed best-effort invoke functions separately from the
+	// fatal errors above.
+	if len(h.bestEffortErrs) > 0 {
+		errs = errors.Join(errs, &cell.BestEffortError{Err: errors.Join(h.bestEffortErrs...)})
 	}
 	return errs
 }
 
-func (h *Hive) waitForSignalOrShutdown() error {
-	signals := make(chan os.Signal, 1)
-	defer signal.Stop(signals)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+func (h *Hive) waitForContextOrShutdown(ctx context.Context) error {
 	select {
-	case sig := <-signals:
-		h.log.Info("Signal received", "signal", sig)
+	case <-ctx.Done():
 		return nil
 	case err := <-h.shutdown:
+		if err != nil {
+			h.log.Error("Shutdown requested with an error", "error", err)
+		}
 		return err
 	}
 }
 
 // Populate instantiates the hive. Use for testing that the hive can
 // be instantiated.
-func (h *Hive) Populate() error {
+//
+// The optional targets are pointers that are assigned the objects of the
+// pointed-to types from the hive once it has been instantiated. The start
+// hooks are not executed. To populate named objects or value groups, use a
+// pointer to a parameter struct:
+//
+//	var (
+//		foo    *Foo
+//		params struct {
+//			cell.In
+//			Handlers []Handler `group:"handlers"`
+//		}
+//	)
+//	err := h.Populate(&foo, &params)
+func (h *Hive) Populate(targets ...any) error {
+	if err := h.populate(); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if err := h.populateTarget(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// populateTarget assigns the object of the type pointed to by the target.
+func (h *Hive) populateTarget(target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("populate target %T is not a non-nil pointer", target)
+	}
+	fn := reflect.MakeFunc(
+		reflect.FuncOf([]reflect.Type{v.Type().Elem()}, nil, false),
+		func(args []reflect.Value) []reflect.Value {
+			v.Elem().Set(args[0])
+			return nil
+		})
+	if err := h.container.Invoke(fn.Interface()); err != nil {
+		return fmt.Errorf("failed to populate %s: %w", v.Type().Elem(), err)
+	}
+	return nil
+}
+
+func (h *Hive) populate() error {
 	if h.populated {
 		return nil
 	}
 	h.populated = true
 
+	t0 := h.opts.Clock.Now()
+	defer func() { h.startup.Invoke = h.opts.Clock.Since(t0) }()
+
 	// Provide all the parsed settings to the config cells.
 	err := h.container.Provide(
 		func() cell.AllSettings {
@@ -313,47 +773,350 @@ func (h *Hive) Populate() error {
 		}
 	}
 
+	// Add the constructors of the modules with an enable flag and of the
+	// optional cells now that the flags have been parsed.
+	if err := h.provideDeferred(); err != nil {
+		return err
+	}
+
+	// Check all the configs before constructing anything else in order to
+	// report all the invalid configs at once.
+	var errs []error
+	for _, check := range h.configChecks {
+		if err := check(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
 	// Execute the invoke functions to construct the objects.
-	for _, invoke := range h.invokes {
+	invokes, err := sortInvokes(h.invokes)
+	if err != nil {
+		return err
+	}
+	for _, invoke := range invokes {
 		if err := invoke(); err != nil {
-			return err
+			var bestEffortErr *cell.BestEffortError
+			if errors.As(err, &bestEffortErr) {
+				h.bestEffortErrs = append(h.bestEffortErrs, bestEffortErr.Err)
+				continue
+			}
+			if dig.IsCycleDetected(err) {
+				// Detected only now if the check was deferred.
+				if cycle := h.findCycle(); cycle != nil {
+					return cycle
+				}
+			}
+			return h.withMissingHints(err)
 		}
 	}
 	return nil
 }
 
+// Validate checks that the dependencies of all the invoke functions can be
+// satisfied without calling any constructors, invoke functions or lifecycle
+// hooks. The returned error includes the missing dependencies of every invoke
+// function and not just of the first failing one.
+//
+// Validate can be used in tests to catch wiring mistakes cheaply. The hive
+// itself is not modified and can be populated or run afterwards.
+func (h *Hive) Validate() error {
+	opts := h.opts
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.FrameworkLogger = opts.Logger
+	dry, err := newHive(opts, true, h.cells)
+	if err != nil {
+		return err
+	}
+	err = dry.container.Provide(
+		func() cell.AllSettings {
+			return cell.AllSettings{}
+		})
+	if err != nil {
+		return err
+	}
+
+	if err := dry.provideDeferred(); err != nil {
+		return err
+	}
+
+	var errs []error
+	invokes, err := sortInvokes(dry.invokes)
+	if err != nil {
+		return err
+	}
+	for _, invoke := range invokes {
+		if err := invoke(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return dry.withMissingHints(errors.Join(errs...))
+}
+
 func (h *Hive) AppendInvoke(invoke func() error) {
-	h.invokes = append(h.invokes, invoke)
+	h.invokes = append(h.invokes, orderedInvoke{fn: invoke})
+}
+
+// AppendInvokeAfter appends the invoke function of an invoke cell to be
+// invoked after the invoke functions of the other invoke cells.
+// Used by cell.Invoke and cell.InvokeAfter.
+func (h *Hive) AppendInvokeAfter(invoke func() error, handle cell.InvokeHandle, after []cell.InvokeHandle) {
+	h.invokes = append(h.invokes, orderedInvoke{invoke, handle, after})
+}
+
+// orderedInvoke is an invoke function and the invoke cell it is for, if any.
+type orderedInvoke struct {
+	fn     func() error
+	handle cell.InvokeHandle
+	after  []cell.InvokeHandle
+}
+
+// sortInvokes orders the invoke functions to be invoked after the
+// invoke functions of the cells they are ordered after. Otherwise the
+// order in which they were appended is kept.
+func sortInvokes(invokes []orderedInvoke) ([]func() error, error) {
+	indices := map[cell.InvokeHandle][]int{}
+	for i, inv := range invokes {
+		if inv.handle != nil {
+			indices[inv.handle] = append(indices[inv.handle], i)
+		}
+	}
+
+	// For each invoke function the number of invoke functions to invoke
+	// before it and the invoke functions waiting for it.
+	waitCount := make([]int, len(invokes))
+	waiting := make([][]int, len(invokes))
+	for i, inv := range invokes {
+		for _, dep := range inv.after {
+			deps, ok := indices[dep]
+			if !ok {
+				return nil, fmt.Errorf("invoke function %s is ordered after %s, which is not in the hive", inv.handle, dep)
+			}
+			for _, j := range deps {
+				waitCount[i]++
+				waiting[j] = append(waiting[j], i)
+			}
+		}
+	}
+
+	sorted := make([]func() error, 0, len(invokes))
+	done := make([]bool, len(invokes))
+	for len(sorted) < len(invokes) {
+		// Pick the first invoke function that is not waiting for any
+		// others in order to keep the original order when possible.
+		next := -1
+		for i := range invokes {
+			if !done[i] && waitCount[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var conflicting []string
+			for i, inv := range invokes {
+				if !done[i] {
+					conflicting = append(conflicting, inv.handle.String())
+				}
+			}
+			return nil, fmt.Errorf("conflicting ordering of invoke functions: %s", strings.Join(conflicting, "; "))
+		}
+		done[next] = true
+		sorted = append(sorted, invokes[next].fn)
+		for _, i := range waiting[next] {
+			waitCount[i]--
+		}
+	}
+	return sorted, nil
+}
+
+// AppendDeferredProvide appends a function for adding a constructor or a
+// decorator of a module with an enable flag or of an optional cell. The
+// functions are called when populating the hive before the config checks.
+// Used by cell.WithEnableFlag and cell.OptionalCell.
+func (h *Hive) AppendDeferredProvide(provide func() error) {
+	h.deferred = append(h.deferred, provide)
+}
+
+// provideDeferred calls the functions appended with AppendDeferredProvide
+// and records the constructors not provided as their cells are disabled.
+func (h *Hive) provideDeferred() error {
+	for _, provide := range h.deferred {
+		if err := provide(); err != nil {
+			var disabledErr *cell.DisabledProviderError
+			if errors.As(err, &disabledErr) {
+				h.disabled = append(h.disabled, disabledErr)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendConfigCheck appends a function for checking a config. The config
+// checks are run when populating the hive before the invoke functions.
+// Used by the config cells with configs that implement Validate().
+func (h *Hive) AppendConfigCheck(check func() error) {
+	h.configChecks = append(h.configChecks, check)
+}
+
+// AppendConfigReload appends a function for reloading a config. Used by
+// the cell.ReloadableConfig cells.
+func (h *Hive) AppendConfigReload(reload func(cell.AllSettings) (commit func(), err error)) {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+	h.configReloads = append(h.configReloads, reload)
+}
+
+// SkippedCell is an optional cell (see cell.OptionalCell) that was skipped
+// because it failed.
+type SkippedCell struct {
+	// Name is the name of the cell, e.g. "module foo" or the name and
+	// location of its constructor.
+	Name string
+
+	// Err is the failure the cell was skipped for.
+	Err error
+}
+
+// RecordSkippedCell records an optional cell that was skipped. Used by the
+// cell.OptionalCell cells.
+func (h *Hive) RecordSkippedCell(name string, err error) {
+	h.skippedMu.Lock()
+	defer h.skippedMu.Unlock()
+	h.skipped = append(h.skipped, SkippedCell{Name: name, Err: err})
+}
+
+// SkippedCells returns the optional cells (see cell.OptionalCell) that have
+// been skipped because they failed to be applied, invoked or started, in the
+// order they failed.
+func (h *Hive) SkippedCells() []SkippedCell {
+	h.skippedMu.Lock()
+	defer h.skippedMu.Unlock()
+	return slices.Clone(h.skipped)
+}
+
+// Reload re-reads the configuration settings from the sources of the hive's
+// viper instance, i.e. the config file set with e.g. SetConfigFile, if any,
+// and the environment variables, and applies them to the configs of the
+// cell.ReloadableConfig cells. If the config file cannot be read, a config
+// cannot be parsed or if the reload changes fields that are not tagged
+// reloadable, the reload fails and none of the configs are changed.
+//
+// To reload the configs on SIGHUP instead of stopping the hive:
+//
+//	h.Run(hive.WithReload(func() { h.Reload() }))
+func (h *Hive) Reload() error {
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	// Re-read the config file. The environment variables are read anew
+	// by viper when getting the settings.
+	if err := h.viper.ReadInConfig(); err != nil && !errors.As(err, &viper.ConfigFileNotFoundError{}) {
+		h.log.Error("Config reload failed", "error", err)
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	settings := cell.AllSettings(h.viper.AllSettings())
+	var (
+		commits []func()
+		errs    []error
+	)
+	for _, reload := range h.configReloads {
+		commit, err := reload(settings)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		commits = append(commits, commit)
+	}
+	if err := errors.Join(errs...); err != nil {
+		h.log.Error("Config reload failed", "error", err)
+		return fmt.Errorf("failed to reload config: %w", err)
+	}
+	for _, commit := range commits {
+		commit()
+	}
+	h.log.Info("Config reloaded")
+	return nil
 }
 
 // Start starts the hive. The context allows cancelling the start.
 // If context is cancelled and the start hooks do not respect the cancellation
 // then after 5 more seconds the process will be terminated forcefully.
-func (h *Hive) Start(ctx context.Context) error {
+func (h *Hive) Start(ctx context.Context) (err error) {
+	if h.opts.Tracer != nil {
+		var span cell.Span
+		ctx, span = h.opts.Tracer.Start(ctx, "hive start")
+		defer func() { span.End(err) }()
+		h.tracer.setParent(ctx)
+		defer h.tracer.setParent(nil)
+	}
+
 	if err := h.Populate(); err != nil {
 		return err
 	}
 
 	defer close(h.fatalOnTimeout(ctx))
 
+	h.clearShutdownReason()
 	h.log.Info("Starting")
-	start := time.Now()
-	err := h.lifecycle.Start(h.log, ctx)
+	start := h.opts.Clock.Now()
+	err = h.runPhase(ctx, PreStart)
 	if err == nil {
-		h.log.Info("Started", "duration", time.Since(start))
+		err = h.lifecycle.Start(h.log, ctx)
+		if err == nil {
+			err = h.runPhase(ctx, PostStart)
+		}
+	}
+	if err == nil {
+		h.started.Store(true)
+		h.startup.Start = h.opts.Clock.Since(start)
+		h.log.Info("Started",
+			"duration", h.startup.Start,
+			"build-duration", h.startup.Build,
+			"invoke-duration", h.startup.Invoke,
+			"total-duration", h.startup.Total())
+		if skipped := h.SkippedCells(); len(skipped) > 0 {
+			names := make([]string, len(skipped))
+			for i, s := range skipped {
+				names[i] = s.Name
+			}
+			h.log.Warn("Started with skipped optional cells", "skipped", names)
+		}
+		h.writeStartupReport()
 	} else {
-		h.log.Error("Start failed", "error", err, "duration", time.Since(start))
+		h.log.Error("Start failed", "error", err, "duration", h.opts.Clock.Since(start))
 	}
 	return err
 }
 
-// Stop stops the hive. The context allows cancelling the stop.
+// StartupDuration returns how long it took to construct, populate and start
+// the hive. The durations of the phases that have not completed are zero.
+func (h *Hive) StartupDuration() StartupDurations {
+	return h.startup
+}
+
+// Stop stops the hive. The context allows cancelling the stop. The stop
+// hooks can find out why the hive is stopped with ShutdownReasonOf.
 // If context is cancelled and the stop hooks do not respect the cancellation
 // then after 5 more seconds the process will be terminated forcefully.
-func (h *Hive) Stop(ctx context.Context) error {
+func (h *Hive) Stop(ctx context.Context) (err error) {
+	if h.opts.Tracer != nil {
+		var span cell.Span
+		ctx, span = h.opts.Tracer.Start(ctx, "hive stop")
+		defer func() { span.End(err) }()
+	}
+
 	defer close(h.fatalOnTimeout(ctx))
 	h.log.Info("Stopping")
-	return h.lifecycle.Stop(h.log, ctx)
+	h.started.Store(false)
+	h.rootCancel()
+	ctx = h.withShutdownReason(ctx)
+	preErr := h.runPhase(ctx, PreStop)
+	err = h.lifecycle.Stop(h.log, ctx)
+	return errors.Join(preErr, err, h.runPhase(ctx, PostStop))
 }
 
 func (h *Hive) fatalOnTimeout(ctx context.Context) chan struct{} {
@@ -368,7 +1131,8 @@ func (h *Hive) fatalOnTimeout(ctx context.Context) chan struct{} {
 		}
 
 		// Context was cancelled. Give 5 more seconds and then
-		// go fatal.
+		// go fatal. Measured with a real timer as a fake Clock may never
+		// advance, which would leave a hung start or stop hanging.
 		select {
 		case <-terminated:
 		case <-time.After(5 * time.Second):
@@ -386,40 +1150,156 @@ func (h *Hive) Shutdown(opts ...ShutdownOption) {
 		opt.apply(&o)
 	}
 
-	// If there already is an error in the channel, no-op
-	select {
-	case h.shutdown <- o.err:
-	default:
+	// If there already is an error in the channel, no-op. The reason is
+	// recorded before sending in order to have it set when the hive is
+	// stopped due to the shutdown.
+	h.shutdownMu.Lock()
+	defer h.shutdownMu.Unlock()
+	if len(h.shutdown) > 0 {
+		return
+	}
+	if o.err != nil {
+		h.setShutdownReasonLocked(ShutdownError, o.err)
+	} else {
+		h.setShutdownReasonLocked(ShutdownClean, nil)
 	}
+	h.shutdown <- o.err
 }
 
 func (h *Hive) PrintObjects() {
 	if err := h.Populate(); err != nil {
 		panic(fmt.Sprintf("Failed to populate object graph: %s", err))
 	}
+	h.writeObjects(os.Stdout)
+}
 
-	fmt.Printf("Cells:\n\n")
+// writeObjects writes the cells and the lifecycle hooks to w in textual
+// form. The hive must be populated.
+func (h *Hive) writeObjects(w io.Writer) {
+	fmt.Fprintf(w, "Cells:\n\n")
 	ip := cell.NewInfoPrinter()
-	for _, c := range h.cells {
-		c.Info(h.container).Print(2, ip)
-		fmt.Println()
+	ip.Writer = w
+	ip.ASCII = ip.ASCII || h.opts.ASCIIInfo
+	if h.opts.InfoHealth && h.started.Load() {
+		if statuses, err := h.Health(); err == nil {
+			ip.Health = map[string]cell.HealthStatus{}
+			flattenHealth(statuses, ip.Health)
+		}
+	}
+	for _, info := range h.infos() {
+		info.Print(2, ip)
+		fmt.Fprintln(w)
+	}
+	if lc, ok := h.lifecycle.(interface{ WriteHooks(io.Writer) }); ok {
+		lc.WriteHooks(w)
+	} else {
+		h.lifecycle.PrintHooks()
 	}
-	h.lifecycle.PrintHooks()
 }
 
+// infos returns the information about the hive's cells.
+func (h *Hive) infos() []cell.Info {
+	infos := make([]cell.Info, len(h.cells))
+	for i, c := range h.cells {
+		infos[i] = c.Info(h.container)
+	}
+	return infos
+}
+
+// PrintDotGraph writes the constructor dependency graph in the Graphviz dot
+// format to stdout. See WriteDotGraph.
 func (h *Hive) PrintDotGraph() {
+	if err := h.WriteDotGraph(os.Stdout); err != nil {
+		panic(fmt.Sprintf("Failed to write the dot graph: %s", err))
+	}
+}
+
+// PrintConfig writes the effective configuration of the hive to w. For each
+// field of each config cell it prints the value and whether it was set by a
+// flag, an environment variable, a configuration file or was left to the
+// default. The values of fields tagged with `sensitive:"true"` are redacted.
+func (h *Hive) PrintConfig(w io.Writer) {
 	if err := h.Populate(); err != nil {
 		panic(fmt.Sprintf("Failed to populate object graph: %s", err))
 	}
 
-	if err := dig.Visualize(h.container, os.Stdout); err != nil {
-		panic(fmt.Sprintf("Failed to dig.Visualize(): %s", err))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cfg := range h.configs() {
+		fmt.Fprintf(tw, "%T:\n", cfg.Value())
+		for _, f := range cfg.ConfigFields() {
+			value := fmt.Sprintf("%v", f.Value)
+			if f.Sensitive {
+				value = "<redacted>"
+			}
+			fmt.Fprintf(tw, "\t%s\t%s\t%s\t--%s\n", f.Name, value, h.configSource(f.Flag), f.Flag)
+		}
+	}
+	tw.Flush()
+}
+
+// ParseAndDumpConfig parses the arguments with the flags of the config cells,
+// populates the hive and returns the populated configuration structs in a
+// normalized textual form, e.g. for comparing against a golden file in a
+// test to catch accidental changes to the defaults. The structs are sorted by
+// type, the fields are in the order of declaration and the values of fields
+// tagged with `sensitive:"true"` are redacted:
+//
+//	hive_test.Config:
+//	  Foo = test (--foo)
+//	  Bar = 123 (--bar)
+//
+// Unlike PrintConfig the sources of the values are not included, as they
+// depend on the environment. Fails if the hive has already been populated.
+func (h *Hive) ParseAndDumpConfig(args []string) (string, error) {
+	if h.populated {
+		return "", errors.New("hive has already been populated, the arguments would not be used")
+	}
+	if err := h.flags.Parse(args); err != nil {
+		return "", fmt.Errorf("failed to parse the arguments: %w", err)
+	}
+	if err := h.Populate(); err != nil {
+		return "", err
+	}
+
+	configs := h.configs()
+	sort.SliceStable(configs, func(i, j int) bool {
+		return fmt.Sprintf("%T", configs[i].Value()) < fmt.Sprintf("%T", configs[j].Value())
+	})
+	var b strings.Builder
+	for _, cfg := range configs {
+		fmt.Fprintf(&b, "%T:\n", cfg.Value())
+		for _, f := range cfg.ConfigFields() {
+			value := fmt.Sprintf("%v", f.Value)
+			if f.Sensitive {
+				value = "<redacted>"
+			}
+			fmt.Fprintf(&b, "  %s = %s (--%s)\n", f.Name, value, f.Flag)
+		}
+	}
+	return b.String(), nil
+}
+
+// configSource returns where the value of the flag came from.
+func (h *Hive) configSource(flag string) string {
+	if f := h.flags.Lookup(flag); f != nil && f.Changed {
+		return "flag"
 	}
+	if _, ok := os.LookupEnv(h.getEnvName(flag)); ok {
+		return "env"
+	}
+	if h.viper.InConfig(flag) {
+		return "config"
+	}
+	return "default"
 }
 
 // getEnvName returns the environment variable to be used for the given option name.
 func (h *Hive) getEnvName(option string) string {
 	under := strings.Replace(option, "-", "_", -1)
 	upper := strings.ToUpper(under)
-	return h.opts.EnvPrefix + upper
+	prefix := h.opts.EnvPrefix
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return prefix + upper
 }
@@ -4,15 +4,31 @@
 package hive_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/dig"
 
 	"github.com/cilium/hive"
 	"github.com/cilium/hive/cell"
@@ -58,6 +74,72 @@ func TestHiveGoodConfig(t *testing.T) {
 	assert.Equal(t, 13, cfg.Bar, "Config.Bar not set correctly")
 }
 
+type ServerConfig struct {
+	Address string `mapstructure:"server-address"`
+
+	// The fields of the embedded struct are matched with the flags
+	// as if they were fields of ServerConfig.
+	TLSConfig `mapstructure:",squash"`
+}
+
+type TLSConfig struct {
+	CertFile string `mapstructure:"server-cert-file"`
+}
+
+func (def ServerConfig) Flags(flags *pflag.FlagSet) {
+	// By convention the flags are prefixed with the module ID.
+	flags.String("server-address", def.Address, "address to listen on")
+	flags.String("server-cert-file", def.CertFile, "path to the certificate")
+}
+
+func TestHiveMultipleConfigs(t *testing.T) {
+	var (
+		cfg       Config
+		serverCfg ServerConfig
+	)
+	h := hive.New(
+		cell.Config(Config{}),
+		cell.Module(
+			"server",
+			"Server",
+			cell.Config(ServerConfig{Address: ":8080"}),
+			cell.Invoke(func(c ServerConfig) { serverCfg = c }),
+		),
+		cell.Invoke(func(c Config) { cfg = c }),
+	)
+
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--foo=test", "--server-cert-file=/tmp/cert.pem"}))
+	require.NoError(t, h.Populate(), "Populate")
+
+	assert.Equal(t, Config{Foo: "test", Bar: 123}, cfg)
+	assert.Equal(t, ServerConfig{Address: ":8080", TLSConfig: TLSConfig{CertFile: "/tmp/cert.pem"}}, serverCfg)
+}
+
+type FooTimeoutConfig struct{ Timeout time.Duration }
+type BarTimeoutConfig struct{ Timeout time.Duration }
+
+func (FooTimeoutConfig) Flags(flags *pflag.FlagSet) {
+	flags.Duration("timeout", time.Second, "foo timeout")
+}
+
+func (BarTimeoutConfig) Flags(flags *pflag.FlagSet) {
+	flags.Duration("timeout", time.Second, "bar timeout")
+}
+
+func TestConflictingFlags(t *testing.T) {
+	var msg string
+	func() {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(
+			cell.Module("foo", "Foo", cell.Config(FooTimeoutConfig{})),
+			cell.Module("bar", "Bar", cell.Config(BarTimeoutConfig{})),
+		)
+	}()
+	assert.Contains(t, msg, `flag "timeout" is registered by both config hive_test.FooTimeoutConfig in module foo and config hive_test.BarTimeoutConfig in module bar`)
+}
+
 // BadConfig has a field that matches no flags, and Flags
 // declares a flag that matches no field.
 type BadConfig struct {
@@ -83,6 +165,192 @@ func TestHiveBadConfig(t *testing.T) {
 	assert.ErrorContains(t, err, "has unset fields: Bar", "expected 'unset fields' error")
 }
 
+type PortConfig struct {
+	Port int
+}
+
+func (PortConfig) Flags(flags *pflag.FlagSet) {
+	flags.Int("port", 0, "port")
+}
+
+func (c PortConfig) Validate() error {
+	if c.Port <= 0 {
+		return fmt.Errorf("Port must be positive, got %d", c.Port)
+	}
+	return nil
+}
+
+type WorkersConfig struct {
+	Workers int
+}
+
+func (WorkersConfig) Flags(flags *pflag.FlagSet) {
+	flags.Int("workers", 0, "workers")
+}
+
+func (c WorkersConfig) Validate() error {
+	if c.Workers <= 0 {
+		return fmt.Errorf("Workers must be positive, got %d", c.Workers)
+	}
+	return nil
+}
+
+func TestHiveConfigValidate(t *testing.T) {
+	invoked := false
+	newHive := func() *hive.Hive {
+		return hive.New(
+			cell.Config(Config{}),
+			cell.Config(PortConfig{}),
+			// Validated even though nothing depends on it.
+			cell.Config(WorkersConfig{}),
+			cell.Invoke(func(PortConfig) { invoked = true }),
+		)
+	}
+
+	h := newHive()
+	err := h.Populate()
+	assert.ErrorContains(t, err, "invalid config hive_test.PortConfig: Port must be positive, got 0")
+	assert.ErrorContains(t, err, "invalid config hive_test.WorkersConfig: Workers must be positive, got 0")
+	assert.False(t, invoked, "expected invoke function to not be called")
+
+	h = newHive()
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--port=8080", "--workers=4"}))
+	require.NoError(t, h.Populate(), "Populate")
+	assert.True(t, invoked, "expected invoke function to be called")
+}
+
+type DatabaseConfig struct {
+	Address  string
+	Password string `sensitive:"true"`
+	Timeout  time.Duration
+}
+
+func (DatabaseConfig) Flags(flags *pflag.FlagSet) {
+	flags.String("address", "localhost:5432", "database address")
+	flags.String("password", "", "database password")
+	flags.Duration("timeout", time.Second, "database timeout")
+}
+
+func TestPrintConfig(t *testing.T) {
+	h := hive.New(
+		cell.Config(Config{}),
+		cell.Module("db", "Database",
+			cell.WithFlagPrefix(),
+			cell.Config(DatabaseConfig{}),
+		),
+	)
+
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--foo=test", "--db-password=hunter2", "--db-timeout=5s"}))
+
+	var buf bytes.Buffer
+	h.PrintConfig(&buf)
+	out := buf.String()
+
+	assert.NotContains(t, out, "hunter2", "expected password to be redacted")
+	assert.Regexp(t, `(?m)^hive_test.Config:$`, out)
+	assert.Regexp(t, `(?m)^\s+Foo\s+test\s+flag\s+--foo$`, out)
+	assert.Regexp(t, `(?m)^\s+Bar\s+123\s+default\s+--bar$`, out)
+	assert.Regexp(t, `(?m)^hive_test.DatabaseConfig:$`, out)
+	assert.Regexp(t, `(?m)^\s+Address\s+localhost:5432\s+default\s+--db-address$`, out)
+	assert.Regexp(t, `(?m)^\s+Password\s+<redacted>\s+flag\s+--db-password$`, out)
+	assert.Regexp(t, `(?m)^\s+Timeout\s+5s\s+flag\s+--db-timeout$`, out)
+}
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+func TestParseAndDumpConfig(t *testing.T) {
+	newHive := func(server ServerConfig) *hive.Hive {
+		return hive.New(
+			cell.Config(Config{}),
+			cell.Config(server),
+			cell.Module("db", "Database",
+				cell.WithFlagPrefix(),
+				cell.Config(DatabaseConfig{}),
+			),
+		)
+	}
+	args := []string{"--foo=test", "--db-password=hunter2", "--db-timeout=5s"}
+
+	dump, err := newHive(ServerConfig{Address: ":8080"}).ParseAndDumpConfig(args)
+	require.NoError(t, err)
+	assert.NotContains(t, dump, "hunter2", "expected password to be redacted")
+
+	golden := "testdata/config_dump.txt"
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(golden, []byte(dump), 0644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), dump)
+
+	// Changing a default changes the dump.
+	dump, err = newHive(ServerConfig{Address: ":9090"}).ParseAndDumpConfig(args)
+	require.NoError(t, err)
+	assert.NotEqual(t, string(expected), dump)
+	assert.Contains(t, dump, "Address = :9090 (--server-address)")
+
+	_, err = newHive(ServerConfig{}).ParseAndDumpConfig([]string{"--no-such-flag"})
+	assert.ErrorContains(t, err, "failed to parse the arguments")
+}
+
+type EnvConfig struct {
+	FooTimeout time.Duration
+	Enabled    bool
+	Names      []string
+	Count      int
+}
+
+func (EnvConfig) Flags(flags *pflag.FlagSet) {
+	flags.Duration("foo-timeout", time.Second, "timeout")
+	flags.Bool("enabled", false, "enabled")
+	flags.StringSlice("names", nil, "names")
+	flags.Int("count", 1, "count")
+}
+
+func TestHiveEnvConfig(t *testing.T) {
+	t.Setenv("TEST_FOO_TIMEOUT", "5s")
+	t.Setenv("TEST_ENABLED", "true")
+	t.Setenv("TEST_NAMES", "foo,bar baz")
+	t.Setenv("TEST_COUNT", "2")
+
+	var cfg EnvConfig
+	opts := hive.DefaultOptions()
+	opts.EnvPrefix = "TEST"
+	h := hive.NewWithOptions(opts,
+		cell.Config(EnvConfig{}),
+		cell.Invoke(func(c EnvConfig) { cfg = c }),
+	)
+
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--count=3"}))
+	require.NoError(t, h.Populate(), "Populate")
+
+	assert.Equal(t, 5*time.Second, cfg.FooTimeout, "expected duration from env")
+	assert.True(t, cfg.Enabled, "expected bool from env")
+	assert.Equal(t, []string{"foo", "bar baz"}, cfg.Names, "expected string slice from env")
+	assert.Equal(t, 3, cfg.Count, "expected flag to take precedence over env")
+}
+
+func TestHiveEnvConfigDefaults(t *testing.T) {
+	// Variables without the prefix are ignored.
+	t.Setenv("FOO_TIMEOUT", "5s")
+
+	var cfg EnvConfig
+	opts := hive.DefaultOptions()
+	opts.EnvPrefix = "TEST_"
+	h := hive.NewWithOptions(opts,
+		cell.Config(EnvConfig{}),
+		cell.Invoke(func(c EnvConfig) { cfg = c }),
+	)
+	require.NoError(t, h.Populate(), "Populate")
+	assert.Equal(t, EnvConfig{FooTimeout: time.Second, Count: 1, Names: []string{}}, cfg)
+}
+
 type MapConfig struct {
 	Foo map[string]string
 }
@@ -216,122 +484,6 @@ func TestProvideInvoke(t *testing.T) {
 	assert.True(t, invoked, "expected invoke to be called, but it was not")
 }
 
-func TestModuleID(t *testing.T) {
-	invoked := false
-	inner := cell.Module(
-		"inner",
-		"inner module",
-		cell.Invoke(func(id cell.ModuleID, fid cell.FullModuleID) error {
-			invoked = true
-			if id != "inner" {
-				return fmt.Errorf("inner id mismatch, expected 'inner', got %q", id)
-			}
-			if fid.String() != "outer.inner" {
-				return fmt.Errorf("outer id mismatch, expected 'outer.inner', got %q", fid)
-			}
-			return nil
-		}),
-	)
-
-	outer := cell.Module(
-		"outer",
-		"outer module",
-		inner,
-	)
-
-	err := hive.New(
-		outer,
-		shutdownOnStartCell,
-	).Run()
-	assert.NoError(t, err, "expected Run to succeed")
-
-	assert.True(t, invoked, "expected invoke to be called, but it was not")
-}
-
-func TestGroup(t *testing.T) {
-	sum := 0
-
-	testCell := cell.Group(
-		cell.Provide(func() *SomeObject { return &SomeObject{10} }),
-		cell.Provide(func() *OtherObject { return &OtherObject{5} }),
-	)
-	err := hive.New(
-		testCell,
-		cell.Invoke(func(a *SomeObject, b *OtherObject) { sum = a.X + b.Y }),
-		shutdownOnStartCell,
-	).Run()
-	assert.NoError(t, err, "expected Run to succeed")
-	assert.Equal(t, 15, sum)
-}
-
-func TestProvidePrivate(t *testing.T) {
-	invoked := false
-
-	testCell := cell.Module(
-		"test",
-		"Test Module",
-		cell.ProvidePrivate(func() *SomeObject { return &SomeObject{10} }),
-		cell.Invoke(func(*SomeObject) { invoked = true }),
-	)
-
-	// Test happy path.
-	err := hive.New(
-		testCell,
-		shutdownOnStartCell,
-	).Run()
-	assert.NoError(t, err, "expected Start to succeed")
-
-	if !invoked {
-		t.Fatal("expected invoke to be called, but it was not")
-	}
-
-	// Now test that we can't access it from root scope.
-	h := hive.New(
-		testCell,
-		cell.Invoke(func(*SomeObject) {}),
-		shutdownOnStartCell,
-	)
-	err = h.Start(context.TODO())
-	assert.ErrorContains(t, err, "missing type: *hive_test.SomeObject", "expected Start to fail to find *SomeObject")
-}
-
-func TestDecorate(t *testing.T) {
-	invoked := false
-
-	testCell := cell.Decorate(
-		func(o *SomeObject) *SomeObject {
-			return &SomeObject{X: o.X + 1}
-		},
-		cell.Invoke(
-			func(o *SomeObject) error {
-				if o.X != 2 {
-					return errors.New("X != 2")
-				}
-				invoked = true
-				return nil
-			}),
-	)
-
-	hive := hive.New(
-		cell.Provide(func() *SomeObject { return &SomeObject{1} }),
-
-		// Here *SomeObject is not decorated.
-		cell.Invoke(func(o *SomeObject) error {
-			if o.X != 1 {
-				return errors.New("X != 1")
-			}
-			return nil
-		}),
-
-		testCell,
-
-		shutdownOnStartCell,
-	)
-
-	assert.NoError(t, hive.Run(), "expected Run() to succeed")
-	assert.True(t, invoked, "expected decorated invoke function to be called")
-}
-
 func TestShutdown(t *testing.T) {
 	//
 	// Happy paths without a shutdown error:
@@ -386,6 +538,23 @@ func TestShutdown(t *testing.T) {
 		}),
 	)
 	assert.ErrorIs(t, h.Run(), shutdownErr, "expected Run() to fail with shutdownErr")
+
+	// Test that the error is propagated from a goroutine and logged.
+	rec := &logRecorder{}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(rec)
+	h = hive.NewWithOptions(
+		opts,
+		cell.Invoke(func(lc cell.Lifecycle, shutdowner hive.Shutdowner) {
+			lc.Append(cell.Hook{
+				OnStart: func(cell.HookContext) error {
+					go shutdowner.Shutdown(hive.ShutdownWithError(shutdownErr))
+					return nil
+				}})
+		}),
+	)
+	assert.ErrorIs(t, h.Run(), shutdownErr, "expected Run() to fail with shutdownErr")
+	assert.Equal(t, []string{shutdownErr.Error()}, rec.find(slog.LevelError, "Shutdown requested with an error", "error"))
 }
 
 func TestRunRollback(t *testing.T) {
@@ -429,6 +598,33 @@ func TestRunRollback(t *testing.T) {
 	assert.Equal(t, 1, stopped)
 }
 
+func TestRunRollbackFailure(t *testing.T) {
+	startErr := errors.New("start failed")
+	stopErr := errors.New("stop failed")
+	h := hive.New(
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.Hook{
+				OnStart: func(cell.HookContext) error { return nil },
+				OnStop:  func(cell.HookContext) error { return stopErr },
+			})
+			lc.Append(cell.Hook{
+				OnStart: func(cell.HookContext) error { return startErr },
+			})
+		}),
+	)
+
+	err := h.RunContext(context.Background())
+	assert.ErrorIs(t, err, startErr)
+	assert.ErrorIs(t, err, stopErr)
+
+	lines := strings.Split(err.Error(), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "failed to start (primary cause): start hook"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[0], "start failed"), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "failed to roll back the start: stop hook"), lines[1])
+	assert.True(t, strings.HasSuffix(lines[1], "stop failed"), lines[1])
+}
+
 var shutdownOnStartCell = cell.Invoke(func(lc cell.Lifecycle, shutdowner hive.Shutdowner) {
 	lc.Append(cell.Hook{
 		OnStart: func(cell.HookContext) error {
@@ -464,34 +660,1994 @@ func TestSameCellMultipleHives(t *testing.T) {
 	require.NoError(t, h1.Stop(context.TODO()))
 }
 
-func TestModulePrivateProvidersDecorators(t *testing.T) {
-	var intCalled bool
-	opts := hive.DefaultOptions()
-	opts.ModuleDecorators = cell.ModuleDecorators{
-		func(n int) int { intCalled = true; return n + 1 },
-		func(base string, mod cell.ModuleID) string { return base + string(mod) },
+// logRecorder is a slog.Handler that records the log records.
+type logRecorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (r *logRecorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (r *logRecorder) Handle(_ context.Context, rec slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+	return nil
+}
+
+func (r *logRecorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+func (r *logRecorder) WithGroup(string) slog.Handler      { return r }
+
+// find returns the string value of the given attribute for all records
+// with the given level and message.
+func (r *logRecorder) find(level slog.Level, msg string, attr string) (values []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.records {
+		if rec.Level != level || rec.Message != msg {
+			continue
+		}
+		rec.Attrs(func(a slog.Attr) bool {
+			if a.Key == attr {
+				values = append(values, a.Value.String())
+			}
+			return true
+		})
 	}
-	opts.ModulePrivateProviders = cell.ModulePrivateProviders{
-		func() float64 { return 3.1415 },
+	return
+}
+
+type groupOut struct {
+	cell.Out
+
+	Object *SomeObject `group:"objects"`
+}
+
+func newUsed() *SomeObject     { return &SomeObject{} }
+func newUnused() *OtherObject  { return &OtherObject{} }
+func newGroupMember() groupOut { return groupOut{} }
+
+func TestUnusedProviders(t *testing.T) {
+	h := hive.New(
+		cell.Provide(
+			newUsed,
+			newUnused,
+			newGroupMember,
+		),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	unused, err := h.UnusedProviders()
+	require.NoError(t, err)
+	if assert.Len(t, unused, 1) {
+		assert.Contains(t, unused[0], "hive_test.newUnused ")
 	}
 
-	var stringContents string
-	var floatContents float64
-	h := hive.NewWithOptions(opts,
+	// The inputs of decorators and replacement constructors are used, and
+	// the eager constructors are not reported.
+	type (
+		decoratorInput   struct{}
+		replacementInput struct{}
+		eagerObject      struct{}
+	)
+	h = hive.New(
 		cell.Provide(
-			// Things to decorate
-			func() int { return 1 },
-			func() string { return "hello, " },
-		),
-		cell.Module("test", "test",
-			cell.Invoke(func(s string, f float64) {
-				stringContents = s
-				floatContents = f
-			}),
-		))
+			func() *SomeObject { return &SomeObject{} },
+			func() *decoratorInput { return &decoratorInput{} },
+			func() *replacementInput { return &replacementInput{} },
+		),
+		cell.Decorate(
+			func(o *SomeObject, _ *decoratorInput) *SomeObject { return o },
+			cell.Invoke(func(*SomeObject) {}),
+		),
+		cell.ReplaceProvide(func(*replacementInput) *OtherObject { return &OtherObject{} }),
+		cell.Provide(func() *OtherObject { return &OtherObject{} }),
+		cell.Invoke(func(*OtherObject) {}),
+		cell.ProvideEager(func() *eagerObject { return &eagerObject{} }),
+	)
+	unused, err = h.UnusedProviders()
+	require.NoError(t, err)
+	assert.Empty(t, unused)
+}
 
-	require.NoError(t, h.Populate())
-	require.Equal(t, "hello, test", stringContents)
-	require.Equal(t, 3.1415, floatContents)
-	require.False(t, intCalled, "did not expect unreferenced module decorator to be called")
+type ThirdObject struct{}
+
+func newSome() *SomeObject                            { return &SomeObject{} }
+func newOther(*SomeObject) *OtherObject               { return &OtherObject{} }
+func newThird(*SomeObject, *OtherObject) *ThirdObject { return &ThirdObject{} }
+
+func TestGraphMetrics(t *testing.T) {
+	h := hive.New(
+		cell.Provide(newSome, newOther),
+		cell.Module("test", "Test Module",
+			cell.ProvidePrivate(newThird),
+			cell.Invoke(func(*ThirdObject) {}),
+		),
+	)
+	metrics := h.GraphMetrics()
+
+	assert.Equal(t, 2, metrics.FanIn["*hive_test.SomeObject"])
+	assert.Equal(t, 1, metrics.FanIn["*hive_test.OtherObject"])
+	assert.Zero(t, metrics.FanIn["*hive_test.ThirdObject"], "expected invoke functions to not be counted")
+
+	fanOut := map[string]int{}
+	for name, n := range metrics.FanOut {
+		name, _, _ = strings.Cut(name, " ")
+		fanOut[name] = n
+	}
+	assert.Equal(t, 0, fanOut["hive_test.newSome"])
+	assert.Equal(t, 1, fanOut["hive_test.newOther"])
+	assert.Equal(t, 2, fanOut["hive_test.newThird"])
+
+	var chain []string
+	for _, name := range metrics.LongestChain {
+		name, _, _ = strings.Cut(name, " ")
+		chain = append(chain, name)
+	}
+	assert.Equal(t, []string{"hive_test.newThird", "hive_test.newOther", "hive_test.newSome"}, chain)
+}
+
+func TestWriteDotGraph(t *testing.T) {
+	h := hive.New(
+		cell.Provide(newSome, newOther),
+		cell.Module("test", "Test Module",
+			cell.ProvidePrivate(newThird),
+			cell.Invoke(func(*ThirdObject) {}),
+		),
+	)
+	var buf bytes.Buffer
+	require.NoError(t, h.WriteDotGraph(&buf))
+	dot := buf.String()
+
+	nodes := map[string]string{}
+	for _, line := range strings.Split(dot, "\n") {
+		if id, label, ok := strings.Cut(strings.TrimSpace(line), " [label=\""); ok {
+			if name, _, ok := strings.Cut(label, " "); ok {
+				nodes[name] = id
+			}
+		}
+	}
+	some, other, third := nodes["hive_test.newSome"], nodes["hive_test.newOther"], nodes["hive_test.newThird"]
+	require.NotEmpty(t, some)
+	require.NotEmpty(t, other)
+	require.NotEmpty(t, third)
+
+	assert.Contains(t, dot, other+" -> "+some+` [label="*hive_test.SomeObject"];`)
+	assert.Contains(t, dot, third+" -> "+some+` [label="*hive_test.SomeObject"];`)
+	assert.Contains(t, dot, third+" -> "+other+` [label="*hive_test.OtherObject"];`)
+	assert.Equal(t, 3, strings.Count(dot, "->"))
+	assert.Regexp(t, third+` \[label=".*", style=dashed\];`, dot)
+	assert.NotRegexp(t, some+` \[label=".*", style=dashed\];`, dot)
+
+	// A private constructor in another module is not drawn as providing the
+	// inputs.
+	h = hive.New(
+		cell.Module("mod-a", "Module A",
+			cell.ProvidePrivate(newSome),
+		),
+		cell.Module("mod-b", "Module B",
+			cell.ProvidePrivate(func() *SomeObject { return &SomeObject{} }),
+			cell.Provide(newOther),
+		),
+	)
+	buf.Reset()
+	require.NoError(t, h.WriteDotGraph(&buf))
+	dot = buf.String()
+	assert.Equal(t, 1, strings.Count(dot, "->"))
+	assert.Contains(t, dot, `ctor2 -> ctor1 [label="*hive_test.SomeObject"];`)
+}
+
+func TestProvidersOf(t *testing.T) {
+	names := func(infos []*cell.ProviderInfo) (out []string) {
+		for _, info := range infos {
+			name, _, _ := strings.Cut(info.Name, " ")
+			out = append(out, name)
+		}
+		return
+	}
+
+	h := hive.New(
+		cell.Provide(newSome, newOther),
+		cell.Module("test", "Test Module",
+			cell.ProvidePrivate(newThird),
+		),
+		cell.Provide(func() int { return 1 }),
+	)
+
+	// Direct match without dependencies.
+	infos, err := h.ProvidersOf("*hive_test.SomeObject")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hive_test.newSome"}, names(infos))
+
+	// Transitive dependencies: newThird needs newSome and newOther, and
+	// newOther needs newSome.
+	infos, err = h.ProvidersOf("*hive_test.ThirdObject")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hive_test.newOther", "hive_test.newSome", "hive_test.newThird"}, names(infos))
+
+	infos, err = h.ProvidersOf("*hive_test.NoSuchObject")
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	// A private constructor in another module does not provide the inputs.
+	h = hive.New(
+		cell.Module("mod-a", "Module A",
+			cell.ProvidePrivate(newSome),
+		),
+		cell.Module("mod-b", "Module B",
+			cell.ProvidePrivate(func() *SomeObject { return &SomeObject{} }),
+			cell.Provide(newOther),
+		),
+	)
+	infos, err = h.ProvidersOf("*hive_test.OtherObject")
+	require.NoError(t, err)
+	if assert.Len(t, infos, 2) {
+		assert.Contains(t, infos[0].Name, "hive_test.TestProvidersOf.func")
+		assert.Contains(t, infos[1].Name, "hive_test.newOther ")
+	}
+}
+
+type fakeConstructorMetrics struct {
+	mu        sync.Mutex
+	durations map[string][]time.Duration
+}
+
+func (m *fakeConstructorMetrics) ObserveConstructor(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, _, _ = strings.Cut(name, " ")
+	m.durations[name] = append(m.durations[name], d)
+}
+
+func TestValidate(t *testing.T) {
+	constructed, invoked := false, false
+	h := hive.New(
+		cell.Config(Config{}),
+		cell.Provide(func() *SomeObject { constructed = true; return &SomeObject{} }),
+		cell.Invoke(func(*SomeObject, Config) { invoked = true }),
+	)
+	assert.NoError(t, h.Validate(), "Validate")
+	assert.False(t, constructed, "expected constructor not to be called")
+	assert.False(t, invoked, "expected invoke function not to be called")
+
+	// The hive can still be populated after validation.
+	require.NoError(t, h.Populate(), "Populate")
+	assert.True(t, invoked)
+
+	h = hive.New(
+		cell.Provide(func(*OtherObject) *SomeObject { return &SomeObject{} }),
+		cell.Invoke(func(*SomeObject) {}),
+		cell.Module("test", "Test Module",
+			cell.Invoke(func(*ThirdObject) {}),
+		),
+	)
+	err := h.Validate()
+	require.Error(t, err, "expected Validate to fail")
+	assert.Contains(t, err.Error(), "*hive_test.OtherObject")
+	assert.Contains(t, err.Error(), "*hive_test.ThirdObject")
+
+	// The constructors and invoke functions of a sub-hive are not called
+	// either, but its missing dependencies are found.
+	constructed = false
+	h = hive.New(
+		cell.SubHive(nil, []any{new(*SomeObject)},
+			cell.Provide(func() *SomeObject { constructed = true; return &SomeObject{} }),
+			cell.Invoke(func(*SomeObject) { invoked = true }),
+		),
+		cell.Invoke(func(*SomeObject) { invoked = true }),
+	)
+	invoked = false
+	assert.NoError(t, h.Validate(), "Validate")
+	assert.False(t, constructed, "expected sub-hive constructor not to be called")
+	assert.False(t, invoked, "expected invoke function not to be called")
+
+	h = hive.New(
+		cell.SubHive(nil, nil, cell.Invoke(func(*OtherObject) {})),
+	)
+	err = h.Validate()
+	require.Error(t, err, "expected Validate to fail")
+	assert.Contains(t, err.Error(), "*hive_test.OtherObject")
+}
+
+type stopBase struct{}
+type stopA struct{}
+type stopB struct{}
+
+func TestParallelStop(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(ev string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}
+
+	// The stop hooks of A and B wait for each other and thus only complete
+	// if run in parallel.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	bothStopping := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(bothStopping)
+	}()
+	errA, errB := errors.New("A failed"), errors.New("B failed")
+	stopHook := func(name string, err error) cell.Hook {
+		return cell.Hook{
+			OnStop: func(cell.HookContext) error {
+				wg.Done()
+				select {
+				case <-bothStopping:
+				case <-time.After(5 * time.Second):
+					t.Error("stop hooks were not run in parallel")
+				}
+				record("stop " + name)
+				return err
+			},
+		}
+	}
+
+	opts := hive.DefaultOptions()
+	opts.ParallelStop = 2
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(
+			func(lc cell.Lifecycle) *stopBase {
+				lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+					record("stop base")
+					return nil
+				}})
+				return &stopBase{}
+			},
+			func(lc cell.Lifecycle, _ *stopBase) *stopA {
+				lc.Append(stopHook("A", errA))
+				return &stopA{}
+			},
+		),
+		cell.Module("test", "Test Module",
+			cell.Provide(func(lc cell.Lifecycle, _ *stopBase) *stopB {
+				lc.Append(stopHook("B", errB))
+				return &stopB{}
+			}),
+		),
+		cell.Invoke(func(*stopA, *stopB) {}),
+	)
+
+	require.NoError(t, h.Start(context.TODO()), "Start")
+	err := h.Stop(context.TODO())
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+
+	require.Len(t, events, 3)
+	assert.ElementsMatch(t, []string{"stop A", "stop B"}, events[:2])
+	assert.Equal(t, "stop base", events[2], "expected dependency to be stopped last")
+}
+
+func TestParallelStart(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(ev string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}
+
+	// The start hooks of A and B wait for each other and thus only complete
+	// if run in parallel.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	bothStarting := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(bothStarting)
+	}()
+	errA := errors.New("A failed")
+	hook := func(name string, err error) cell.Hook {
+		return cell.Hook{
+			OnStart: func(cell.HookContext) error {
+				wg.Done()
+				select {
+				case <-bothStarting:
+				case <-time.After(5 * time.Second):
+					t.Error("start hooks were not run in parallel")
+				}
+				record("start " + name)
+				return err
+			},
+			OnStop: func(cell.HookContext) error {
+				record("stop " + name)
+				return nil
+			},
+		}
+	}
+
+	opts := hive.DefaultOptions()
+	opts.ParallelStart = 2
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(
+			func(lc cell.Lifecycle) *stopBase {
+				lc.Append(cell.Hook{
+					OnStart: func(cell.HookContext) error {
+						record("start base")
+						return nil
+					},
+					OnStop: func(cell.HookContext) error {
+						record("stop base")
+						return nil
+					},
+				})
+				return &stopBase{}
+			},
+			func(lc cell.Lifecycle, _ *stopBase) *stopA {
+				lc.Append(hook("A", errA))
+				return &stopA{}
+			},
+			func(lc cell.Lifecycle, _ *stopBase) *stopB {
+				lc.Append(hook("B", nil))
+				return &stopB{}
+			},
+		),
+		cell.Invoke(func(*stopA, *stopB) {}),
+	)
+
+	err := h.Start(context.TODO())
+	assert.ErrorIs(t, err, errA)
+	require.NoError(t, h.Stop(context.TODO()), "Stop")
+
+	require.Len(t, events, 5)
+	assert.Equal(t, "start base", events[0], "expected dependency to be started first")
+	assert.ElementsMatch(t, []string{"start A", "start B"}, events[1:3])
+	assert.Equal(t, []string{"stop B", "stop base"}, events[3:], "expected only the started hooks to be stopped")
+}
+
+func TestParallelStartSameConstructor(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(ev string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}
+
+	// The hooks appended by the same constructor are started in order even
+	// though they have the same level as the hook of the other constructor.
+	opts := hive.DefaultOptions()
+	opts.ParallelStart = 4
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(
+			func(lc cell.Lifecycle) *stopA {
+				lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+					time.Sleep(50 * time.Millisecond)
+					record("open DB")
+					return nil
+				}})
+				lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+					record("use DB")
+					return nil
+				}})
+				return &stopA{}
+			},
+			func(lc cell.Lifecycle) *stopB {
+				lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+					record("start B")
+					return nil
+				}})
+				return &stopB{}
+			},
+		),
+		cell.Invoke(func(*stopA, *stopB) {}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Less(t, slices.Index(events, "open DB"), slices.Index(events, "use DB"), "events: %v", events)
+}
+
+func TestParallelStopSameConstructor(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(ev string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	}
+
+	// The hooks appended by the same constructor are stopped in reverse order
+	// even though they have the same level as the hook of the other
+	// constructor.
+	opts := hive.DefaultOptions()
+	opts.ParallelStop = 4
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(
+			func(lc cell.Lifecycle) *stopA {
+				lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+					record("close DB")
+					return nil
+				}})
+				lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+					time.Sleep(50 * time.Millisecond)
+					record("stop using DB")
+					return nil
+				}})
+				return &stopA{}
+			},
+			func(lc cell.Lifecycle) *stopB {
+				lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+					record("stop B")
+					return nil
+				}})
+				return &stopB{}
+			},
+		),
+		cell.Invoke(func(*stopA, *stopB) {}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Less(t, slices.Index(events, "stop using DB"), slices.Index(events, "close DB"), "events: %v", events)
+}
+
+func TestRunContext(t *testing.T) {
+	started, stopped := make(chan struct{}), false
+	h := hive.New(
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.Hook{
+				OnStart: func(cell.HookContext) error {
+					close(started)
+					return nil
+				},
+				OnStop: func(cell.HookContext) error {
+					stopped = true
+					return nil
+				},
+			})
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- h.RunContext(ctx) }()
+
+	<-started
+	assert.False(t, stopped, "expected stop hook not to have run before cancel")
+	cancel()
+
+	select {
+	case err := <-errs:
+		assert.NoError(t, err, "expected RunContext to succeed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return after the context was cancelled")
+	}
+	assert.True(t, stopped, "expected stop hook to have run")
+}
+
+func TestReady(t *testing.T) {
+	var component cell.Health
+	newHive := func(requireReports bool) *hive.Hive {
+		opts := hive.DefaultOptions()
+		opts.RequireHealthReports = requireReports
+		return hive.NewWithOptions(opts,
+			cell.SimpleHealthCell,
+			cell.Module("test", "Test",
+				cell.Invoke(func(h cell.Health) { component = h.NewScope("component") }),
+			),
+		)
+	}
+
+	// Without RequireHealthReports the unreported component is excluded.
+	h := newHive(false)
+	require.NoError(t, h.Start(context.TODO()), "Start")
+	assert.True(t, h.Ready(), "expected ready with unreported component excluded")
+	require.NoError(t, h.Stop(context.TODO()), "Stop")
+	assert.False(t, h.Ready(), "expected not ready after stop")
+
+	h = newHive(true)
+	assert.False(t, h.Ready(), "expected not ready before start")
+	require.NoError(t, h.Start(context.TODO()), "Start")
+	assert.False(t, h.Ready(), "expected not ready before component reports")
+
+	ready := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ready <- h.WaitReady(ctx)
+	}()
+	component.OK("Ready")
+	require.NoError(t, <-ready, "WaitReady")
+	assert.True(t, h.Ready(), "expected ready after component reports OK")
+
+	component.Degraded("Connection lost", errors.New("timeout"))
+	assert.False(t, h.Ready(), "expected not ready after component degrades")
+
+	component.OK("Reconnected")
+	assert.True(t, h.Ready(), "expected ready after component recovers")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	component.Degraded("Connection lost", errors.New("timeout"))
+	assert.ErrorIs(t, h.WaitReady(ctx), context.Canceled)
+
+	require.NoError(t, h.Stop(context.TODO()), "Stop")
+}
+
+func TestIntrospectionHandler(t *testing.T) {
+	opts := hive.DefaultOptions()
+	opts.ModuleDecorators = cell.ModuleDecorators{cell.HealthModuleDecorator}
+	h := hive.NewWithOptions(opts,
+		cell.SimpleHealthCell,
+		cell.Module("db", "Database",
+			cell.WithFlagPrefix(),
+			cell.Config(DatabaseConfig{}),
+			cell.Invoke(func(lc cell.Lifecycle, h cell.Health, _ DatabaseConfig) {
+				lc.Append(cell.HookWithName("db-connect", cell.Hook{
+					OnStart: func(cell.HookContext) error {
+						h.OK("Connected")
+						return nil
+					},
+				}))
+			}),
+		),
+	)
+	flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	h.RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--db-password=hunter2"}))
+	require.NoError(t, h.Start(context.TODO()), "Start")
+	defer h.Stop(context.TODO())
+
+	srv := httptest.NewServer(http.StripPrefix("/hive", h.IntrospectionHandler()))
+	defer srv.Close()
+
+	get := func(path string, v any) string {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err, "GET %s", path)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, "GET %s", path)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, v), "GET %s: %s", path, body)
+		return string(body)
+	}
+
+	var objects []map[string]any
+	body := get("/hive/objects", &objects)
+	require.Len(t, objects, 2)
+	assert.Contains(t, body, `"module":{"id":"db","description":"Database"}`)
+	assert.Contains(t, body, `"type":"hive_test.DatabaseConfig"`)
+	assert.Contains(t, body, `"Password":"\u003credacted\u003e"`)
+	assert.NotContains(t, body, "hunter2")
+
+	var health []map[string]any
+	get("/hive/health", &health)
+	require.Len(t, health, 1)
+	assert.Equal(t, "db", health[0]["scope"])
+	assert.Equal(t, "OK", health[0]["level"])
+	assert.Equal(t, "Connected", health[0]["message"])
+
+	var timings []hive.HookTiming
+	get("/hive/timing", &timings)
+	require.Len(t, timings, 1)
+	assert.Equal(t, "db-connect (db)", timings[0].Name)
+	assert.Equal(t, "start", timings[0].Phase)
+	assert.Empty(t, timings[0].Error)
+}
+
+type CycleA struct{}
+type CycleB struct{}
+
+func newCycleA(*CycleB) *CycleA { return &CycleA{} }
+func newCycleB(*CycleA) *CycleB { return &CycleB{} }
+
+func TestCycleError(t *testing.T) {
+	var msg string
+	func() {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(
+			cell.Provide(newCycleA),
+			cell.Module("cycle", "Cycle", cell.Provide(newCycleB)),
+		)
+	}()
+	assert.Regexp(t,
+		`^Failed to apply cell: dependency cycle detected: `+
+			`\*hive_test.CycleA \(hive_test.newCycleA at [^()]*hive_test.go:\d+\) -> `+
+			`\*hive_test.CycleB \(hive_test.newCycleB at [^()]*hive_test.go:\d+\) -> `+
+			`\*hive_test.CycleA$`,
+		msg)
+
+	// A cycle without types does not panic.
+	assert.Equal(t, "dependency cycle detected", (&hive.CycleError{}).Error())
+	assert.Equal(t, "dependency cycle detected: *hive_test.CycleA",
+		(&hive.CycleError{Types: []string{"*hive_test.CycleA"}, Constructors: []string{"newCycleA"}}).Error())
+}
+
+func TestDeferCycleCheck(t *testing.T) {
+	opts := hive.DefaultOptions()
+	opts.DeferCycleCheck = true
+	_, err := hive.NewWithError(opts,
+		cell.Provide(newCycleA, newCycleB),
+		cell.Invoke(func(*CycleA) {}),
+	)
+	var cycleErr *hive.CycleError
+	require.ErrorAs(t, err, &cycleErr, "expected the deferred check to detect the cycle")
+	assert.Equal(t, []string{"*hive_test.CycleA", "*hive_test.CycleB", "*hive_test.CycleA"}, cycleErr.Types)
+}
+
+// BenchmarkDeferCycleCheck compares the construction of a hive with a long
+// chain of constructors with and without deferring the check for cycles.
+func BenchmarkDeferCycleCheck(b *testing.B) {
+	// The constructors return distinct array types, each depending on the
+	// previous one.
+	var ctors []any
+	for i := 0; i < 300; i++ {
+		var in []reflect.Type
+		if i > 0 {
+			in = append(in, reflect.ArrayOf(i-1, reflect.TypeOf(byte(0))))
+		}
+		out := reflect.ArrayOf(i, reflect.TypeOf(byte(0)))
+		fn := reflect.MakeFunc(reflect.FuncOf(in, []reflect.Type{out}, false),
+			func([]reflect.Value) []reflect.Value { return []reflect.Value{reflect.Zero(out)} })
+		ctors = append(ctors, fn.Interface())
+	}
+
+	for _, deferred := range []bool{false, true} {
+		b.Run(fmt.Sprintf("deferred=%v", deferred), func(b *testing.B) {
+			opts := hive.DefaultOptions()
+			opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+			opts.DeferCycleCheck = deferred
+			for i := 0; i < b.N; i++ {
+				hive.NewWithOptions(opts, cell.Provide(ctors...))
+			}
+		})
+	}
+}
+
+func newDuplicateA() *SomeObject  { return &SomeObject{X: 1} }
+func newDuplicateA2() *SomeObject { return &SomeObject{X: 2} }
+
+func TestDuplicateProviderError(t *testing.T) {
+	var msg string
+	func() {
+		defer func() { msg = fmt.Sprint(recover()) }()
+		hive.New(
+			cell.Provide(newDuplicateA),
+			cell.Module("dup", "Duplicate", cell.Provide(newDuplicateA2)),
+		)
+	}()
+	assert.Regexp(t,
+		`^Failed to apply cell: \*hive_test.SomeObject is provided by both `+
+			`hive_test.newDuplicateA at [^()]*hive_test.go:\d+ and `+
+			`hive_test.newDuplicateA2 at [^()]*hive_test.go:\d+`,
+		msg)
+	assert.Contains(t, msg, "Hint: use cell.ProvideNamed")
+
+	// Private constructors in unrelated modules do not collide.
+	h := hive.New(
+		cell.Module("dup-a", "Duplicate A", cell.ProvidePrivate(newDuplicateA)),
+		cell.Module("dup-b", "Duplicate B", cell.ProvidePrivate(newDuplicateA2)),
+	)
+	assert.NoError(t, h.Populate())
+}
+
+type HintObject struct{}
+
+func TestMissingDependencyHints(t *testing.T) {
+	// Pointer mismatch: HintObject is provided, but *HintObject is needed.
+	h := hive.New(
+		cell.Provide(func() HintObject { return HintObject{} }),
+		cell.Invoke(func(*HintObject) {}),
+	)
+	err := h.Populate()
+	require.Error(t, err)
+	assert.Regexp(t,
+		`Hints for missing type \*hive_test.HintObject:\n`+
+			`  - did you mean hive_test.HintObject provided by hive_test.TestMissingDependencyHints.func1 at [^()]*hive_test.go:\d+\?`,
+		err.Error())
+
+	// Wrong scope: *HintObject is provided privately in a sibling module.
+	h = hive.New(
+		cell.Module("provider", "Provider",
+			cell.ProvidePrivate(func() *HintObject { return &HintObject{} }),
+		),
+		cell.Module("consumer", "Consumer",
+			cell.Invoke(func(*HintObject) {}),
+		),
+	)
+	err = h.Populate()
+	require.Error(t, err)
+	assert.Regexp(t,
+		`Hints for missing type \*hive_test.HintObject:\n`+
+			`  - \*hive_test.HintObject is provided privately \(🔒️\) by hive_test.TestMissingDependencyHints.func3 at [^()]*hive_test.go:\d+ `+
+			`in module "provider" and is not visible to module "consumer"\. `+
+			`Move the dependent into module "provider" or a module nested in it, `+
+			`or use cell\.Provide instead of cell\.ProvidePrivate to export it`,
+		err.Error())
+
+	// Validate gives the same hints.
+	assert.ErrorContains(t, h.Validate(), `in module "provider" and is not visible to module "consumer"`)
+
+	// Wrong scope: *HintObject is provided privately in a nested module.
+	h = hive.New(
+		cell.Module("outer", "Outer",
+			cell.Module("provider", "Provider",
+				cell.ProvidePrivate(func() *HintObject { return &HintObject{} }),
+			),
+		),
+		cell.Invoke(func(*HintObject) {}),
+	)
+	err = h.Populate()
+	var missingErr *hive.MissingDependencyError
+	require.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, []string{"*hive_test.HintObject"}, missingErr.Types)
+	assert.Contains(t, err.Error(), `in module "outer.provider" and is not visible to the top level of the hive. `+
+		`Move the dependent into module "outer.provider"`)
+}
+
+func TestCommand(t *testing.T) {
+	newHive := func() *hive.Hive {
+		return hive.New(
+			cell.Config(Config{}),
+			cell.Module("test", "Test Module",
+				cell.Provide(func() *SomeObject { return &SomeObject{1} }),
+				cell.Invoke(func(*SomeObject) {}),
+			),
+		)
+	}
+	run := func(args ...string) (string, error) {
+		cmd := newHive().Command()
+		var buf bytes.Buffer
+		cmd.SetOut(&buf)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return buf.String(), err
+	}
+
+	out, err := run("objects")
+	require.NoError(t, err, "objects")
+	assert.Contains(t, out, "Cells:")
+	assert.Contains(t, out, "Ⓜ️ test (Test Module)")
+	assert.Contains(t, out, "Start hooks:")
+
+	out, err = run("objects", "--output=json")
+	require.NoError(t, err, "objects --output=json")
+	var objects []map[string]any
+	require.NoError(t, json.Unmarshal([]byte(out), &objects), "expected JSON output")
+	assert.Len(t, objects, 2)
+	assert.Contains(t, out, `"id": "test"`)
+
+	for _, args := range [][]string{{"objects", "-o", "dot"}, {"dot"}, {"dot-graph"}} {
+		out, err = run(args...)
+		require.NoError(t, err, "%v", args)
+		assert.True(t, strings.HasPrefix(out, "digraph {"), "expected dot output for %v, got %q", args, out)
+	}
+
+	out, err = run("config", "--foo=bar")
+	require.NoError(t, err, "config")
+	assert.Regexp(t, `(?m)^\s+Foo\s+bar\s+flag\s+--foo$`, out)
+
+	_, err = run("objects", "--output=yaml")
+	assert.ErrorContains(t, err, `unknown output format "yaml"`)
+}
+
+func TestASCIIInfo(t *testing.T) {
+	opts := hive.DefaultOptions()
+	opts.ASCIIInfo = true
+	cmd := hive.NewWithOptions(opts,
+		cell.Module("test", "Test Module",
+			cell.Provide(func() *SomeObject { return &SomeObject{1} }),
+			cell.Invoke(func(*SomeObject) {}),
+		),
+	).Command()
+	var buf bytes.Buffer
+	cmd.SetOut(&buf)
+	cmd.SetArgs([]string{"objects"})
+	require.NoError(t, cmd.Execute())
+
+	assert.Contains(t, buf.String(), "[module] test (Test Module)")
+	assert.Contains(t, buf.String(), "-> *hive_test.SomeObject")
+	assert.NotContains(t, buf.String(), "🚧")
+}
+
+// captureStdout returns what fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestInfoHealth(t *testing.T) {
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.InfoHealth = true
+	opts.ModuleDecorators = cell.ModuleDecorators{cell.HealthModuleDecorator}
+	h := hive.NewWithOptions(opts,
+		cell.SimpleHealthCell,
+		cell.Module("outer", "Outer",
+			cell.Invoke(func(h cell.Health) { h.OK("Outer ready") }),
+			cell.Module("inner", "Inner",
+				cell.Invoke(func(h cell.Health) {
+					h.NewScope("component").Degraded("Connection lost", errors.New("timeout"))
+				}),
+			),
+		),
+		cell.Module("other", "Other",
+			cell.Invoke(func(h cell.Health) { h.OK("Other ready") }),
+		),
+	)
+
+	out := captureStdout(t, h.PrintObjects)
+	assert.Contains(t, out, "outer (Outer):")
+	assert.NotContains(t, out, "[health:", "expected no health before the start")
+
+	require.NoError(t, h.Start(context.TODO()), "Start")
+	out = captureStdout(t, h.PrintObjects)
+	require.NoError(t, h.Stop(context.TODO()), "Stop")
+	assert.Contains(t, out, "outer (Outer) [health: Degraded, cause outer.inner.component]:")
+	assert.Contains(t, out, "inner (Inner) [health: Degraded, cause outer.inner.component]:")
+	assert.Contains(t, out, "other (Other) [health: OK]:")
+}
+
+func TestPopulateTargets(t *testing.T) {
+	started := false
+	h := hive.New(
+		cell.Provide(
+			func(lc cell.Lifecycle) *SomeObject {
+				lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+					started = true
+					return nil
+				}})
+				return &SomeObject{X: 1}
+			},
+			func(s *SomeObject) *OtherObject { return &OtherObject{Y: s.X + 1} },
+			func() groupOut { return groupOut{Object: &SomeObject{X: 10}} },
+			func() groupOut { return groupOut{Object: &SomeObject{X: 20}} },
+		),
+	)
+
+	var (
+		some   *SomeObject
+		other  *OtherObject
+		params struct {
+			cell.In
+			Objects []*SomeObject `group:"objects"`
+		}
+	)
+	require.NoError(t, h.Populate(&some, &other, &params))
+	assert.Equal(t, 1, some.X)
+	assert.Equal(t, 2, other.Y)
+	assert.ElementsMatch(t, []*SomeObject{{X: 10}, {X: 20}}, params.Objects)
+	assert.False(t, started, "expected start hooks not to be executed")
+
+	var missing *ThirdObject
+	err := h.Populate(&missing)
+	assert.ErrorContains(t, err, "failed to populate *hive_test.ThirdObject")
+	assert.ErrorContains(t, err, "missing type: *hive_test.ThirdObject")
+
+	assert.ErrorContains(t, h.Populate(*some), "is not a non-nil pointer")
+}
+
+func TestRootContext(t *testing.T) {
+	var (
+		rootCtx         cell.RootContext
+		cancelledInStop bool
+	)
+	h := hive.New(
+		cell.Provide(func(ctx cell.RootContext, lc cell.Lifecycle) *SomeObject {
+			rootCtx = ctx
+			lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+				cancelledInStop = ctx.Err() != nil
+				return nil
+			}})
+			return &SomeObject{}
+		}),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+
+	require.NoError(t, h.Start(context.TODO()))
+	require.NotNil(t, rootCtx)
+	assert.NoError(t, rootCtx.Err(), "expected root context to be alive after start")
+
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.True(t, cancelledInStop, "expected root context to be cancelled before stop hooks")
+	assert.ErrorIs(t, rootCtx.Err(), context.Canceled)
+}
+
+func TestStartWatchdog(t *testing.T) {
+	var rec logRecorder
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(&rec)
+	opts.StartWatchdog = 10 * time.Millisecond
+	opts.WatchdogStacks = true
+
+	release := make(chan struct{})
+	h := hive.NewWithOptions(
+		opts,
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.HookWithName("blocking", cell.Hook{
+				OnStart: func(cell.HookContext) error {
+					<-release
+					return nil
+				},
+			}))
+		}),
+	)
+
+	errs := make(chan error, 1)
+	go func() { errs <- h.Start(context.TODO()) }()
+
+	const msg = "Start has not completed, start hook still running"
+	require.Eventually(t,
+		func() bool { return len(rec.find(slog.LevelWarn, msg, "function")) > 0 },
+		5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, "blocking", rec.find(slog.LevelWarn, msg, "function")[0])
+	assert.Contains(t, rec.find(slog.LevelWarn, msg, "stacks")[0], "goroutine")
+
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, h.Stop(context.TODO()))
+}
+
+func TestConcurrentHivesSharedCells(t *testing.T) {
+	shared := cell.Module("shared", "Shared cells",
+		cell.Config(Config{}),
+		cell.Provide(func(cfg Config) *SomeObject { return &SomeObject{X: cfg.Bar} }),
+		cell.ProvidePrivate(func(s *SomeObject) *OtherObject { return &OtherObject{Y: s.X} }),
+		cell.Invoke(func(*OtherObject) {}),
+	)
+	info := func() string {
+		b, err := json.Marshal(shared.Info(dig.New()))
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	const numHives = 50
+	var wg sync.WaitGroup
+	objects := make([]*SomeObject, numHives)
+	errs := make([]error, numHives)
+	for i := 0; i < numHives; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h := hive.New(shared)
+			hive.AddConfigOverride(h, func(cfg *Config) { cfg.Bar = i })
+			errs[i] = h.Populate(&objects[i])
+			info()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < numHives; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, i, objects[i].X, "expected each hive to construct its own objects")
+	}
+	assert.Contains(t, info(), `"inputs":[{"type":"hive_test.Config"}]`)
+	assert.Contains(t, info(), `"outputs":[{"type":"*hive_test.SomeObject"}]`)
+}
+
+func TestSharedCellDistinctConfig(t *testing.T) {
+	shared := cell.Module("shared", "Shared cells",
+		cell.Config(Config{}),
+		cell.Config(ServerConfig{}),
+	)
+
+	newHive := func(args ...string) (*hive.Hive, *pflag.FlagSet) {
+		h := hive.New(shared)
+		flags := pflag.NewFlagSet("", pflag.ContinueOnError)
+		h.RegisterFlags(flags)
+		require.NoError(t, flags.Parse(args))
+		return h, flags
+	}
+	h1, flags1 := newHive("--foo=one")
+	h2, flags2 := newHive("--foo=two", "--server-cert-file=/tmp/cert.pem")
+	h3 := hive.New(shared)
+	h3.Viper().Set("bar", 3)
+
+	var cfg1, cfg2, cfg3 Config
+	var server1, server2 ServerConfig
+	require.NoError(t, h1.Populate(&cfg1, &server1))
+	require.NoError(t, h2.Populate(&cfg2, &server2))
+	require.NoError(t, h3.Populate(&cfg3))
+
+	assert.Equal(t, "one", cfg1.Foo)
+	assert.Equal(t, "two", cfg2.Foo)
+	assert.Equal(t, "hello world", cfg3.Foo)
+	assert.Equal(t, 123, cfg1.Bar)
+	assert.Equal(t, 3, cfg3.Bar)
+	assert.Empty(t, server1.CertFile)
+	assert.Equal(t, "/tmp/cert.pem", server2.CertFile)
+
+	// The flags are distinct for each hive.
+	assert.Equal(t, "one", flags1.Lookup("foo").Value.String())
+	assert.Equal(t, "two", flags2.Lookup("foo").Value.String())
+	assert.False(t, flags1.Lookup("server-cert-file").Changed)
+}
+
+func TestOptional(t *testing.T) {
+	var got *SomeObject
+	newOther := func(some cell.Optional[*SomeObject]) *OtherObject {
+		got = some.Value
+		return &OtherObject{}
+	}
+	consumer := cell.Provide(newOther)
+	invoke := cell.Invoke(func(*OtherObject) {})
+
+	// Present
+	h := hive.New(
+		cell.Provide(func() *SomeObject { return &SomeObject{X: 1} }),
+		consumer, invoke,
+	)
+	require.NoError(t, h.Populate())
+	require.NotNil(t, got)
+	assert.Equal(t, 1, got.X)
+
+	// Absent
+	got = &SomeObject{}
+	h = hive.New(consumer, invoke)
+	require.NoError(t, h.Populate())
+	assert.Nil(t, got)
+
+	info := consumer.Info(nil).(*cell.InfoNode).Children()[0].(*cell.InfoNode).Provider()
+	require.Len(t, info.Inputs, 1)
+	assert.True(t, info.Inputs[0].Optional)
+	assert.Equal(t, "*hive_test.SomeObject[optional]", info.Inputs[0].String())
+}
+
+func TestStartupDuration(t *testing.T) {
+	var rec logRecorder
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(&rec)
+
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(func(lc cell.Lifecycle) *SomeObject {
+			time.Sleep(time.Millisecond)
+			lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+				time.Sleep(time.Millisecond)
+				return nil
+			}})
+			return &SomeObject{}
+		}),
+		cell.Invoke(func(*SomeObject) {}),
+	)
+	assert.NotZero(t, h.StartupDuration().Build)
+	assert.Zero(t, h.StartupDuration().Start)
+
+	require.NoError(t, h.Start(context.TODO()))
+	d := h.StartupDuration()
+	assert.GreaterOrEqual(t, d.Invoke, time.Millisecond)
+	assert.GreaterOrEqual(t, d.Start, time.Millisecond)
+	assert.Equal(t, d.Build+d.Invoke+d.Start, d.Total())
+	assert.Equal(t, []string{d.Total().String()}, rec.find(slog.LevelInfo, "Started", "total-duration"))
+	require.NoError(t, h.Stop(context.TODO()))
+}
+
+// fakeClock is a cell.Clock that only advances when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.Now().Sub(t) }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestFakeClock(t *testing.T) {
+	var rec logRecorder
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(&rec)
+	opts.LogThreshold = time.Second
+	opts.Clock = clock
+
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(
+			func() *SomeObject {
+				clock.Advance(5 * time.Second)
+				return &SomeObject{}
+			},
+			func(c cell.Clock, lc cell.Lifecycle) *OtherObject {
+				assert.Same(t, clock, c, "expected the clock to be provided")
+				lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+					clock.Advance(2 * time.Second)
+					return nil
+				}})
+				return &OtherObject{}
+			},
+		),
+		cell.Invoke(func(*SomeObject, *OtherObject) {}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+
+	// The slow constructor is logged at Info level with the fake duration
+	// and the fast one only at Debug level.
+	assert.Equal(t, []string{"5s"}, rec.find(slog.LevelInfo, "Constructed", "duration"))
+	assert.Equal(t, []string{"0s"}, rec.find(slog.LevelDebug, "Constructed", "duration"))
+	assert.Equal(t, []string{"5s"}, rec.find(slog.LevelInfo, "Invoked", "duration"))
+	assert.Equal(t, []string{"2s"}, rec.find(slog.LevelInfo, "Start hook executed", "duration"))
+	assert.Equal(t, 5*time.Second, h.StartupDuration().Invoke)
+	assert.Equal(t, 2*time.Second, h.StartupDuration().Start)
+	require.NoError(t, h.Stop(context.TODO()))
+}
+
+func TestStopDependencyOrder(t *testing.T) {
+	type B struct{}
+	type A struct{ *B }
+	var events []string
+	appendHook := func(lc cell.Lifecycle, name string) {
+		lc.Append(cell.Hook{
+			OnStart: func(cell.HookContext) error {
+				events = append(events, "start "+name)
+				return nil
+			},
+			OnStop: func(cell.HookContext) error {
+				events = append(events, "stop "+name)
+				return nil
+			},
+		})
+	}
+
+	h := hive.New(
+		cell.Provide(
+			func() *B { return &B{} },
+			func(b *B) *A { return &A{b} },
+		),
+		// The hook for A is appended before the hook for B, yet A depends
+		// on B and must stop first.
+		cell.Invoke(func(lc cell.Lifecycle, _ *A) { appendHook(lc, "a") }),
+		cell.Invoke(func(lc cell.Lifecycle, _ *B) { appendHook(lc, "b") }),
+		// Hooks with the same dependency level stop in reverse order.
+		cell.Invoke(func(lc cell.Lifecycle, _ *B) { appendHook(lc, "c") }),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, []string{
+		"start a", "start b", "start c",
+		"stop a", "stop c", "stop b",
+	}, events)
+}
+
+func newInstantiatedA() *SomeObject             { return &SomeObject{} }
+func newInstantiatedB(*SomeObject) *OtherObject { return &OtherObject{} }
+func newNotInstantiated() *ThirdObject          { return &ThirdObject{} }
+
+func TestInstantiatedConstructors(t *testing.T) {
+	metrics := &fakeConstructorMetrics{durations: map[string][]time.Duration{}}
+	opts := hive.DefaultOptions()
+	opts.ConstructorMetrics = metrics
+	h := hive.NewWithOptions(
+		opts,
+		cell.Provide(newInstantiatedA, newInstantiatedB, newNotInstantiated),
+		cell.Invoke(func(*OtherObject) {}),
+	)
+	assert.Empty(t, h.InstantiatedConstructors())
+	require.NoError(t, h.Populate())
+
+	ctors := h.InstantiatedConstructors()
+	require.Len(t, ctors, 2)
+	assert.Contains(t, ctors[0], "hive_test.newInstantiatedA (")
+	assert.Contains(t, ctors[1], "hive_test.newInstantiatedB (")
+	assert.NotContains(t, strings.Join(ctors, " "), "newNotInstantiated")
+
+	// The durations are still passed on to the metrics.
+	assert.Len(t, metrics.durations["hive_test.newInstantiatedA"], 1)
+}
+
+// buildErr returns the error hive.New panics with for the cells.
+func buildErr(cells ...cell.Cell) error {
+	_, err := hive.NewWithError(hive.DefaultOptions(), cells...)
+	return err
+}
+
+func TestBuildErrorTypes(t *testing.T) {
+	// NewWithOptions panics with the message of the error.
+	func() {
+		defer func() {
+			assert.IsType(t, "", recover())
+		}()
+		hive.New(cell.Provide(newCycleA, newCycleB))
+	}()
+
+	var cycleErr *hive.CycleError
+	err := buildErr(cell.Provide(newCycleA, newCycleB))
+	require.ErrorAs(t, err, &cycleErr)
+	assert.Equal(t, []string{"*hive_test.CycleA", "*hive_test.CycleB", "*hive_test.CycleA"}, cycleErr.Types)
+	require.Len(t, cycleErr.Constructors, 2)
+	assert.Contains(t, cycleErr.Constructors[0], "hive_test.newCycleA")
+
+	var dupErr *hive.DuplicateProvideError
+	err = buildErr(cell.Provide(newDuplicateA), cell.Provide(newDuplicateA2))
+	require.ErrorAs(t, err, &dupErr)
+	assert.Equal(t, "*hive_test.SomeObject", dupErr.Type)
+	require.Len(t, dupErr.Constructors, 2)
+	assert.Contains(t, dupErr.Constructors[0], "hive_test.newDuplicateA ")
+	assert.Contains(t, dupErr.Constructors[1], "hive_test.newDuplicateA2 ")
+	assert.Contains(t, err.Error(), "is provided by both")
+
+	// The errors from dig when applying the cells are wrapped.
+	var digErr dig.Error
+	err = buildErr(cell.Provide(func() (out struct {
+		cell.Out
+		unexported *SomeObject
+	}) {
+		return
+	}))
+	require.ErrorAs(t, err, &digErr)
+	assert.Contains(t, err.Error(), "Failed to apply cell")
+
+	var missingErr *hive.MissingDependencyError
+	h := hive.New(cell.Invoke(func(*SomeObject, *OtherObject, cell.Lifecycle) {}))
+	err = h.Populate()
+	require.ErrorAs(t, err, &missingErr)
+	assert.ElementsMatch(t, []string{"*hive_test.SomeObject", "*hive_test.OtherObject"}, missingErr.Types)
+	assert.ErrorAs(t, h.Validate(), &missingErr)
+
+	assert.False(t, errors.As(err, &cycleErr))
+}
+
+func TestStartProgress(t *testing.T) {
+	type progress struct {
+		done, total int
+		name        string
+	}
+	var got []progress
+	opts := hive.DefaultOptions()
+	opts.StartProgress = func(done, total int, name string) {
+		got = append(got, progress{done, total, name})
+	}
+
+	start := func(cell.HookContext) error { return nil }
+	h := hive.NewWithOptions(
+		opts,
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.HookWithName("first", cell.Hook{OnStart: start}))
+			lc.Append(cell.HookWithName("stop-only", cell.Hook{OnStop: start}))
+			lc.Append(cell.HookWithName("second", cell.Hook{OnStart: start}))
+			lc.Append(cell.HookWithName("third", cell.Hook{OnStart: start}))
+		}),
+	)
+
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, []progress{
+		{1, 3, "first"},
+		{2, 3, "second"},
+		{3, 3, "third"},
+	}, got)
+}
+
+func TestRunSignals(t *testing.T) {
+	signals := make(chan os.Signal)
+	reloaded := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- hive.New().Run(
+			hive.WithSignals(syscall.SIGQUIT),
+			hive.WithReload(func() { reloaded <- struct{}{} }),
+			hive.WithSignalChannel(signals),
+		)
+	}()
+
+	// SIGTERM is not in the signal set and is ignored.
+	signals <- syscall.SIGTERM
+	signals <- syscall.SIGHUP
+	<-reloaded
+	select {
+	case err := <-errs:
+		t.Fatalf("expected Run to continue after SIGTERM and SIGHUP, got %v", err)
+	default:
+	}
+
+	signals <- syscall.SIGQUIT
+	require.NoError(t, <-errs)
+}
+
+func TestRunWithoutSignals(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	shutdownErr := errors.New("shutdown")
+	h := hive.New(
+		cell.Invoke(func(lc cell.Lifecycle, shutdowner hive.Shutdowner) {
+			lc.Append(cell.Hook{
+				OnStart: func(cell.HookContext) error {
+					signals <- os.Interrupt
+					shutdowner.Shutdown(hive.ShutdownWithError(shutdownErr))
+					return nil
+				}})
+		}),
+	)
+	assert.ErrorIs(t, h.Run(hive.WithoutSignals(), hive.WithSignalChannel(signals)), shutdownErr)
+	assert.Len(t, signals, 1, "expected the signal to not be received")
+}
+
+type ReloadConfig struct {
+	ReloadAddress string
+	ReloadLevel   string `reloadable:"true"`
+}
+
+func (ReloadConfig) Flags(flags *pflag.FlagSet) {
+	flags.String("reload-address", "localhost", "address")
+	flags.String("reload-level", "info", "log level")
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("reload-level: debug\n"), 0644))
+
+	cfgs := make(chan ReloadConfig, 1)
+	h := hive.New(
+		cell.ReloadableConfig(ReloadConfig{}),
+		cell.Invoke(func(r *cell.Reloadable[ReloadConfig]) {
+			r.Subscribe(func(c ReloadConfig) { cfgs <- c })
+		}),
+	)
+	h.Viper().SetConfigFile(path)
+	require.NoError(t, h.Viper().ReadInConfig())
+
+	signals := make(chan os.Signal)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- h.Run(
+			hive.WithReload(func() { h.Reload() }),
+			hive.WithSignalChannel(signals))
+	}()
+
+	// Changing the config file and sending SIGHUP reloads the config from
+	// the file.
+	require.NoError(t, os.WriteFile(path, []byte("reload-level: warn\n"), 0644))
+	signals <- syscall.SIGHUP
+	select {
+	case cfg := <-cfgs:
+		assert.Equal(t, ReloadConfig{"localhost", "warn"}, cfg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+
+	signals <- syscall.SIGTERM
+	require.NoError(t, <-errs)
+}
+
+type StartupConfig struct {
+	StartupDelay time.Duration
+	StartupAbort bool
+}
+
+func (StartupConfig) Flags(flags *pflag.FlagSet) {
+	flags.Duration("startup-delay", 0, "delay")
+	flags.Bool("startup-abort", false, "abort")
+}
+
+func TestFlagUsages(t *testing.T) {
+	h := hive.New(
+		cell.Config(Config{}),
+		cell.Module("zeta", "Last module",
+			cell.Config(StartupConfig{}),
+		),
+		cell.Module("alpha", "First module",
+			cell.WithEnableFlag(true),
+			cell.Config(ReloadConfig{}),
+			cell.Module("beta", "Nested module",
+				cell.Config(ServerConfig{}),
+			),
+		),
+	)
+	assert.Equal(t,
+		`Flags:
+      --bar int      bar (default 123)
+      --foo string   sets the greeting (default "hello world")
+
+Module alpha (First module):
+      --enable-alpha            Enable the alpha module (default true)
+      --reload-address string   address (default "localhost")
+      --reload-level string     log level (default "info")
+
+Module alpha.beta (Nested module):
+      --server-address string     address to listen on
+      --server-cert-file string   path to the certificate
+
+Module zeta (Last module):
+      --startup-abort            abort
+      --startup-delay duration   delay
+`,
+		h.FlagUsages())
+}
+
+func TestContainer(t *testing.T) {
+	h := hive.New(
+		cell.Config(Config{}),
+		cell.Provide(func(cfg Config) *SomeObject { return &SomeObject{X: cfg.Bar} }),
+	)
+	require.NoError(t, h.Container().Decorate(func(o *SomeObject) *SomeObject {
+		return &SomeObject{X: o.X + 1}
+	}))
+	require.NoError(t, h.Populate())
+
+	var obj *SomeObject
+	require.NoError(t, h.Container().Invoke(func(o *SomeObject) { obj = o }))
+	assert.Equal(t, 124, obj.X)
+
+	assert.PanicsWithValue(t,
+		"hive.Container() called on a hive not constructed with hive.New or hive.NewWithOptions",
+		func() { (&hive.Hive{}).Container() })
+}
+
+type fakeSpanKey struct{}
+
+// fakeTracer records the finished spans as "parent/child" paths with their
+// errors.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type fakeSpan struct {
+	t    *fakeTracer
+	path string
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, cell.Span) {
+	path := name
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		path = parent.path + "/" + name
+	}
+	span := &fakeSpan{t, path}
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (s *fakeSpan) End(err error) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	entry := s.path
+	if err != nil {
+		entry += ": " + err.Error()
+	}
+	s.t.spans = append(s.t.spans, entry)
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &fakeTracer{}
+	opts := hive.DefaultOptions()
+	opts.Tracer = tracer
+	errHook := errors.New("boom")
+
+	newObject := func(lc cell.Lifecycle) *SomeObject {
+		lc.Append(cell.HookWithName("object", cell.Hook{
+			OnStart: func(ctx cell.HookContext) error {
+				// The hooks are given the context of their span.
+				_, span := tracer.Start(ctx, "nested")
+				span.End(nil)
+				return nil
+			},
+			OnStop: func(cell.HookContext) error { return nil },
+		}))
+		return &SomeObject{}
+	}
+	h := hive.NewWithOptions(opts,
+		cell.Provide(newObject),
+		cell.Invoke(func(lc cell.Lifecycle, _ *SomeObject) {
+			lc.Append(cell.HookWithName("failing", cell.Hook{
+				OnStart: func(cell.HookContext) error { return errHook },
+			}))
+		}),
+	)
+	require.ErrorIs(t, h.Start(context.TODO()), errHook)
+	require.NoError(t, h.Stop(context.TODO()))
+
+	ctorName := tracer.spans[0]
+	assert.Contains(t, ctorName, "hive start/hive_test.TestTracer.func")
+	assert.Equal(t, []string{
+		ctorName,
+		"hive start/object/nested",
+		"hive start/object",
+		"hive start/failing: boom",
+		"hive start: start hook failing failed: boom",
+		"hive stop/object",
+		"hive stop",
+	}, tracer.spans)
+}
+
+func TestStartupReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "startup.json")
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.StartupReport = path
+	opts.ModuleDecorators = cell.ModuleDecorators{cell.HealthModuleDecorator}
+	h := hive.NewWithOptions(opts,
+		cell.SimpleHealthCell,
+		cell.Module("test", "Test",
+			cell.Provide(newSome),
+			cell.Invoke(func(h cell.Health, lc cell.Lifecycle, _ *SomeObject) {
+				lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+					h.OK("Running")
+					return nil
+				}})
+			}),
+		),
+	)
+	_, err := os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist, "expected no report before start")
+
+	require.NoError(t, h.Start(context.TODO()))
+	t.Cleanup(func() { h.Stop(context.TODO()) })
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var report map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &report))
+	for _, section := range []string{"durations", "constructors", "hooks", "healthy", "health"} {
+		assert.Contains(t, report, section)
+	}
+
+	var decoded hive.StartupReport
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, h.StartupDuration().Total(), decoded.Durations.Total)
+	require.Len(t, decoded.Constructors, 2)
+	assert.Contains(t, decoded.Constructors[1].Name, "hive_test.newSome")
+	require.Len(t, decoded.Hooks, 1)
+	assert.Equal(t, "start", decoded.Hooks[0].Phase)
+	assert.True(t, decoded.Healthy)
+	assert.Contains(t, string(report["health"]), `"Running"`)
+}
+
+func TestStopTimeoutAbandonsHooks(t *testing.T) {
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+
+	var stopped []string
+	rec := &logRecorder{}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(rec)
+	opts.StopTimeout = 10 * time.Millisecond
+	h := hive.NewWithOptions(opts,
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+				stopped = append(stopped, "first")
+				return nil
+			}})
+			lc.Append(cell.HookWithName("hanging", cell.Hook{OnStop: func(cell.HookContext) error {
+				<-block
+				return nil
+			}}))
+		}),
+		shutdownOnStartCell,
+	)
+
+	err := h.Run(hive.WithoutSignals())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stop hooks did not complete: hanging")
+	assert.Empty(t, stopped, "expected the hooks after the hanging one to be abandoned")
+	assert.Equal(t, []string{"[hanging]"}, rec.find(slog.LevelError, "Stop cancelled, abandoning hooks", "outstanding"))
+	assert.Equal(t, []string{"1"}, rec.find(slog.LevelError, "Stop cancelled, abandoning hooks", "not-stopped"))
+}
+
+func TestStopTimeoutAbandonsParallelHooks(t *testing.T) {
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+
+	var (
+		mu      sync.Mutex
+		stopped []string
+	)
+	rec := &logRecorder{}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(rec)
+	opts.ParallelStop = 2
+	// The grace period for the abandoned hooks does not depend on the clock.
+	opts.Clock = &fakeClock{now: time.Unix(0, 0)}
+	h := hive.NewWithOptions(opts,
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.Hook{OnStop: func(cell.HookContext) error {
+				mu.Lock()
+				defer mu.Unlock()
+				stopped = append(stopped, "queued")
+				return nil
+			}})
+		}),
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.HookWithName("hanging", cell.Hook{OnStop: func(cell.HookContext) error {
+				<-block
+				return nil
+			}}))
+		}),
+		cell.Invoke(func(lc cell.Lifecycle) {
+			lc.Append(cell.HookWithName("respecting", cell.Hook{OnStop: func(ctx cell.HookContext) error {
+				<-ctx.Done()
+				return nil
+			}}))
+		}),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := h.Stop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stop hooks did not complete: hanging")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, stopped, "expected the queued hook to be abandoned")
+}
+
+func TestGraphSnapshot(t *testing.T) {
+	build := func(reversed bool) *hive.Hive {
+		cells := []cell.Cell{
+			cell.Module("test", "Test",
+				cell.ProvidePrivate(func(o *SomeObject) *OtherObject { return &OtherObject{Y: o.X} }),
+				cell.Invoke(func(*OtherObject) {}),
+			),
+			cell.Provide(newSome),
+		}
+		if reversed {
+			cells[0], cells[1] = cells[1], cells[0]
+		}
+		return hive.New(cells...)
+	}
+
+	snapshot := build(false).GraphSnapshot()
+	assert.Equal(t, snapshot, build(false).GraphSnapshot(), "expected the snapshot to be stable")
+	assert.Equal(t, snapshot, build(true).GraphSnapshot(), "expected the snapshot to not depend on the order of the cells")
+	assert.Equal(t, `root:
+  constructor hive_test.newSome (hive_test.go):
+    outputs: *hive_test.SomeObject
+
+module test:
+  invoke hive_test.TestGraphSnapshot.func1.2 (hive_test.go):
+    inputs: *hive_test.OtherObject
+  private constructor hive_test.TestGraphSnapshot.func1.1 (hive_test.go):
+    inputs: *hive_test.SomeObject
+    outputs: *hive_test.OtherObject
+`, snapshot)
+}
+
+func TestFrameworkLogger(t *testing.T) {
+	appRec, frameworkRec := &logRecorder{}, &logRecorder{}
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(appRec)
+	opts.FrameworkLogger = slog.New(frameworkRec)
+	opts.LogThreshold = time.Millisecond
+	h := hive.NewWithOptions(opts,
+		cell.Module("test", "Test",
+			cell.Provide(func(log *slog.Logger) *SomeObject {
+				log.Info("Constructing", "object", "some")
+				time.Sleep(5 * time.Millisecond)
+				return &SomeObject{}
+			}),
+			cell.Invoke(func(*SomeObject) {}),
+		),
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+
+	assert.Len(t, frameworkRec.find(slog.LevelInfo, "Constructed", "function"), 1, "expected the slow constructor on the framework logger")
+	assert.Len(t, frameworkRec.find(slog.LevelInfo, "Started", "duration"), 1)
+	assert.Empty(t, frameworkRec.find(slog.LevelInfo, "Constructing", "object"))
+
+	assert.Equal(t, []string{"some"}, appRec.find(slog.LevelInfo, "Constructing", "object"))
+	assert.Empty(t, appRec.find(slog.LevelInfo, "Constructed", "function"), "expected no framework logs on the app logger")
+	assert.Empty(t, appRec.find(slog.LevelInfo, "Started", "duration"))
+}
+
+func TestRandSeed(t *testing.T) {
+	sequence := func(seed int64) []int64 {
+		var seq []int64
+		opts := hive.DefaultOptions()
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		opts.RandSeed = seed
+		h := hive.NewWithOptions(opts,
+			cell.Invoke(func(r *rand.Rand) {
+				for i := 0; i < 5; i++ {
+					seq = append(seq, r.Int63())
+				}
+			}),
+		)
+		require.NoError(t, h.Populate(), "Populate")
+		return seq
+	}
+
+	assert.Equal(t, sequence(42), sequence(42), "expected the same sequence with the same seed")
+	assert.NotEqual(t, sequence(42), sequence(43), "expected different sequences with different seeds")
+	assert.NotEqual(t, sequence(0), sequence(0), "expected different sequences without a seed")
+}
+
+func TestPhaseHooks(t *testing.T) {
+	var events []string
+	record := func(ev string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			events = append(events, ev)
+			return err
+		}
+	}
+	newHive := func(hooks ...hive.PhaseHook) *hive.Hive {
+		opts := hive.DefaultOptions()
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		opts.PhaseHooks = hooks
+		return hive.NewWithOptions(opts,
+			cell.Invoke(func(lc cell.Lifecycle) {
+				lc.Append(cell.Hook{
+					OnStart: func(ctx cell.HookContext) error { return record("start", nil)(ctx) },
+					OnStop:  func(ctx cell.HookContext) error { return record("stop", nil)(ctx) },
+				})
+			}),
+			shutdownOnStartCell,
+		)
+	}
+
+	// The hooks run at the boundaries in the order they are given in.
+	errPostStop := errors.New("post-stop failed")
+	h := newHive(
+		hive.PhaseHook{Phase: hive.PostStop, Fn: record("post-stop-1", errPostStop)},
+		hive.PhaseHook{Phase: hive.PreStart, Fn: record("pre-start", nil)},
+		hive.PhaseHook{Phase: hive.PreStop, Fn: record("pre-stop", nil)},
+		hive.PhaseHook{Phase: hive.PostStart, Fn: record("post-start", nil)},
+		hive.PhaseHook{Phase: hive.PostStop, Fn: record("post-stop-2", nil)},
+	)
+	err := h.Run()
+	assert.ErrorIs(t, err, errPostStop, "expected the post-stop error to be returned from Run")
+	assert.Equal(t,
+		[]string{"pre-start", "start", "post-start", "pre-stop", "stop", "post-stop-1", "post-stop-2"},
+		events)
+
+	// An error in a pre-start hook aborts the start.
+	events = nil
+	errPreStart := errors.New("pre-start failed")
+	h = newHive(
+		hive.PhaseHook{Phase: hive.PreStart, Fn: record("pre-start-1", errPreStart)},
+		hive.PhaseHook{Phase: hive.PreStart, Fn: record("pre-start-2", nil)},
+	)
+	err = h.Start(context.TODO())
+	assert.ErrorIs(t, err, errPreStart)
+	assert.ErrorContains(t, err, "pre-start hook hive_test.TestPhaseHooks.func")
+	assert.Equal(t, []string{"pre-start-1"}, events, "expected the start to be aborted")
+}
+
+func TestShutdownReason(t *testing.T) {
+	type cause struct {
+		reason hive.ShutdownReason
+		err    error
+	}
+	run := func(trigger cell.Cell, opts ...hive.RunOption) (cause, error) {
+		var got cause
+		hopts := hive.DefaultOptions()
+		hopts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		h := hive.NewWithOptions(hopts,
+			cell.Invoke(func(lc cell.Lifecycle) {
+				lc.Append(cell.Hook{OnStop: func(ctx cell.HookContext) error {
+					got.reason, got.err = hive.ShutdownReasonOf(ctx)
+					return nil
+				}})
+			}),
+			trigger,
+		)
+		return got, h.Run(opts...)
+	}
+
+	got, err := run(shutdownOnStartCell)
+	require.NoError(t, err)
+	assert.Equal(t, cause{hive.ShutdownClean, nil}, got)
+
+	errShutdown := errors.New("shutdown")
+	got, err = run(cell.Invoke(func(lc cell.Lifecycle, s hive.Shutdowner) {
+		lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+			s.Shutdown(hive.ShutdownWithError(errShutdown))
+			return nil
+		}})
+	}))
+	assert.ErrorIs(t, err, errShutdown)
+	assert.Equal(t, cause{hive.ShutdownError, errShutdown}, got)
+
+	errStart := errors.New("start")
+	got, err = run(cell.Invoke(func(lc cell.Lifecycle) {
+		lc.Append(cell.Hook{OnStart: func(cell.HookContext) error { return errStart }})
+	}))
+	assert.ErrorIs(t, err, errStart)
+	assert.Equal(t, hive.ShutdownError, got.reason)
+	assert.ErrorIs(t, got.err, errStart)
+
+	signals := make(chan os.Signal, 1)
+	got, err = run(cell.Invoke(func(lc cell.Lifecycle) {
+		lc.Append(cell.Hook{OnStart: func(cell.HookContext) error {
+			signals <- syscall.SIGTERM
+			return nil
+		}})
+	}), hive.WithSignalChannel(signals))
+	require.NoError(t, err)
+	assert.Equal(t, cause{hive.ShutdownSignal, nil}, got)
+
+	// A context not given by the hive is a clean shutdown.
+	reason, err := hive.ShutdownReasonOf(context.Background())
+	assert.Equal(t, hive.ShutdownClean, reason)
+	assert.NoError(t, err)
+
+	// The reason is cleared when the hive is started again.
+	var (
+		runs int
+		ctx  context.Context
+		stop context.CancelFunc
+	)
+	hopts := hive.DefaultOptions()
+	hopts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := hive.NewWithOptions(hopts,
+		cell.Invoke(func(lc cell.Lifecycle, s hive.Shutdowner) {
+			lc.Append(cell.Hook{
+				OnStart: func(cell.HookContext) error {
+					runs++
+					if runs == 1 {
+						s.Shutdown(hive.ShutdownWithError(errShutdown))
+					} else {
+						stop()
+					}
+					return nil
+				},
+				OnStop: func(ctx cell.HookContext) error {
+					got.reason, got.err = hive.ShutdownReasonOf(ctx)
+					return nil
+				},
+			})
+		}),
+	)
+	ctx, stop = context.WithCancel(context.Background())
+	assert.ErrorIs(t, h.RunContext(ctx), errShutdown)
+	assert.Equal(t, cause{hive.ShutdownError, errShutdown}, got)
+	ctx, stop = context.WithCancel(context.Background())
+	defer stop()
+	require.NoError(t, h.RunContext(ctx))
+	assert.Equal(t, cause{hive.ShutdownClean, nil}, got)
+}
+
+func TestDefaultLogger(t *testing.T) {
+	for name, h := range map[string]func(...cell.Cell) *hive.Hive{
+		"New": hive.New,
+		"zero options": func(cells ...cell.Cell) *hive.Hive {
+			return hive.NewWithOptions(hive.Options{}, cells...)
+		},
+	} {
+		var loggers []*slog.Logger
+		record := func(log *slog.Logger, root cell.RootLogger) {
+			loggers = append(loggers, log, root)
+		}
+		err := h(
+			cell.Invoke(record),
+			cell.Module("test", "Test", cell.Invoke(record)),
+		).Populate()
+		require.NoError(t, err, name)
+
+		require.Len(t, loggers, 4, name)
+		for _, log := range loggers {
+			require.NotNil(t, log, name)
+			assert.NotPanics(t, func() { log.Debug("Injected logger is usable") }, name)
+		}
+	}
 }
@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest
+
+import (
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+// Option modifies the options of the hive constructed by New.
+type Option func(*hive.Options)
+
+// WithConstructorCache returns an option that caches the results of the
+// pure constructors (cell.ProvidePure) in a cache shared by all the hives
+// the option is used for. Create the option once and use it for each build
+// in e.g. a table-driven test so that the expensive pure constructors are
+// only called once for the same inputs:
+//
+//	withCache := hivetest.WithConstructorCache()
+//	for _, tc := range testCases {
+//		h := hivetest.New(t, foo.Cell, withCache, tc.override)
+//		...
+//	}
+//
+// The option can also be applied to hive.Options directly. Constructors that
+// are not marked pure are called for every hive.
+func WithConstructorCache() Option {
+	cache := cell.NewConstructorCache()
+	return func(opts *hive.Options) {
+		opts.ConstructorCache = cache
+	}
+}
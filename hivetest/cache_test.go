@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/hivetest"
+)
+
+type Index struct {
+	Words []string
+}
+
+type Searcher struct {
+	Index *Index
+}
+
+func TestWithConstructorCache(t *testing.T) {
+	var pureCalls, impureCalls int
+	c := cell.Group(
+		cell.ProvidePure(func(db *Database) *Index {
+			pureCalls++
+			return &Index{Words: []string{db.Name}}
+		}),
+		cell.Provide(func(idx *Index) *Searcher {
+			impureCalls++
+			return &Searcher{Index: idx}
+		}),
+		cell.Invoke(func(*Searcher) {}),
+	)
+
+	const builds = 5
+	withCache := hivetest.WithConstructorCache()
+	var indexes []*Index
+	for i := 0; i < builds; i++ {
+		h := hivetest.New(t, c, withCache,
+			func() *Database { return &Database{Name: "words"} })
+		require.NoError(t, h.Start(context.TODO()))
+		require.NoError(t, h.Stop(context.TODO()))
+		var idx *Index
+		require.NoError(t, h.Populate(&idx))
+		indexes = append(indexes, idx)
+	}
+	assert.Equal(t, 1, pureCalls, "expected the pure constructor to be called once")
+	assert.Equal(t, builds, impureCalls, "expected the impure constructor to be called for each build")
+	for _, idx := range indexes {
+		assert.Same(t, indexes[0], idx)
+	}
+
+	// Different inputs are not served from the cache.
+	h := hivetest.New(t, c, withCache,
+		func() *Database { return &Database{Name: "other"} })
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, 2, pureCalls)
+
+	// Without the cache the pure constructor is called for each build.
+	h = hivetest.New(t, c, func() *Database { return &Database{Name: "words"} })
+	require.NoError(t, h.Start(context.TODO()))
+	require.NoError(t, h.Stop(context.TODO()))
+	assert.Equal(t, 3, pureCalls)
+
+	// The closures of the same function literal are different constructors.
+	provideIndex := func(word string) cell.Cell {
+		return cell.ProvidePure(func() *Index { return &Index{Words: []string{word}} })
+	}
+	for _, word := range []string{"foo", "bar"} {
+		h := hivetest.New(t, provideIndex(word), withCache)
+		var idx *Index
+		require.NoError(t, h.Populate(&idx))
+		assert.Equal(t, []string{word}, idx.Words)
+	}
+}
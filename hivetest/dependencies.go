@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/dig"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/internal"
+)
+
+// Dependencies returns the inputs the cell requires and the outputs it
+// provides, e.g. "*foo.Bar" or `[]foo.Handler[group = "handlers"]`. The inputs
+// are the inputs of the constructors and invoke functions in the cell that are
+// not provided by the cell itself. The outputs are the outputs of the exported
+// constructors and the config structs. Both are sorted.
+//
+// The cell is applied to a new hive, but the hive is not populated.
+func Dependencies(c cell.Cell) (inputs, outputs []string, err error) {
+	if err := apply(c); err != nil {
+		return nil, nil, err
+	}
+	missing, outputs := dependencies(c)
+	for _, in := range missing {
+		inputs = append(inputs, in.String())
+	}
+	slices.Sort(inputs)
+	slices.Sort(outputs)
+	return slices.Compact(inputs), slices.Compact(outputs), nil
+}
+
+// input is an input of a constructor or an invoke function.
+type input struct {
+	cell.InfoValue
+	typ reflect.Type
+}
+
+// dependencies returns the inputs not provided by the applied cell and
+// the outputs it provides.
+func dependencies(c cell.Cell) (missing []input, outputs []string) {
+	var (
+		allInputs  []input
+		allOutputs []cell.InfoValue
+	)
+	var walk func(info cell.Info)
+	walk = func(info cell.Info) {
+		switch n := info.(type) {
+		case *cell.InfoStruct:
+			typ := reflect.TypeOf(n.Value()).String()
+			allOutputs = append(allOutputs, cell.InfoValue{Type: typ})
+			outputs = append(outputs, typ)
+		case *cell.InfoNode:
+			if p := n.Provider(); p != nil {
+				for _, in := range p.Inputs {
+					allInputs = append(allInputs, input{in, p.InputType(in)})
+				}
+				allOutputs = append(allOutputs, p.Outputs...)
+				if p.Exported {
+					for _, out := range p.Outputs {
+						outputs = append(outputs, out.String())
+					}
+				}
+			}
+			if inv := n.Invoke(); inv != nil {
+				for _, in := range inv.Inputs {
+					allInputs = append(allInputs, input{in, inv.InputType(in)})
+				}
+			}
+			for _, child := range n.Children() {
+				walk(child)
+			}
+		}
+	}
+	walk(c.Info(dig.New()))
+
+inputs:
+	for _, in := range allInputs {
+		for _, out := range allOutputs {
+			if providesInput(out, in.InfoValue) {
+				continue inputs
+			}
+		}
+		missing = append(missing, in)
+	}
+	return missing, outputs
+}
+
+// apply applies the cell to a new hive in order to fill the information
+// about its constructors.
+func apply(c cell.Cell) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	hive.NewWithOptions(opts, c)
+	return nil
+}
+
+// providesInput returns true if the output satisfies the input, either by
+// being of the same type and name or by being a member of the value group.
+func providesInput(out, in cell.InfoValue) bool {
+	return internal.ProvidesInput(digValue(out), digValue(in))
+}
+
+// digValue returns the input or output as a value for internal.
+func digValue(v cell.InfoValue) internal.DigValue {
+	return internal.DigValue{TypeName: v.Type, Name: v.Name, Group: v.Group, Optional: v.Optional}
+}
+
+// AssertDependencies asserts that the cell requires exactly the given inputs
+// and provides exactly the given outputs, in any order. See Dependencies for
+// how they are determined and formatted. Useful for catching accidental
+// changes to the dependencies of a module:
+//
+//	hivetest.AssertDependencies(t, foo.Cell,
+//		[]string{"*slog.Logger", "cell.Lifecycle"},
+//		[]string{"*foo.Foo", "foo.Config"})
+func AssertDependencies(tb testing.TB, c cell.Cell, wantIn, wantOut []string) {
+	tb.Helper()
+
+	inputs, outputs, err := Dependencies(c)
+	if err != nil {
+		tb.Fatalf("Failed to apply cell: %s", err)
+	}
+	if diff := diffStrings(inputs, wantIn); diff != "" {
+		tb.Errorf("Unexpected inputs:\n%s", diff)
+	}
+	if diff := diffStrings(outputs, wantOut); diff != "" {
+		tb.Errorf("Unexpected outputs:\n%s", diff)
+	}
+}
+
+// diffStrings describes the strings missing from or extra in got.
+func diffStrings(got, want []string) string {
+	want = slices.Clone(want)
+	slices.Sort(want)
+	var b strings.Builder
+	for _, s := range want {
+		if _, found := slices.BinarySearch(got, s); !found {
+			b.WriteString("  missing: " + s + "\n")
+		}
+	}
+	for _, s := range got {
+		if _, found := slices.BinarySearch(want, s); !found {
+			b.WriteString("  unexpected: " + s + "\n")
+		}
+	}
+	return b.String()
+}
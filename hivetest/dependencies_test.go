@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest_test
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/hivetest"
+)
+
+type Config struct {
+	Address string
+}
+
+func (Config) Flags(flags *pflag.FlagSet) {
+	flags.String("address", ":8080", "address")
+}
+
+type Server struct{}
+type store struct{}
+type Handler interface{}
+
+type serverParams struct {
+	cell.In
+
+	Config   Config
+	Log      *slog.Logger
+	Store    *store
+	Handlers []Handler `group:"handlers"`
+}
+
+var testCell = cell.Module("server", "Server",
+	cell.Config(Config{}),
+	cell.ProvidePrivate(func() *store { return &store{} }),
+	cell.Provide(func(serverParams) *Server { return &Server{} }),
+	cell.Invoke(func(*Server, cell.Lifecycle) {}),
+)
+
+func TestDependencies(t *testing.T) {
+	inputs, outputs, err := hivetest.Dependencies(testCell)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*slog.Logger", "[]hivetest_test.Handler[group = \"handlers\"]", "cell.Lifecycle"}, inputs)
+	assert.Equal(t, []string{"*hivetest_test.Server", "hivetest_test.Config"}, outputs)
+
+	hivetest.AssertDependencies(t, testCell,
+		[]string{"cell.Lifecycle", "*slog.Logger", "[]hivetest_test.Handler[group = \"handlers\"]"},
+		[]string{"hivetest_test.Config", "*hivetest_test.Server"})
+}
+
+func TestAssertDependenciesMismatch(t *testing.T) {
+	ft := &fakeTB{TB: t}
+	hivetest.AssertDependencies(ft, testCell,
+		[]string{"cell.Lifecycle", "*slog.Logger"},
+		[]string{"hivetest_test.Config", "*hivetest_test.Server", "*hivetest_test.Client"})
+	assert.Equal(t, []string{
+		"Unexpected inputs:\n  unexpected: []hivetest_test.Handler[group = \"handlers\"]\n",
+		"Unexpected outputs:\n  missing: *hivetest_test.Client\n",
+	}, ft.errors)
+}
+
+// fakeTB records the errors instead of failing the test.
+type fakeTB struct {
+	testing.TB
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Fatalf(format string, args ...any) {
+	f.Errorf(format, args...)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/internal"
+)
+
+// New constructs a hive from the cell for testing it in isolation. The inputs
+// of the cell that are provided neither by the cell, the overrides nor the hive
+// itself are stubbed: pointers are provided as pointers to zero values, maps
+// as empty maps and other values as zero values. The overrides are
+// constructors for the inputs that cannot be stubbed, e.g. interfaces and
+// functions, or that need a non-zero value. Optional inputs and value groups
+// are not stubbed.
+//
+// The test fails immediately if an input cannot be stubbed:
+//
+//	h := hivetest.New(t, foo.Cell,
+//		func() foo.Store { return &fakeStore{} })
+//	require.NoError(t, h.Start(ctx))
+//
+// Options such as WithConstructorCache can be given among the overrides.
+func New(tb testing.TB, c cell.Cell, overrides ...any) *hive.Hive {
+	tb.Helper()
+
+	var (
+		options []Option
+		ctors   []any
+	)
+	for _, o := range overrides {
+		if opt, ok := o.(Option); ok {
+			options = append(options, opt)
+		} else {
+			ctors = append(ctors, o)
+		}
+	}
+
+	cells := []cell.Cell{c}
+	if len(ctors) > 0 {
+		cells = append(cells, cell.Provide(ctors...))
+	}
+	group := cell.Group(cells...)
+	if err := apply(group); err != nil {
+		tb.Fatalf("Failed to apply cell: %s", err)
+	}
+
+	missing, _ := dependencies(group)
+	var (
+		errs []string
+		seen = map[cell.InfoValue]bool{}
+	)
+	for _, in := range missing {
+		if in.Optional || in.Group != "" || in.typ == nil || seen[in.InfoValue] || providedByHive(in) {
+			continue
+		}
+		seen[in.InfoValue] = true
+		stub, err := stubConstructor(in)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		cells = append(cells, cell.Provide(stub))
+	}
+	if len(errs) > 0 {
+		tb.Fatalf("Cannot stub the missing inputs of the cell, provide them with overrides:\n  %s",
+			strings.Join(errs, "\n  "))
+	}
+
+	opts := hive.DefaultOptions()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return hive.NewWithOptions(opts, cells...)
+}
+
+// providedByHive returns true if the input is provided by the hive itself,
+// e.g. cell.Lifecycle or *slog.Logger.
+func providedByHive(in input) bool {
+	for _, out := range internal.HiveObjects {
+		if internal.ProvidesInput(out, digValue(in.InfoValue)) {
+			return true
+		}
+	}
+	return false
+}
+
+// stubConstructor returns a constructor for the stub of the input.
+func stubConstructor(in input) (any, error) {
+	var value reflect.Value
+	switch in.typ.Kind() {
+	case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return nil, fmt.Errorf("%s: %s has no usable zero value", in, in.typ.Kind())
+	case reflect.Pointer:
+		value = reflect.New(in.typ.Elem())
+	case reflect.Map:
+		value = reflect.MakeMap(in.typ)
+	default:
+		value = reflect.Zero(in.typ)
+	}
+
+	outType := reflect.StructOf([]reflect.StructField{
+		{Name: "Out", Type: reflect.TypeOf(cell.Out{}), Anonymous: true},
+		{Name: "Value", Type: in.typ, Tag: nameTag(in.Name)},
+	})
+	return reflect.MakeFunc(
+		reflect.FuncOf(nil, []reflect.Type{outType}, false),
+		func([]reflect.Value) []reflect.Value {
+			out := reflect.New(outType).Elem()
+			out.Field(1).Set(value)
+			return []reflect.Value{out}
+		},
+	).Interface(), nil
+}
+
+func nameTag(name string) reflect.StructTag {
+	if name == "" {
+		return ""
+	}
+	return reflect.StructTag(fmt.Sprintf("name:%q", name))
+}
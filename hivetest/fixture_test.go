@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/hivetest"
+)
+
+type Database struct {
+	Name string
+}
+
+type Clock interface {
+	Now() int
+}
+
+type fakeClock struct{}
+
+func (fakeClock) Now() int { return 42 }
+
+type Repository struct {
+	DB      *Database
+	Limits  map[string]int
+	Retries int
+	Clock   Clock
+}
+
+type repositoryParams struct {
+	cell.In
+
+	DB      *Database
+	Limits  map[string]int
+	Retries int `name:"retries"`
+	Clock   Clock
+	Log     *slog.Logger
+	Lc      cell.Lifecycle
+}
+
+func newRepository(p repositoryParams) *Repository {
+	return &Repository{DB: p.DB, Limits: p.Limits, Retries: p.Retries, Clock: p.Clock}
+}
+
+var repositoryCell = cell.Module("repository", "Repository",
+	cell.Provide(newRepository),
+)
+
+func TestNew(t *testing.T) {
+	var repo *Repository
+	h := hivetest.New(t,
+		cell.Group(repositoryCell, cell.Invoke(func(r *Repository) { repo = r })),
+		func() Clock { return fakeClock{} },
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	t.Cleanup(func() { h.Stop(context.TODO()) })
+
+	require.NotNil(t, repo)
+	assert.NotNil(t, repo.DB, "expected *Database to be stubbed")
+	assert.NotNil(t, repo.Limits, "expected map to be stubbed")
+	assert.Zero(t, repo.Retries)
+	assert.Equal(t, 42, repo.Clock.Now())
+}
+
+func TestNewOverride(t *testing.T) {
+	var repo *Repository
+	h := hivetest.New(t,
+		cell.Group(repositoryCell, cell.Invoke(func(r *Repository) { repo = r })),
+		func() Clock { return fakeClock{} },
+		func() *Database { return &Database{Name: "test"} },
+	)
+	require.NoError(t, h.Start(context.TODO()))
+	t.Cleanup(func() { h.Stop(context.TODO()) })
+
+	require.NotNil(t, repo)
+	assert.Equal(t, "test", repo.DB.Name)
+}
+
+func TestNewCannotStub(t *testing.T) {
+	ft := &fakeTB{TB: t}
+	hivetest.New(ft, repositoryCell)
+	require.Len(t, ft.errors, 1)
+	assert.Contains(t, ft.errors[0], "hivetest_test.Clock: interface has no usable zero value")
+	assert.NotContains(t, ft.errors[0], "Database")
+}
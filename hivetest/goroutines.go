@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/hive"
+)
+
+// leakGracePeriod is how long the goroutines started by the hive are given
+// to exit after it has been stopped.
+const leakGracePeriod = time.Second
+
+// defaultGoroutineAllowlist are the stack substrings of the goroutines that
+// are started by the runtime and the standard library in the background and
+// are not leaked by the lifecycle hooks.
+var defaultGoroutineAllowlist = []string{
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+	"testing.tRunner",
+}
+
+// AssertNoLeakedGoroutines starts and stops the hive and asserts that the
+// lifecycle hooks did not leave goroutines running. The goroutines are
+// snapshotted before the start and after the stop, and the goroutines that
+// still exist after a grace period are reported with their stacks and the
+// function that created them. The goroutines whose stack contains any of the
+// allowlisted substrings are ignored in addition to the background goroutines
+// of the runtime:
+//
+//	hivetest.AssertNoLeakedGoroutines(t, hive.New(foo.Cell),
+//		"go.opencensus.io/stats/view.(*worker).start")
+//
+// The goroutines of parallel tests are indistinguishable from the ones of the
+// hive, so the tests using this should not be run in parallel.
+func AssertNoLeakedGoroutines(tb testing.TB, h *hive.Hive, allowlist ...string) {
+	tb.Helper()
+
+	before := goroutines()
+	if err := h.Start(context.TODO()); err != nil {
+		tb.Fatalf("Failed to start hive: %s", err)
+		return
+	}
+	if err := h.Stop(context.TODO()); err != nil {
+		tb.Fatalf("Failed to stop hive: %s", err)
+		return
+	}
+
+	allowlist = append(allowlist, defaultGoroutineAllowlist...)
+	var leaked []goroutine
+	for deadline := time.Now().Add(leakGracePeriod); ; {
+		leaked = leaked[:0]
+		for id, g := range goroutines() {
+			if _, existed := before[id]; !existed && !g.allowed(allowlist) {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, g := range leaked {
+		tb.Errorf("Leaked goroutine %s, created by %s:\n%s", g.id, g.creator(), g.stack)
+	}
+}
+
+// goroutine is a goroutine parsed from the output of runtime.Stack.
+type goroutine struct {
+	id    string
+	stack string
+}
+
+// goroutines returns the current goroutines, other than the calling one,
+// by their IDs.
+func goroutines() map[string]goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	gs := map[string]goroutine{}
+	for i, stack := range strings.Split(string(buf), "\n\n") {
+		if i == 0 {
+			// The first goroutine is the calling one.
+			continue
+		}
+		header, _, _ := strings.Cut(stack, "\n")
+		id, _, _ := strings.Cut(strings.TrimPrefix(header, "goroutine "), " ")
+		gs[id] = goroutine{id: id, stack: stack}
+	}
+	return gs
+}
+
+func (g goroutine) allowed(allowlist []string) bool {
+	for _, s := range allowlist {
+		if strings.Contains(g.stack, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// creator returns the function and the location that created the goroutine,
+// or "unknown" if the stack does not name it.
+func (g goroutine) creator() string {
+	_, created, found := strings.Cut(g.stack, "\ncreated by ")
+	if !found {
+		return "unknown"
+	}
+	fn, loc, _ := strings.Cut(created, "\n")
+	fn, _, _ = strings.Cut(fn, " in goroutine ")
+	loc, _, _ = strings.Cut(strings.TrimSpace(loc), " +0x")
+	return fn + " at " + loc
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hivetest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+	"github.com/cilium/hive/hivetest"
+)
+
+type Worker struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (w *Worker) run() {
+	defer close(w.done)
+	<-w.stop
+}
+
+func newWorker(lc cell.Lifecycle) *Worker {
+	w := &Worker{stop: make(chan struct{}), done: make(chan struct{})}
+	lc.Append(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			go w.run()
+			return nil
+		},
+		OnStop: func(cell.HookContext) error {
+			close(w.stop)
+			<-w.done
+			return nil
+		},
+	})
+	return w
+}
+
+func newLeakingWorker(lc cell.Lifecycle) *Worker {
+	w := &Worker{stop: make(chan struct{}), done: make(chan struct{})}
+	lc.Append(cell.Hook{
+		OnStart: func(cell.HookContext) error {
+			go w.run()
+			return nil
+		},
+	})
+	return w
+}
+
+func TestAssertNoLeakedGoroutines(t *testing.T) {
+	ft := &fakeTB{TB: t}
+	hivetest.AssertNoLeakedGoroutines(ft,
+		hive.New(cell.Provide(newWorker), cell.Invoke(func(*Worker) {})))
+	assert.Empty(t, ft.errors)
+}
+
+func TestAssertNoLeakedGoroutinesLeak(t *testing.T) {
+	var w *Worker
+	ft := &fakeTB{TB: t}
+	hivetest.AssertNoLeakedGoroutines(ft,
+		hive.New(cell.Provide(newLeakingWorker), cell.Invoke(func(worker *Worker) { w = worker })))
+	t.Cleanup(func() { close(w.stop) })
+
+	require.Len(t, ft.errors, 1)
+	assert.Contains(t, ft.errors[0], "hivetest_test.(*Worker).run")
+	assert.Contains(t, ft.errors[0], "created by github.com/cilium/hive/hivetest_test.newLeakingWorker.func1 at ")
+	assert.Contains(t, ft.errors[0], "goroutines_test.go:")
+
+	// Allowlisting the goroutine silences the report.
+	ft = &fakeTB{TB: t}
+	hivetest.AssertNoLeakedGoroutines(ft,
+		hive.New(cell.Provide(newLeakingWorker), cell.Invoke(func(*Worker) {})),
+		"hivetest_test.(*Worker).run")
+	assert.Empty(t, ft.errors)
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package internal
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/dig"
+)
+
+// DigValue is the information dig records about a single input or output
+// of a function.
+type DigValue struct {
+	// Type is the Go type of the value, or nil if it could not be found
+	// among the types of the function.
+	Type reflect.Type
+
+	// TypeName is the name of the type as formatted by dig, e.g. "*foo.Bar".
+	TypeName string
+
+	Name     string
+	Group    string
+	Optional bool
+}
+
+// DigInput returns the information recorded by dig about an input of the
+// function of type fn.
+func DigInput(in *dig.Input, fn reflect.Type) DigValue {
+	return parseDigValue(in.String(), fn)
+}
+
+// DigOutput returns the information recorded by dig about an output of the
+// function of type fn.
+func DigOutput(out *dig.Output, fn reflect.Type) DigValue {
+	return parseDigValue(out.String(), fn)
+}
+
+var (
+	digInType  = reflect.TypeOf(dig.In{})
+	digOutType = reflect.TypeOf(dig.Out{})
+
+	// digOption matches an option in the String() form of dig.Input and
+	// dig.Output, e.g. `name = "foo"`.
+	digOption      = `optional|(?:name|group) = "(?:[^"\\]|\\.)*"`
+	digOptionRegex = regexp.MustCompile(digOption)
+
+	// digValueRegex matches the String() form of dig.Input and dig.Output
+	// with options, e.g. `*foo.Bar[optional, name = "foo"]`.
+	digValueRegex = regexp.MustCompile(`^(.*)\[((?:` + digOption + `)(?:, (?:` + digOption + `))*)\]$`)
+)
+
+// parseDigValue parses the String() form of dig.Input or dig.Output, which
+// is the only way dig exposes them, and finds the Go type of the value among
+// the types of the parameters and results of the function of type fn and of
+// the fields of their dig.In and dig.Out structs.
+func parseDigValue(s string, fn reflect.Type) (dv DigValue) {
+	dv.TypeName = s
+	if m := digValueRegex.FindStringSubmatch(s); m != nil {
+		dv.TypeName = m[1]
+		for _, opt := range digOptionRegex.FindAllString(m[2], -1) {
+			key, value, _ := strings.Cut(opt, " = ")
+			value, _ = strconv.Unquote(value)
+			switch key {
+			case "optional":
+				dv.Optional = true
+			case "name":
+				dv.Name = value
+			case "group":
+				dv.Group = value
+			}
+		}
+	}
+	if fn != nil && fn.Kind() == reflect.Func {
+		dv.Type = findType(fn, dv.TypeName)
+	}
+	return
+}
+
+// findType returns the type with the name among the types of the function,
+// or nil if there is none.
+func findType(fn reflect.Type, name string) reflect.Type {
+	var found reflect.Type
+	var visit func(typ, marker reflect.Type)
+	visit = func(typ, marker reflect.Type) {
+		if found != nil {
+			return
+		}
+		if typ.Kind() == reflect.Struct && embeds(typ, marker) {
+			for i := 0; i < typ.NumField(); i++ {
+				if f := typ.Field(i); f.Type != marker {
+					visit(f.Type, marker)
+				}
+			}
+			return
+		}
+		// The value groups are consumed as slices and may be provided
+		// flattened from slices.
+		switch {
+		case typ.String() == name:
+			found = typ
+		case typ.Kind() == reflect.Slice && typ.Elem().String() == name:
+			found = typ.Elem()
+		case "[]"+typ.String() == name:
+			found = reflect.SliceOf(typ)
+		}
+	}
+	for i := 0; i < fn.NumIn(); i++ {
+		visit(fn.In(i), digInType)
+	}
+	for i := 0; i < fn.NumOut(); i++ {
+		visit(fn.Out(i), digOutType)
+	}
+	return found
+}
+
+// embeds returns true if the struct embeds the marker, e.g. dig.In.
+func embeds(typ, marker reflect.Type) bool {
+	for i := 0; i < typ.NumField(); i++ {
+		if f := typ.Field(i); f.Anonymous && f.Type == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// ProvidesInput returns true if the output satisfies the input, either by
+// being of the same type and name or by being a member of the value group.
+func ProvidesInput(out, in DigValue) bool {
+	if in.Group != "" {
+		return out.Group == in.Group && "[]"+out.TypeName == in.TypeName
+	}
+	return out.Group == "" && out.TypeName == in.TypeName && out.Name == in.Name
+}
+
+// HiveObjects are the objects the hive itself provides to the cells, e.g.
+// cell.Lifecycle and *slog.Logger. Set by the hive package.
+var HiveObjects []DigValue
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/dig"
+)
+
+type digTestObject struct{}
+
+type digTestGeneric[T any] struct{}
+
+type digTestNested struct {
+	dig.In
+	Generic *digTestGeneric[int] `name:"with [brackets], \"quotes\""`
+}
+
+type digTestParams struct {
+	dig.In
+	Plain    *digTestObject
+	Named    *digTestObject   `name:"named"`
+	Optional *digTestObject   `name:"optional" optional:"true"`
+	Group    []*digTestObject `group:"group"`
+	Nested   digTestNested
+}
+
+type digTestResult struct {
+	dig.Out
+	Named   *digTestObject   `name:"out"`
+	Group   *digTestObject   `group:"out-group"`
+	Flatten []*digTestObject `group:"flat,flatten"`
+}
+
+func TestDigValue(t *testing.T) {
+	fn := func(digTestParams, *digTestGeneric[string]) (digTestResult, error) { return digTestResult{}, nil }
+	var info dig.ProvideInfo
+	require.NoError(t, dig.New().Provide(fn, dig.FillProvideInfo(&info)))
+	fnType := reflect.TypeOf(fn)
+
+	objType := reflect.TypeOf(&digTestObject{})
+	value := func(typ reflect.Type, name, group string, optional bool) DigValue {
+		return DigValue{Type: typ, TypeName: typ.String(), Name: name, Group: group, Optional: optional}
+	}
+
+	inputs := make([]DigValue, len(info.Inputs))
+	for i, in := range info.Inputs {
+		inputs[i] = DigInput(in, fnType)
+	}
+	assert.ElementsMatch(t, []DigValue{
+		value(objType, "", "", false),
+		value(objType, "named", "", false),
+		value(objType, "optional", "", true),
+		value(reflect.SliceOf(objType), "", "group", false),
+		value(reflect.TypeOf(&digTestGeneric[int]{}), "with [brackets], \"quotes\"", "", false),
+		value(reflect.TypeOf(&digTestGeneric[string]{}), "", "", false),
+	}, inputs)
+
+	outputs := make([]DigValue, len(info.Outputs))
+	for i, out := range info.Outputs {
+		outputs[i] = DigOutput(out, fnType)
+	}
+	assert.ElementsMatch(t, []DigValue{
+		value(objType, "out", "", false),
+		value(objType, "", "out-group", false),
+		value(objType, "", "flat", false),
+	}, outputs)
+
+	// The type is not known without the function.
+	assert.Equal(t, DigValue{TypeName: objType.String(), Name: "out"}, DigOutput(info.Outputs[0], nil))
+}
+
+func TestProvidesInput(t *testing.T) {
+	obj := DigValue{TypeName: "*foo.Bar"}
+	assert.True(t, ProvidesInput(obj, obj))
+	assert.False(t, ProvidesInput(obj, DigValue{TypeName: "*foo.Bar", Name: "bar"}))
+	assert.True(t, ProvidesInput(DigValue{TypeName: "*foo.Bar", Group: "bars"}, DigValue{TypeName: "[]*foo.Bar", Group: "bars"}))
+	assert.False(t, ProvidesInput(obj, DigValue{TypeName: "[]*foo.Bar", Group: "bars"}))
+	assert.False(t, ProvidesInput(DigValue{TypeName: "*foo.Bar", Group: "bars"}, obj))
+}
@@ -10,14 +10,47 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 func PrettyType(x any) string {
 	return fmt.Sprintf("%T", x)
 }
 
+// funcNameAndLocationCache memoizes FuncNameAndLocation. Keyed by the
+// code pointer of the function, so the size is bounded by the number of
+// functions in the program.
+var funcNameAndLocationCache sync.Map // uintptr => string
+
+// FuncNameAndLocation returns the name and source location of the function,
+// e.g. "foo.NewBar (pkg/foo/bar.go:12)". For a value that is not a function,
+// e.g. an invalid constructor, the type of the value is returned.
 func FuncNameAndLocation(fn any) string {
-	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return PrettyType(fn)
+	}
+	pc := v.Pointer()
+	if s, ok := funcNameAndLocationCache.Load(pc); ok {
+		return s.(string)
+	}
+	s := funcNameAndLocation(pc)
+	funcNameAndLocationCache.Store(pc, s)
+	return s
+}
+
+// CallerLocation returns the source location of the caller of the function
+// calling CallerLocation, e.g. "pkg/foo/bar.go:12".
+func CallerLocation() string {
+	_, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", usefulPathSegment(file), line)
+}
+
+func funcNameAndLocation(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
 	file, line := f.FileLine(f.Entry())
 	name := f.Name()
 	name = strings.TrimSuffix(name, "-fm")
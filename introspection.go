@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cilium/hive/cell"
+)
+
+// IntrospectionHandler returns a HTTP handler for inspecting a running hive.
+// It serves the following paths as JSON:
+//
+//	/objects  The information about the cells, as with PrintObjects.
+//	/health   The health statuses, as returned by Health.
+//	/timing   The durations of the lifecycle start and stop hooks.
+//
+// To serve it under e.g. "/hive/" use http.StripPrefix:
+//
+//	mux.Handle("/hive/", http.StripPrefix("/hive", h.IntrospectionHandler()))
+func (h *Hive) IntrospectionHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/objects", h.serveObjects)
+	mux.HandleFunc("/health", h.serveHealth)
+	mux.HandleFunc("/timing", h.serveTiming)
+	return mux
+}
+
+func (h *Hive) serveObjects(w http.ResponseWriter, r *http.Request) {
+	if err := h.Populate(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, h.infos())
+}
+
+func (h *Hive) serveHealth(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.Health()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if statuses == nil {
+		statuses = []cell.HealthStatus{}
+	}
+	writeJSON(w, statuses)
+}
+
+func (h *Hive) serveTiming(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.timings.get())
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// HookTiming is the duration and result of running a lifecycle hook.
+type HookTiming struct {
+	// Name is the name of the hook as logged by the lifecycle.
+	Name string `json:"name"`
+
+	// Phase is either "start" or "stop".
+	Phase string `json:"phase"`
+
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// hookTimings records the hook timings for the introspection handler and
+// passes them on to the LifecycleMetrics given in the options.
+type hookTimings struct {
+	next cell.LifecycleMetrics
+
+	mu      sync.Mutex
+	timings []HookTiming
+}
+
+func (t *hookTimings) HookStart(name string, d time.Duration, err error) {
+	t.record(name, "start", d, err)
+	if t.next != nil {
+		t.next.HookStart(name, d, err)
+	}
+}
+
+func (t *hookTimings) HookStop(name string, d time.Duration, err error) {
+	t.record(name, "stop", d, err)
+	if t.next != nil {
+		t.next.HookStop(name, d, err)
+	}
+}
+
+func (t *hookTimings) record(name, phase string, d time.Duration, err error) {
+	timing := HookTiming{Name: name, Phase: phase, Duration: d}
+	if err != nil {
+		timing.Error = err.Error()
+	}
+	t.mu.Lock()
+	t.timings = append(t.timings, timing)
+	t.mu.Unlock()
+}
+
+func (t *hookTimings) get() []HookTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.timings == nil {
+		return []HookTiming{}
+	}
+	return slices.Clone(t.timings)
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package job
+
+import (
+	"github.com/cilium/hive/cell"
+)
+
+// Goroutines is a group of goroutines bound to the lifecycle of the hive,
+// for the cells that need to run a few goroutines without managing their own
+// Group. Provided by Cell. The goroutines started before the hive is started
+// are queued until the start. When the hive is stopped the contexts of the
+// goroutines are cancelled and the stop waits for them to return. As the
+// group is shared, the goroutines are stopped after the stop hooks of the
+// cells depending on it.
+//
+//	func newFoo(gs job.Goroutines) *Foo {
+//		foo := &Foo{}
+//		gs.Go("foo-loop", foo.loop, job.WithShutdown())
+//		return foo
+//	}
+type Goroutines interface {
+	// Go runs the function in a goroutine as a one shot job with the given
+	// options, e.g. WithShutdown for shutting down the hive if it fails.
+	Go(name string, fn OneShotFunc, opts ...jobOneShotOpt)
+}
+
+type goroutines struct {
+	group Group
+}
+
+func newGoroutines(lc cell.Lifecycle, r Registry, health cell.Health) Goroutines {
+	g := r.NewGroup(health)
+	lc.Append(g)
+	return &goroutines{group: g}
+}
+
+func (gs *goroutines) Go(name string, fn OneShotFunc, opts ...jobOneShotOpt) {
+	gs.group.Add(OneShot(name, fn, opts...))
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/hive"
+	"github.com/cilium/hive/cell"
+)
+
+// This test asserts that the goroutines are cancelled and waited for when the hive is stopped.
+func TestGoroutines_Stop(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	stopped := false
+
+	h := hive.New(
+		cell.SimpleHealthCell,
+		Cell,
+		cell.Module("test", "test module", cell.Invoke(func(gs Goroutines) {
+			gs.Go("long", func(ctx context.Context, health cell.Health) error {
+				close(started)
+				<-ctx.Done()
+				stopped = true
+				return nil
+			})
+		})),
+	)
+
+	if assert.NoError(t, h.Start(context.Background())) {
+		<-started
+		assert.NoError(t, h.Stop(context.Background()))
+		assert.True(t, stopped, "expected stop to wait for the goroutine")
+	}
+}
+
+// This test asserts that a failing goroutine started with WithShutdown shuts down the hive.
+func TestGoroutines_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	targetErr := errors.New("Always error")
+	h := hive.New(
+		cell.SimpleHealthCell,
+		Cell,
+		cell.Module("test", "test module", cell.Invoke(func(gs Goroutines) {
+			gs.Go("shutdown", func(ctx context.Context, health cell.Health) error {
+				return targetErr
+			}, WithShutdown())
+		})),
+	)
+
+	err := h.Run()
+	assert.ErrorIs(t, err, targetErr)
+}
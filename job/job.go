@@ -23,6 +23,7 @@ var Cell = cell.Module(
 	"Managed background goroutines and timers",
 	cell.Provide(
 		newRegistry,
+		newGoroutines,
 	),
 )
 
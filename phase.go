@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cilium/hive/internal"
+)
+
+// Phase is a boundary of the lifecycle of the hive at which the phase hooks
+// are run.
+type Phase int
+
+const (
+	// PreStart is before the start hooks are run. An error aborts the start.
+	PreStart Phase = iota
+
+	// PostStart is after the start hooks have completed successfully. An
+	// error fails the start, so that e.g. Run stops the hive.
+	PostStart
+
+	// PreStop is before the stop hooks are run. An error does not prevent
+	// the stop hooks from running and is returned from Stop.
+	PreStop
+
+	// PostStop is after the stop hooks have completed. An error is returned
+	// from Stop and thus from Run.
+	PostStop
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PreStart:
+		return "pre-start"
+	case PostStart:
+		return "post-start"
+	case PreStop:
+		return "pre-stop"
+	case PostStop:
+		return "post-stop"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// PhaseHook is a function run at a boundary of the lifecycle of the hive
+// rather than of a single cell, e.g. for flushing the metrics after the hive
+// has stopped:
+//
+//	opts.PhaseHooks = append(opts.PhaseHooks, hive.PhaseHook{
+//		Phase: hive.PostStop,
+//		Fn:    flushMetrics,
+//	})
+//
+// The hooks of the same phase are run in the order they are given in.
+type PhaseHook struct {
+	Phase Phase
+	Fn    func(ctx context.Context) error
+}
+
+// runPhase runs the phase hooks of the phase. With the start phases the first
+// error stops running the hooks, with the stop phases all the hooks are run
+// and the errors are joined.
+func (h *Hive) runPhase(ctx context.Context, phase Phase) error {
+	var errs []error
+	for _, hook := range h.opts.PhaseHooks {
+		if hook.Phase != phase {
+			continue
+		}
+		if err := hook.Fn(ctx); err != nil {
+			err = fmt.Errorf("%s hook %s failed: %w", phase, internal.FuncNameAndLocation(hook.Fn), err)
+			h.log.Error("Phase hook failed", "phase", phase, "error", err)
+			if phase == PreStart || phase == PostStart {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
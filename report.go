@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/cilium/hive/cell"
+)
+
+// StartupReport is the report of the startup of the hive written as JSON to
+// the file given in Options.StartupReport once the hive has started.
+type StartupReport struct {
+	// Durations is the breakdown of the startup duration.
+	Durations StartupReportDurations `json:"durations"`
+
+	// Constructors are the called constructors in the order they were
+	// called.
+	Constructors []ConstructorTiming `json:"constructors"`
+
+	// Hooks are the executed start hooks in the order they were executed.
+	Hooks []HookTiming `json:"hooks"`
+
+	// Healthy is true if none of the health scopes are degraded, see
+	// Hive.Ready.
+	Healthy bool `json:"healthy"`
+
+	// Health are the health statuses, as returned by Hive.Health.
+	Health []cell.HealthStatus `json:"health"`
+}
+
+// StartupReportDurations are the StartupDurations in the startup report.
+type StartupReportDurations struct {
+	Build  time.Duration `json:"build"`
+	Invoke time.Duration `json:"invoke"`
+	Start  time.Duration `json:"start"`
+	Total  time.Duration `json:"total"`
+}
+
+// ConstructorTiming is the duration of calling a constructor.
+type ConstructorTiming struct {
+	// Name is the name and location of the constructor.
+	Name string `json:"name"`
+
+	Duration time.Duration `json:"duration"`
+}
+
+// startupReport collects the startup report from the durations recorded
+// during the startup.
+func (h *Hive) startupReport() (*StartupReport, error) {
+	statuses, err := h.Health()
+	if err != nil {
+		return nil, err
+	}
+	if statuses == nil {
+		statuses = []cell.HealthStatus{}
+	}
+	r := &StartupReport{
+		Durations: StartupReportDurations{
+			Build:  h.startup.Build,
+			Invoke: h.startup.Invoke,
+			Start:  h.startup.Start,
+			Total:  h.startup.Total(),
+		},
+		Constructors: h.ctors.getTimings(),
+		Hooks:        []HookTiming{},
+		Healthy:      h.healthy(statuses),
+		Health:       statuses,
+	}
+	for _, t := range h.timings.get() {
+		if t.Phase == "start" {
+			r.Hooks = append(r.Hooks, t)
+		}
+	}
+	return r, nil
+}
+
+// writeStartupReport writes the startup report to the file given in the
+// options, if any. Failing to write it is logged but does not fail the start.
+func (h *Hive) writeStartupReport() {
+	path := h.opts.StartupReport
+	if path == "" {
+		return
+	}
+	r, err := h.startupReport()
+	var data []byte
+	if err == nil {
+		data, err = json.MarshalIndent(r, "", "  ")
+	}
+	if err == nil {
+		err = os.WriteFile(path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		h.log.Error("Failed to write startup report", "path", path, "error", err)
+	}
+}
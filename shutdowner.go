@@ -3,6 +3,11 @@
 
 package hive
 
+import (
+	"context"
+	"fmt"
+)
+
 // Shutdowner provides Shutdown(), which is a way to trigger stop for hive.
 //
 // To shut down with an error, call Shutdown with ShutdownWithError(err).
@@ -29,3 +34,95 @@ func (fn optionFunc) apply(opts *shutdownOptions) { fn(opts) }
 type shutdownOptions struct {
 	err error
 }
+
+// ShutdownReason is the reason the hive is stopped, for the stop hooks to
+// decide e.g. how thoroughly to clean up. See ShutdownReasonOf.
+type ShutdownReason int
+
+const (
+	// ShutdownClean is a stop without an error, e.g. Shutdown() called
+	// without an error or Stop called directly.
+	ShutdownClean ShutdownReason = iota
+
+	// ShutdownSignal is a stop by a signal received by Run.
+	ShutdownSignal
+
+	// ShutdownError is a stop by Shutdown called with ShutdownWithError or
+	// by a failed start.
+	ShutdownError
+)
+
+func (r ShutdownReason) String() string {
+	switch r {
+	case ShutdownClean:
+		return "clean"
+	case ShutdownSignal:
+		return "signal"
+	case ShutdownError:
+		return "error"
+	}
+	return fmt.Sprintf("ShutdownReason(%d)", int(r))
+}
+
+type shutdownReasonKey struct{}
+
+// shutdownCause is the reason the hive is stopped and the error if the
+// reason is ShutdownError.
+type shutdownCause struct {
+	reason ShutdownReason
+	err    error
+}
+
+// ShutdownReasonOf returns the reason the hive is being stopped and the error
+// causing it, if any, from the context given to the stop hooks:
+//
+//	OnStop: func(ctx cell.HookContext) error {
+//		if reason, _ := hive.ShutdownReasonOf(ctx); reason == hive.ShutdownClean {
+//			return flushAll()
+//		}
+//		return nil
+//	}
+//
+// Returns ShutdownClean for a context not given by the hive.
+func ShutdownReasonOf(ctx context.Context) (ShutdownReason, error) {
+	if c, ok := ctx.Value(shutdownReasonKey{}).(shutdownCause); ok {
+		return c.reason, c.err
+	}
+	return ShutdownClean, nil
+}
+
+// setShutdownReason records the reason the hive is stopped. Only the first
+// reason is recorded, as it is the one that caused the stop.
+func (h *Hive) setShutdownReason(reason ShutdownReason, err error) {
+	h.shutdownMu.Lock()
+	defer h.shutdownMu.Unlock()
+	h.setShutdownReasonLocked(reason, err)
+}
+
+func (h *Hive) setShutdownReasonLocked(reason ShutdownReason, err error) {
+	if h.shutdownCause == nil {
+		h.shutdownCause = &shutdownCause{reason, err}
+	}
+}
+
+// clearShutdownReason forgets the reason the hive was previously stopped
+// when it is started again, unless a shutdown has been requested already.
+func (h *Hive) clearShutdownReason() {
+	h.shutdownMu.Lock()
+	defer h.shutdownMu.Unlock()
+	if len(h.shutdown) == 0 {
+		h.shutdownCause = nil
+	}
+}
+
+// withShutdownReason returns the context with the recorded reason the hive
+// is stopped, or ShutdownClean if none has been recorded.
+func (h *Hive) withShutdownReason(ctx context.Context) context.Context {
+	h.shutdownMu.Lock()
+	defer h.shutdownMu.Unlock()
+	cause := shutdownCause{reason: ShutdownClean}
+	if h.shutdownCause != nil {
+		cause = *h.shutdownCause
+	}
+	return context.WithValue(ctx, shutdownReasonKey{}, cause)
+}
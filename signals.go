@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"os"
+	"slices"
+	"syscall"
+)
+
+// RunOption is an option to Run.
+type RunOption interface {
+	apply(*runOptions)
+}
+
+// WithSignals sets the signals that stop the hive when received by Run.
+// Defaults to os.Interrupt and SIGTERM.
+func WithSignals(sigs ...os.Signal) RunOption {
+	return runOptionFunc(func(opts *runOptions) {
+		opts.signals = sigs
+	})
+}
+
+// WithoutSignals disables the signal handling of Run. The hive is then only
+// stopped by Shutdowner.Shutdown(). Useful when embedding the hive in a
+// program that handles the signals itself.
+func WithoutSignals() RunOption {
+	return runOptionFunc(func(opts *runOptions) {
+		opts.noSignals = true
+	})
+}
+
+// WithReload makes SIGHUP call the reload function instead of stopping the
+// hive. The function is called from the goroutine handling the signals and
+// further signals are handled only after it returns.
+func WithReload(reload func()) RunOption {
+	return runOptionFunc(func(opts *runOptions) {
+		opts.reload = reload
+	})
+}
+
+// WithSignalChannel makes Run receive the signals from the given channel
+// instead of from the operating system. Signals not in the signal set are
+// ignored. Meant for testing the signal handling.
+func WithSignalChannel(ch <-chan os.Signal) RunOption {
+	return runOptionFunc(func(opts *runOptions) {
+		opts.channel = ch
+	})
+}
+
+type runOptionFunc func(*runOptions)
+
+func (fn runOptionFunc) apply(opts *runOptions) { fn(opts) }
+
+type runOptions struct {
+	signals   []os.Signal
+	noSignals bool
+	reload    func()
+	channel   <-chan os.Signal
+}
+
+func newRunOptions(opts []RunOption) runOptions {
+	ro := runOptions{signals: []os.Signal{os.Interrupt, syscall.SIGTERM}}
+	for _, opt := range opts {
+		opt.apply(&ro)
+	}
+	if ro.noSignals {
+		ro.signals = nil
+	} else if ro.reload != nil && !slices.Contains(ro.signals, os.Signal(syscall.SIGHUP)) {
+		ro.signals = append(slices.Clip(ro.signals), syscall.SIGHUP)
+	}
+	return ro
+}
+
+// isReload returns true if the signal should reload rather than stop
+// the hive.
+func (ro *runOptions) isReload(sig os.Signal) bool {
+	return ro.reload != nil && sig == syscall.SIGHUP
+}
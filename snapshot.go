@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cilium/hive/cell"
+)
+
+// GraphSnapshot returns a canonical textual representation of the
+// constructors and invoke functions of the hive with their inputs and
+// outputs, grouped by the module they are in. The representation is stable
+// across builds: the scopes and the functions within them are sorted and
+// the locations of the functions are reduced to the name of the file, so
+// that it is suitable for comparing against a golden file in a test to catch
+// unintended changes to the wiring:
+//
+//	module foo:
+//	  constructor foo.newFoo (foo.go):
+//	    inputs: *bar.Bar, cell.Lifecycle
+//	    outputs: *foo.Foo
+//
+// Does not populate the hive.
+func (h *Hive) GraphSnapshot() string {
+	scopes := map[string][]string{}
+	for _, c := range h.cells {
+		snapshotInfo(c.Info(h.container), "", scopes)
+	}
+
+	names := make([]string, 0, len(scopes))
+	for name := range scopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if name == "" {
+			b.WriteString("root:\n")
+		} else {
+			b.WriteString("module " + name + ":\n")
+		}
+		entries := scopes[name]
+		sort.Strings(entries)
+		for _, entry := range entries {
+			b.WriteString(entry)
+		}
+	}
+	return b.String()
+}
+
+// snapshotLocationRegex matches the location of a function, e.g.
+// " (.../foo/bar.go:12)", to reduce it to the name of the file.
+var snapshotLocationRegex = regexp.MustCompile(` \([^()]*?([^/()]+\.go):\d+\)`)
+
+// snapshotInfo adds the entries of the constructors and invoke functions in
+// the Info tree to the scopes by the full ID of the module they are in.
+func snapshotInfo(info cell.Info, scope string, scopes map[string][]string) {
+	n, ok := info.(*cell.InfoNode)
+	if !ok {
+		return
+	}
+	if m := n.Module(); m != nil {
+		if scope != "" {
+			scope += "."
+		}
+		scope += m.ID
+		if _, ok := scopes[scope]; !ok {
+			scopes[scope] = nil
+		}
+	}
+	if p := n.Provider(); p != nil {
+		kind := "constructor"
+		if !p.Exported {
+			kind = "private constructor"
+		}
+		scopes[scope] = append(scopes[scope], snapshotEntry(kind, p.Name, p.Inputs, p.Outputs))
+	}
+	if r := n.Replacement(); r != nil {
+		scopes[scope] = append(scopes[scope], snapshotEntry("replacement", r.Name, r.Inputs, r.Outputs))
+	}
+	if inv := n.Invoke(); inv != nil {
+		scopes[scope] = append(scopes[scope], snapshotEntry("invoke", inv.Name, inv.Inputs, nil))
+	}
+	for _, child := range n.Children() {
+		snapshotInfo(child, scope, scopes)
+	}
+}
+
+func snapshotEntry(kind, name string, inputs, outputs []cell.InfoValue) string {
+	var b strings.Builder
+	b.WriteString("  " + kind + " " + snapshotLocationRegex.ReplaceAllString(name, " ($1)") + ":\n")
+	if len(inputs) > 0 {
+		b.WriteString("    inputs: " + joinSnapshotValues(inputs) + "\n")
+	}
+	if len(outputs) > 0 {
+		b.WriteString("    outputs: " + joinSnapshotValues(outputs) + "\n")
+	}
+	return b.String()
+}
+
+func joinSnapshotValues(vs []cell.InfoValue) string {
+	strs := make([]string, len(vs))
+	for i, v := range vs {
+		strs[i] = v.String()
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, ", ")
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package hive
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cilium/hive/cell"
+)
+
+// constructorTracer is the cell.Tracer given to the constructors. As the
+// constructors have no context it starts their spans as children of the
+// "hive start" span while the hive is starting.
+type constructorTracer struct {
+	next cell.Tracer
+
+	mu     sync.Mutex
+	parent context.Context
+}
+
+func (t *constructorTracer) Start(ctx context.Context, name string) (context.Context, cell.Span) {
+	t.mu.Lock()
+	if t.parent != nil {
+		ctx = t.parent
+	}
+	t.mu.Unlock()
+	return t.next.Start(ctx, name)
+}
+
+func (t *constructorTracer) setParent(ctx context.Context) {
+	t.mu.Lock()
+	t.parent = ctx
+	t.mu.Unlock()
+}
+
+// ctorTracer returns the tracer for the constructors, or nil if tracing is
+// disabled so that the constructors are not traced at all.
+func (h *Hive) ctorTracer() cell.Tracer {
+	if h.opts.Tracer == nil {
+		return nil
+	}
+	return h.tracer
+}